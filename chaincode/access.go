@@ -0,0 +1,111 @@
+package chaincode
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/core/chaincode/shim/ext/cid"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/vtfr/rocha"
+)
+
+// AdminAttribute is the certificate attribute which grants administrative
+// permissions to an identity when set to "true". Any organization's CA may
+// issue it, so it's only accepted until the configuration lists admins
+const AdminAttribute = "coffee.admin"
+
+// isAdmin verifies if the transaction creator is an administrator, either by
+// being one of the configured admins, or by holding the AdminAttribute if
+// no admins are configured
+func (cf *configurable) isAdmin(stub shim.ChaincodeStubInterface) (bool, error) {
+	identity, err := cid.New(stub)
+	if err != nil {
+		return false, err
+	}
+
+	config, err := cf.config(stub)
+	if err != nil {
+		return false, err
+	}
+
+	if len(config.Admins) == 0 {
+		return identity.AssertAttributeValue(AdminAttribute, "true") == nil, nil
+	}
+
+	mspID, name, err := identityName(identity)
+	if err != nil || name == "" {
+		return false, err
+	}
+
+	return config.IsAdmin(mspID, name), nil
+}
+
+// isPaymentProcessor verifies if the transaction creator is one of the
+// configured payment processors
+func (cf *configurable) isPaymentProcessor(stub shim.ChaincodeStubInterface) (bool, error) {
+	identity, err := cid.New(stub)
+	if err != nil {
+		return false, err
+	}
+
+	mspID, name, err := identityName(identity)
+	if err != nil || name == "" {
+		return false, err
+	}
+
+	config, err := cf.config(stub)
+	if err != nil {
+		return false, err
+	}
+
+	return config.IsPaymentProcessor(mspID, name), nil
+}
+
+// identityName returns the organization and certificate common name of an
+// identity, the name empty if it has no certificate
+func identityName(identity cid.ClientIdentity) (string, string, error) {
+	mspID, err := identity.GetMSPID()
+	if err != nil {
+		return "", "", err
+	}
+
+	cert, err := identity.GetX509Certificate()
+	if err != nil || cert == nil {
+		return "", "", err
+	}
+
+	return mspID, cert.Subject.CommonName, nil
+}
+
+// adminOnly is a middleware which only allows administrators to call the
+// next handler
+func (cf *configurable) adminOnly(next rocha.Handler) rocha.Handler {
+	return func(c rocha.Context) pb.Response {
+		admin, err := cf.isAdmin(c.Stub())
+		if err != nil {
+			return shim.Error(fmt.Sprintf("Permission denied: %s", err.Error()))
+		}
+		if !admin {
+			return shim.Error("Permission denied: caller is not an administrator")
+		}
+		return next(c)
+	}
+}
+
+// paymentsOnly is a middleware which only allows administrators and payment
+// processors to call the next handler
+func (cf *configurable) paymentsOnly(next rocha.Handler) rocha.Handler {
+	return func(c rocha.Context) pb.Response {
+		allowed, err := cf.isAdmin(c.Stub())
+		if err == nil && !allowed {
+			allowed, err = cf.isPaymentProcessor(c.Stub())
+		}
+		if err != nil {
+			return shim.Error(fmt.Sprintf("Permission denied: %s", err.Error()))
+		}
+		if !allowed {
+			return shim.Error("Permission denied: caller is not an administrator nor a payment processor")
+		}
+		return next(c)
+	}
+}
@@ -0,0 +1,214 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/cdtlab19/coffee-chaincode/model"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/vtfr/rocha"
+)
+
+// MaxBatchSize is the maximum number of operations of a batch
+const MaxBatchSize = 100
+
+// Batch modes
+const (
+	// BatchAtomic fails the whole batch if any operation fails
+	BatchAtomic = "atomic"
+	// BatchBestEffort discards only the writes of failed operations. Operations
+	// can't invoke other chaincodes, whose writes can't be discarded
+	BatchBestEffort = "bestEffort"
+)
+
+// Operation is an invocation of a chaincode function in a batch
+type Operation struct {
+	Function string   `json:"function"`
+	Args     []string `json:"args"`
+}
+
+// OperationResult is the response of an operation in a batch
+type OperationResult struct {
+	Function string          `json:"function"`
+	Status   int32           `json:"status"`
+	Message  string          `json:"message,omitempty"`
+	Payload  json.RawMessage `json:"payload,omitempty"`
+}
+
+// BatchResult reports the results of all operations in a batch
+type BatchResult struct {
+	Results   []OperationResult `json:"results"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+}
+
+// BatchError is returned when an operation of an atomic batch fails
+type BatchError struct {
+	Index    int
+	Function string
+	Response pb.Response
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("operation %d (%s) failed: %s", e.Index, e.Function, e.Response.Message)
+}
+
+// Status returns the status of the failed operation
+func (e *BatchError) Status() int32 {
+	return e.Response.Status
+}
+
+// operationStub is the stub of an operation in a batch, with it's own
+// function, arguments and transaction ID. The transaction ID is derived from
+// the batch's, so assets created by different operations get different IDs
+type operationStub struct {
+	*overlayStub
+	operation Operation
+	txID      string
+	mode      string
+}
+
+func newOperationStub(stub shim.ChaincodeStubInterface, index int, operation Operation, mode string) *operationStub {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s/%d", stub.GetTxID(), index)))
+	return &operationStub{newOverlayStub(stub), operation, hex.EncodeToString(sum[:]), mode}
+}
+
+func (s *operationStub) GetTxID() string {
+	return s.txID
+}
+
+func (s *operationStub) GetFunctionAndParameters() (string, []string) {
+	return s.operation.Function, s.operation.Args
+}
+
+func (s *operationStub) GetStringArgs() []string {
+	return append([]string{s.operation.Function}, s.operation.Args...)
+}
+
+func (s *operationStub) GetArgs() [][]byte {
+	args := [][]byte{}
+	for _, arg := range s.GetStringArgs() {
+		args = append(args, []byte(arg))
+	}
+	return args
+}
+
+// InvokeChaincode fails in best-effort batches, as the writes of the invoked
+// chaincode aren't buffered and would be kept even if the operation fails
+func (s *operationStub) InvokeChaincode(name string, args [][]byte, channel string) pb.Response {
+	if s.mode == BatchBestEffort {
+		return shim.Error(fmt.Sprintf("operations of %s batches can't invoke the %s chaincode",
+			BatchBestEffort, name))
+	}
+	return s.overlayStub.InvokeChaincode(name, args, channel)
+}
+
+// GetTransient returns the batch's transient map without the client request
+// ID, which identifies the whole batch
+func (s *operationStub) GetTransient() (map[string][]byte, error) {
+	return withoutRequestID(s.overlayStub)
+}
+
+// withoutRequestID returns the transient map of a stub without the client
+// request ID
+func withoutRequestID(stub shim.ChaincodeStubInterface) (map[string][]byte, error) {
+	transient, err := stub.GetTransient()
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string][]byte{}
+	for key, value := range transient {
+		if key != RequestIDKey {
+			result[key] = value
+		}
+	}
+	return result, nil
+}
+
+// invokeFunc invokes a function with a stub, such as a router's Invoke
+type invokeFunc func(stub shim.ChaincodeStubInterface, function string, args []string) pb.Response
+
+// runBatch invokes the operations received as a JSON array in the `batch`
+// argument with `invoke`, in order. Each operation sees the writes of the
+// previous ones. In the atomic mode, the first failure fails the batch.
+// In the best-effort mode, the writes of failed operations are discarded and
+// the others are kept, and operations invoking other chaincodes fail
+func runBatch(c rocha.Context, invoke invokeFunc) (interface{}, error) {
+	var operations []Operation
+	if err := json.Unmarshal([]byte(c.String("batch")), &operations); err != nil {
+		return nil, fmt.Errorf("invalid batch: %s", err.Error())
+	}
+
+	if len(operations) == 0 || len(operations) > MaxBatchSize {
+		return nil, fmt.Errorf("a batch must have between 1 and %d operations", MaxBatchSize)
+	}
+
+	mode := c.String("mode")
+	if mode == "" {
+		mode = BatchAtomic
+	}
+	if mode != BatchAtomic && mode != BatchBestEffort {
+		return nil, fmt.Errorf("invalid batch mode '%s'", mode)
+	}
+
+	batch := newOverlayStub(c.Stub())
+	result := &BatchResult{Results: []OperationResult{}}
+
+	for i, operation := range operations {
+		var response pb.Response
+		stub := newOperationStub(batch, i, operation, mode)
+
+		// namespaced functions of the combined chaincode can't nest batches
+		// either
+		function := operation.Function[strings.LastIndex(operation.Function, ":")+1:]
+		if function == c.Method() {
+			response = shim.Error("batches can't be nested")
+		} else {
+			response = invoke(stub, operation.Function, operation.Args)
+		}
+
+		operationResult := OperationResult{
+			Function: operation.Function,
+			Status:   response.Status,
+			Message:  response.Message,
+		}
+		if json.Valid(response.Payload) {
+			operationResult.Payload = response.Payload
+		}
+		result.Results = append(result.Results, operationResult)
+
+		if response.Status >= shim.ERRORTHRESHOLD {
+			if mode == BatchAtomic {
+				return nil, &BatchError{i, operation.Function, response}
+			}
+
+			result.Failed++
+			continue
+		}
+
+		if err := stub.flush(); err != nil {
+			return nil, err
+		}
+		result.Succeeded++
+	}
+
+	return result, batch.flush()
+}
+
+var batchFunction = Function{
+	Name: "Batch",
+	Description: "Invokes a JSON array of operations, each with a `function` and it's `args`, " +
+		"in a single transaction",
+	Args: []Argument{
+		stringArg("batch", "JSON array of operations").format(FormatJSON),
+		stringArg("mode", "\"atomic\" or \"bestEffort\"").optional(BatchAtomic),
+	},
+	Idempotent: true,
+	Feature:    model.FeatureBatch,
+	Response:   schemaOf(BatchResult{}),
+}
@@ -0,0 +1,186 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cdtlab19/coffee-chaincode/chaincode"
+	"github.com/cdtlab19/coffee-chaincode/logging"
+	"github.com/cdtlab19/coffee-chaincode/mockstub"
+	"github.com/cdtlab19/coffee-chaincode/model"
+	"github.com/cdtlab19/coffee-chaincode/store"
+)
+
+var _ = Describe("Batch", func() {
+	var mock *mockstub.Stub
+	var logger *logging.Logger
+	var st *store.CoffeeStore
+
+	BeforeEach(func() {
+		logger = logging.New("batch-test")
+		mock = mockstub.NewStub("coffee", NewCoffeeChaincode(logger))
+		st = store.NewCoffeeStore(mock, logger)
+		Expect(mock.SetCreator("Org1MSP", "someone", nil)).To(Succeed())
+
+		createTestCoffee(mock, st, model.NewCoffee("0000", "cappuccino"))
+	})
+
+	batch := func(mode string, operations ...Operation) pb.Response {
+		data, err := json.Marshal(operations)
+		Expect(err).NotTo(HaveOccurred())
+
+		args := [][]byte{[]byte("Batch"), data}
+		if mode != "" {
+			args = append(args, []byte(mode))
+		}
+		return mock.MockInvoke("0001", args)
+	}
+
+	batchResult := func(response pb.Response) *BatchResult {
+		Expect(int(response.Status)).To(Equal(shim.OK))
+
+		result := &BatchResult{}
+		Expect(json.Unmarshal(response.Payload, result)).To(Succeed())
+		return result
+	}
+
+	It("Should run all operations with different transaction IDs", func() {
+		result := batchResult(batch("",
+			Operation{"CreateCoffee", []string{"mocha"}},
+			Operation{"CreateCoffee", []string{"latte"}},
+			Operation{"UseCoffee", []string{"0000", "user"}},
+		))
+		Expect(result.Succeeded).To(Equal(3))
+		Expect(result.Failed).To(Equal(0))
+		Expect(result.Results).To(HaveLen(3))
+		Expect(result.Results[0].Payload).To(ContainSubstring("mocha"))
+
+		coffees, err := st.AllCoffee(false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(coffees).To(HaveLen(3))
+
+		used, err := st.GetCoffee("0000")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(used.Owner).To(Equal("user"))
+	})
+
+	It("Should let operations see the writes of previous operations", func() {
+		result := batchResult(batch(BatchBestEffort,
+			Operation{"UseCoffee", []string{"0000", "user"}},
+			Operation{"CoffeeByOwner", []string{"user"}},
+			Operation{"DeleteCoffee", []string{"0000"}},
+			Operation{"GetCoffee", []string{"0000"}},
+		))
+		Expect(result.Results[1].Payload).To(ContainSubstring(`"id":"0000"`))
+		Expect(result.Results[3].Status).To(Equal(int32(http.StatusNotFound)))
+	})
+
+	It("Should fail atomic batches on the first failed operation", func() {
+		response := batch(BatchAtomic,
+			Operation{"CreateCoffee", []string{"mocha"}},
+			Operation{"UseCoffee", []string{"missing", "user"}},
+			Operation{"CreateCoffee", []string{"latte"}},
+		)
+		Expect(int(response.Status)).To(Equal(http.StatusNotFound))
+		Expect(response.Message).To(ContainSubstring("operation 1 (UseCoffee) failed"))
+
+		coffees, err := st.AllCoffee(false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(coffees).To(HaveLen(1))
+	})
+
+	It("Should discard only failed operations of best-effort batches", func() {
+		result := batchResult(batch(BatchBestEffort,
+			Operation{"CreateCoffee", []string{"mocha"}},
+			Operation{"UseCoffee", []string{"missing", "user"}},
+			Operation{"NoSuchFunction", nil},
+			Operation{"CreateCoffee", []string{"latte"}},
+		))
+		Expect(result.Succeeded).To(Equal(2))
+		Expect(result.Failed).To(Equal(2))
+		Expect(result.Results[1].Status).To(Equal(int32(http.StatusNotFound)))
+		Expect(result.Results[2].Message).To(ContainSubstring("not found"))
+
+		coffees, err := st.AllCoffee(false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(coffees).To(HaveLen(3))
+	})
+
+	It("Should enforce the permissions of each operation", func() {
+		result := batchResult(batch(BatchBestEffort,
+			Operation{"RestoreCoffee", []string{"0000"}},
+		))
+		Expect(result.Results[0].Message).To(ContainSubstring("Permission denied"))
+	})
+
+	It("Should fail operations of best-effort batches invoking other chaincodes", func() {
+		users := mockstub.NewStub("user", NewUserChaincode(logger))
+		users.MockPeerChaincode("coffee", mock)
+		createTestUser(users, store.NewUserStore(users, logger), model.NewUser("0001", 0))
+
+		coffee := model.NewCoffee("0002", "mocha")
+		Expect(coffee.SetOwner("0001")).To(Succeed())
+		createTestCoffee(mock, st, coffee)
+
+		Expect(users.SetCreator("Org1MSP", "admin", map[string]string{
+			AdminAttribute: "true",
+		})).To(Succeed())
+
+		data, err := json.Marshal([]Operation{{"DeleteUser", []string{"0001", "left", "true"}}})
+		Expect(err).NotTo(HaveOccurred())
+
+		result := batchResult(users.MockInvoke("0003", [][]byte{
+			[]byte("Batch"), data, []byte(BatchBestEffort),
+		}))
+		Expect(result.Failed).To(Equal(1))
+		Expect(result.Results[0].Message).To(ContainSubstring("can't invoke the coffee chaincode"))
+
+		_, err = st.GetCoffee("0002")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("Should reject invalid batches", func() {
+		Expect(int(batch("").Status)).To(Equal(shim.ERROR))
+		Expect(int(batch("sometimes", Operation{"GetCoffee", []string{"0000"}}).Status)).To(Equal(shim.ERROR))
+		Expect(int(mock.MockInvoke("0001", [][]byte{[]byte("Batch"), []byte("not json")}).Status)).To(Equal(shim.ERROR))
+
+		operations := make([]Operation, MaxBatchSize+1)
+		for i := range operations {
+			operations[i] = Operation{"GetCoffee", []string{"0000"}}
+		}
+		Expect(int(batch("", operations...).Status)).To(Equal(shim.ERROR))
+	})
+
+	It("Should only run batches if the batch feature is enabled", func() {
+		result := mock.MockInit("0000", [][]byte{[]byte("init"), []byte(`{"features":{"batch":false}}`)})
+		Expect(int(result.Status)).To(Equal(shim.OK))
+
+		response := batch("", Operation{"CreateCoffee", []string{"mocha"}})
+		Expect(int(response.Status)).To(Equal(shim.ERROR))
+		Expect(response.Message).To(ContainSubstring("the 'batch' feature isn't enabled"))
+	})
+
+	It("Should not nest batches", func() {
+		result := batchResult(batch(BatchBestEffort, Operation{"Batch", []string{"[]"}}))
+		Expect(result.Failed).To(Equal(1))
+		Expect(result.Results[0].Message).To(ContainSubstring("can't be nested"))
+	})
+
+	It("Should replay batches with a client request ID", func() {
+		args := [][]byte{[]byte("Batch"), []byte(`[{"function":"CreateCoffee","args":["mocha"]}]`)}
+		transient := map[string][]byte{RequestIDKey: []byte("req-1")}
+
+		first := mock.MockInvokeWithTransient("0001", args, transient)
+		Expect(int(first.Status)).To(Equal(shim.OK))
+		Expect(mock.MockInvokeWithTransient("0002", args, transient)).To(Equal(first))
+
+		coffees, err := st.AllCoffee(false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(coffees).To(HaveLen(2))
+	})
+})
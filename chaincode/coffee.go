@@ -1,62 +1,188 @@
 package chaincode
 
 import (
+	"fmt"
+
+	"github.com/cdtlab19/coffee-chaincode/logging"
 	"github.com/cdtlab19/coffee-chaincode/model"
 	"github.com/cdtlab19/coffee-chaincode/store"
-	"github.com/cdtlab19/coffee-chaincode/utils"
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 	pb "github.com/hyperledger/fabric/protos/peer"
 	"github.com/vtfr/rocha"
-	"github.com/vtfr/rocha/argsmw"
 )
 
 // CoffeeChaincode is a chaincode for controller coffee assets
 type CoffeeChaincode struct {
-	logger *shim.ChaincodeLogger
-	router *rocha.Router
+	configurable
+	logger   *logging.Logger
+	router   *rocha.Router
+	newStore CoffeeStoreFactory
+}
+
+// CoffeeStoreFactory creates the coffee repository used by a transaction
+type CoffeeStoreFactory func(stub shim.ChaincodeStubInterface, logger store.Logger) store.CoffeeRepository
+
+// CoffeeOption configures a CoffeeChaincode
+type CoffeeOption func(cc *CoffeeChaincode)
+
+// WithCoffeeStore replaces the factory of coffee repositories, allowing
+// fakes to be used in tests
+func WithCoffeeStore(factory CoffeeStoreFactory) CoffeeOption {
+	return func(cc *CoffeeChaincode) {
+		cc.newStore = factory
+	}
 }
 
 // Checagem em tempo de compilação se CoffeeChaincode implementa shim.CoffeeChaincode
 var _ shim.Chaincode = &CoffeeChaincode{}
 
 // NewCoffeeChaincode cria uma nova instância do CoffeeChaincode para gerenciamento de
-// cafés com os parâmetros default
-func NewCoffeeChaincode(logger *shim.ChaincodeLogger) *CoffeeChaincode {
-	chaincode := &CoffeeChaincode{logger: logger}
-	chaincode.router = rocha.NewRouter().
-		// CreateCoffee creates a new coffee with `flavour`
-		Handle("CreateCoffee",
-			utils.RespondJSON(chaincode.CreateCoffee),
-			argsmw.Arguments(argsmw.String("flavour"))).
-		// UseCoffee sets a coffee's owner to `user`
-		Handle("UseCoffee", utils.RespondJSON(chaincode.UseCoffee),
-			argsmw.Arguments(
-				argsmw.String("id"),
-				argsmw.String("user"))).
-		Handle("GetCoffee", utils.RespondJSON(chaincode.GetCoffee),
-			argsmw.Arguments(argsmw.String("id"))).
-		// AllCoffee returns all coffees
-		Handle("AllCoffee", utils.RespondJSON(chaincode.AllCoffee)).
-		// DeleteCoffee deletes a coffe by it's `id`
-		Handle("DeleteCoffee", utils.RespondJSON(chaincode.DeleteCoffee),
-			argsmw.Arguments(argsmw.String("id")))
+// cafés com os parâmetros default, alterados pelas opções recebidas
+func NewCoffeeChaincode(logger *logging.Logger, options ...CoffeeOption) *CoffeeChaincode {
+	chaincode := &CoffeeChaincode{
+		configurable: configurable{logger: logger},
+		logger:       logger,
+		newStore: func(stub shim.ChaincodeStubInterface, logger store.Logger) store.CoffeeRepository {
+			return store.NewCoffeeStore(stub, logger)
+		},
+	}
+
+	for _, option := range options {
+		option(chaincode)
+	}
+
+	fns := newFunctions(&chaincode.configurable, "coffee")
+	fns.
+		respond(Function{
+			Name:        "CreateCoffee",
+			Description: "Creates a new coffee with `flavour`",
+			Args:        []Argument{stringArg("flavour", "coffee's flavour")},
+			Idempotent:  true,
+			Response:    object("coffee", model.Coffee{}),
+		}, chaincode.CreateCoffee).
+		respond(Function{
+			Name:        "UseCoffee",
+			Description: "Sets a coffee's owner to `user`, if it's still at the optional `revision`",
+			Args: []Argument{
+				stringArg("id", "coffee's ID"),
+				stringArg("user", "ID of the user who used the coffee"),
+				intArg("revision", "revision the client read, to detect concurrent changes").optional(nil),
+			},
+			Idempotent: true,
+		}, chaincode.UseCoffee).
+		respond(Function{
+			Name:        "GetCoffee",
+			Description: "Returns a coffee by it's `id`",
+			Args:        []Argument{stringArg("id", "coffee's ID")},
+			Response:    object("coffee", model.Coffee{}),
+		}, chaincode.GetCoffee).
+		respond(Function{
+			Name:        "CoffeeByOwner",
+			Description: "Returns the coffees used by an user",
+			Args:        []Argument{stringArg("owner", "user's ID")},
+			Response:    object("coffees", []model.Coffee{}),
+		}, chaincode.CoffeeByOwner).
+		respond(Function{
+			Name:        "CoffeeByFlavour",
+			Description: "Returns the coffees of a flavour",
+			Args:        []Argument{stringArg("flavour", "coffee's flavour")},
+			Response:    object("coffees", []model.Coffee{}),
+		}, chaincode.CoffeeByFlavour).
+		respond(Function{
+			Name:        "CoffeeByState",
+			Description: "Returns the available, used or deleted coffees",
+			Args:        []Argument{stringArg("state", "available, used or deleted")},
+			Response:    object("coffees", []model.Coffee{}),
+		}, chaincode.CoffeeByState).
+		respond(Function{
+			Name:        "AllCoffee",
+			Description: "Returns all coffees, including deleted ones if `includeDeleted` is set",
+			Args:        []Argument{boolArg("includeDeleted", "include deleted coffees").optional(false)},
+			Response:    object("coffees", []model.Coffee{}),
+		}, chaincode.AllCoffee).
+		respond(Function{
+			Name:        "DeleteCoffee",
+			Description: "Deletes a coffee by it's `id`, for an optional `reason`",
+			Args: []Argument{
+				stringArg("id", "coffee's ID"),
+				stringArg("reason", "why the coffee is deleted").optional(""),
+			},
+			Idempotent: true,
+		}, chaincode.DeleteCoffee).
+		respond(Function{
+			Name:        "RestoreCoffee",
+			Description: "Restores a deleted coffee by it's `id`",
+			Args:        []Argument{stringArg("id", "coffee's ID")},
+			Role:        RoleAdmin,
+			Response:    object("coffee", model.Coffee{}),
+		}, chaincode.RestoreCoffee).
+		respond(Function{
+			Name:        "PurgeCoffee",
+			Description: "Erases a coffee deleted for longer than the configured retention",
+			Args:        []Argument{stringArg("id", "coffee's ID")},
+			Role:        RoleAdmin,
+		}, chaincode.PurgeCoffee).
+		respond(Function{
+			Name:        "VerifyIndexes",
+			Description: "Reports missing and stale entries of the coffee indexes",
+			Role:        RoleAdmin,
+			Response:    schemaOf(store.IndexReport{}),
+		}, chaincode.VerifyIndexes).
+		respond(Function{
+			Name:        "RebuildIndexes",
+			Description: "Fixes missing and stale entries of the coffee indexes",
+			Role:        RoleAdmin,
+			Response:    schemaOf(store.IndexReport{}),
+		}, chaincode.RebuildIndexes).
+		respond(Function{
+			Name: "PendingMigrations",
+			Description: "Lists the IDs of a page of coffees stored with older schema versions, " +
+				"starting at the optional `bookmark`. It must be called as a query",
+			Args:     pageArgs(),
+			Role:     RoleAdmin,
+			Envelope: true,
+			Response: schemaOf(store.ScanResult{}),
+		}, chaincode.PendingMigrations).
+		respond(Function{
+			Name:        "Migrate",
+			Description: "Rewrites the coffees with the `ids` listed by PendingMigrations to the current schema version",
+			Args:        []Argument{jsonArg("ids", "JSON array of coffee IDs", &[]string{})},
+			Role:        RoleAdmin,
+			Envelope:    true,
+			Response:    schemaOf(store.RewriteResult{}),
+		}, chaincode.Migrate).
+		respond(expiredRequestsFunction, chaincode.ExpiredRequests).
+		respond(expireRequestsFunction, chaincode.ExpireRequests).
+		respond(batchFunction, chaincode.Batch).
+		handle(exportFunction, chaincode.Export).
+		respond(importFunction, chaincode.Import).
+		respond(getConfigFunction, chaincode.GetConfig).
+		respond(updateConfigFunction, chaincode.UpdateConfig).
+		respond(describeFunction, fns.Describe)
+	chaincode.router = fns.router
 
 	return chaincode
 }
 
-// Init realiza as operações de inicialização do CoffeeChaincode
+// Init realiza as operações de inicialização do CoffeeChaincode, armazenando a
+// configuração recebida
 func (cc *CoffeeChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
-	return shim.Success(nil)
+	return cc.initConfig(stub)
 }
 
 // Invoke é chamado toda vez que o Chaicode é invocado
 func (cc *CoffeeChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	stub, err := invocationStub(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
 	fn, args := stub.GetFunctionAndParameters()
 	return cc.router.Invoke(stub, fn, args)
 }
 
-func (cc *CoffeeChaincode) store(stub shim.ChaincodeStubInterface) *store.CoffeeStore {
-	return store.NewCoffeeStore(stub, cc.logger)
+func (cc *CoffeeChaincode) store(stub shim.ChaincodeStubInterface) store.CoffeeRepository {
+	return cc.newStore(stub, cc.logger.For(stub))
 }
 
 // CreateCoffee cria um novo café
@@ -65,7 +191,7 @@ func (cc *CoffeeChaincode) CreateCoffee(c rocha.Context) (interface{}, error) {
 
 	coffee := model.NewCoffee(stub.GetTxID(), c.String("flavour"))
 
-	if err := cc.store(stub).SetCoffee(coffee); err != nil {
+	if err := cc.store(stub).CreateCoffee(coffee); err != nil {
 		return nil, err
 	}
 
@@ -84,11 +210,15 @@ func (cc *CoffeeChaincode) UseCoffee(c rocha.Context) (interface{}, error) {
 		return nil, err
 	}
 
+	if err := expectRevision(c, model.CoffeeDocType, coffee.ID, coffee.Revision); err != nil {
+		return nil, err
+	}
+
 	if err := coffee.SetOwner(c.String("user")); err != nil {
 		return nil, err
 	}
 
-	return nil, st.SetCoffee(coffee)
+	return nil, st.UpdateCoffee(coffee)
 }
 
 // GetCoffee retorna um café
@@ -103,9 +233,8 @@ func (cc *CoffeeChaincode) GetCoffee(c rocha.Context) (interface{}, error) {
 	}{coffee}, nil
 }
 
-// AllCoffee retorna todos os cafés
-func (cc *CoffeeChaincode) AllCoffee(c rocha.Context) (interface{}, error) {
-	coffees, err := cc.store(c.Stub()).AllCoffee()
+// coffees responde uma lista de cafés
+func coffees(coffees []*model.Coffee, err error) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
@@ -115,7 +244,107 @@ func (cc *CoffeeChaincode) AllCoffee(c rocha.Context) (interface{}, error) {
 	}{coffees}, nil
 }
 
-// DeleteCoffee retorna todos os cafés
+// CoffeeByOwner retorna os cafés usados por um usuário
+func (cc *CoffeeChaincode) CoffeeByOwner(c rocha.Context) (interface{}, error) {
+	return coffees(cc.store(c.Stub()).CoffeeByOwner(c.String("owner")))
+}
+
+// CoffeeByFlavour retorna os cafés de um sabor
+func (cc *CoffeeChaincode) CoffeeByFlavour(c rocha.Context) (interface{}, error) {
+	return coffees(cc.store(c.Stub()).CoffeeByFlavour(c.String("flavour")))
+}
+
+// CoffeeByState retorna os cafés disponíveis, usados ou deletados
+func (cc *CoffeeChaincode) CoffeeByState(c rocha.Context) (interface{}, error) {
+	switch state := c.String("state"); state {
+	case model.CoffeeAvailable, model.CoffeeUsed, model.CoffeeDeleted:
+		return coffees(cc.store(c.Stub()).CoffeeByState(state))
+	default:
+		return nil, fmt.Errorf("invalid state '%s'", state)
+	}
+}
+
+// VerifyIndexes verifica a consistência dos índices de cafés
+func (cc *CoffeeChaincode) VerifyIndexes(c rocha.Context) (interface{}, error) {
+	return cc.store(c.Stub()).VerifyCoffeeIndexes(false)
+}
+
+// RebuildIndexes corrige as entradas faltando ou inconsistentes dos índices
+// de cafés
+func (cc *CoffeeChaincode) RebuildIndexes(c rocha.Context) (interface{}, error) {
+	return cc.store(c.Stub()).VerifyCoffeeIndexes(true)
+}
+
+// AllCoffee retorna todos os cafés
+func (cc *CoffeeChaincode) AllCoffee(c rocha.Context) (interface{}, error) {
+	includeDeleted, _ := c.Get("includeDeleted")
+	return coffees(cc.store(c.Stub()).AllCoffee(includeDeleted == true))
+}
+
+// DeleteCoffee marca um café como deletado, registrando quem o deletou,
+// quando e por quê
 func (cc *CoffeeChaincode) DeleteCoffee(c rocha.Context) (interface{}, error) {
-	return nil, cc.store(c.Stub()).DeleteCoffee(c.String("id"))
+	stub := c.Stub()
+
+	tombstone, err := tombstone(stub, c.String("reason"))
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, cc.store(stub).DeleteCoffee(c.String("id"), tombstone)
+}
+
+// RestoreCoffee restaura um café deletado
+func (cc *CoffeeChaincode) RestoreCoffee(c rocha.Context) (interface{}, error) {
+	st := cc.store(c.Stub())
+	if err := st.RestoreCoffee(c.String("id")); err != nil {
+		return nil, err
+	}
+
+	coffee, err := st.GetCoffee(c.String("id"))
+	if err != nil {
+		return nil, err
+	}
+
+	return struct {
+		Coffee *model.Coffee `json:"coffee"`
+	}{coffee}, nil
+}
+
+// PurgeCoffee apaga definitivamente um café deletado há mais tempo que a
+// retenção configurada
+func (cc *CoffeeChaincode) PurgeCoffee(c rocha.Context) (interface{}, error) {
+	stub := c.Stub()
+
+	retention, now, err := cc.retention(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, cc.store(stub).PurgeCoffee(c.String("id"), retention, now)
+}
+
+// Batch executa várias operações do chaincode em uma única transação
+func (cc *CoffeeChaincode) Batch(c rocha.Context) (interface{}, error) {
+	return runBatch(c, cc.router.Invoke)
+}
+
+// Export exporta uma página do estado do chaincode
+func (cc *CoffeeChaincode) Export(c rocha.Context) pb.Response {
+	return exportSnapshot(c, store.NewCoffeeSnapshot(c.Stub(), cc.logger.For(c.Stub())))
+}
+
+// Import importa uma página exportada por Export
+func (cc *CoffeeChaincode) Import(c rocha.Context) (interface{}, error) {
+	return importSnapshot(c, store.NewCoffeeSnapshot(c.Stub(), cc.logger.For(c.Stub())))
+}
+
+// PendingMigrations lista uma página de cafés armazenados em versões antigas
+func (cc *CoffeeChaincode) PendingMigrations(c rocha.Context) (interface{}, error) {
+	return cc.store(c.Stub()).PendingCoffeeMigrations(c.Int("pageSize"), c.String("bookmark"))
+}
+
+// Migrate atualiza os cafés listados por PendingMigrations
+func (cc *CoffeeChaincode) Migrate(c rocha.Context) (interface{}, error) {
+	return cc.store(c.Stub()).MigrateCoffee(*c.Value("ids").(*[]string))
 }
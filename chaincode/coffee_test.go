@@ -2,25 +2,29 @@ package chaincode_test
 
 import (
 	"encoding/json"
+	"net/http"
 
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	. "github.com/cdtlab19/coffee-chaincode/chaincode"
+	"github.com/cdtlab19/coffee-chaincode/logging"
+	"github.com/cdtlab19/coffee-chaincode/mockstub"
 	"github.com/cdtlab19/coffee-chaincode/model"
 	"github.com/cdtlab19/coffee-chaincode/store"
 )
 
 var _ = Describe("Coffee", func() {
-	var mock *shim.MockStub
-	var logger *shim.ChaincodeLogger
+	var mock *mockstub.Stub
+	var logger *logging.Logger
 	var st *store.CoffeeStore
 
 	BeforeEach(func() {
-		logger = shim.NewLogger("coffee-test")
-		mock = shim.NewMockStub("coffee", NewCoffeeChaincode(logger))
+		logger = logging.New("coffee-test")
+		mock = mockstub.NewStub("coffee", NewCoffeeChaincode(logger))
 		st = store.NewCoffeeStore(mock, logger)
+		Expect(mock.SetCreator("Org1MSP", "someone", nil)).To(Succeed())
 	})
 
 	It("Should Init", func() {
@@ -52,6 +56,28 @@ var _ = Describe("Coffee", func() {
 			Expect(res.Coffees).To(ContainElement(coffee1))
 			Expect(res.Coffees).To(ContainElement(coffee2))
 		})
+
+		It("Should skip invalid stored coffees", func() {
+			createTestCoffee(mock, st, model.NewCoffee("0000", "cappuccino"))
+
+			// stored by an older version, without validation
+			key, _ := mock.CreateCompositeKey(model.CoffeeDocType, []string{"0001"})
+			mock.MockTransactionStart("raw")
+			mock.PutState(key, []byte(`{"docType":"coffee","schemaVersion":1,"id":"0001","flavour":"mocha!"}`))
+			mock.MockTransactionEnd("raw")
+
+			result := mock.MockInvoke("0000", [][]byte{
+				[]byte("AllCoffee"),
+			})
+			Expect(int(result.Status)).To(Equal(shim.OK), result.Message)
+
+			var res struct {
+				Coffees []*model.Coffee `json:"coffees"`
+			}
+			Expect(json.Unmarshal(result.Payload, &res)).To(Succeed())
+			Expect(res.Coffees).To(HaveLen(1))
+			Expect(res.Coffees[0].ID).To(Equal("0000"))
+		})
 	})
 
 	It("Should CreateCoffee", func() {
@@ -84,6 +110,71 @@ var _ = Describe("Coffee", func() {
 
 	})
 
+	It("Should not CreateCoffee twice with the same ID", func() {
+		createTestCoffee(mock, st, model.NewCoffee("0000", "cappuccino"))
+
+		result := mock.MockInvoke("0000", [][]byte{
+			[]byte("CreateCoffee"),
+			[]byte("chocolate"),
+		})
+		Expect(int(result.Status)).To(Equal(http.StatusConflict))
+
+		coffee, err := st.GetCoffee("0000")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(coffee.Flavour).To(Equal("cappuccino"))
+	})
+
+	Context("CreateCoffee validation", func() {
+		invalidFlavours := map[string]string{
+			"empty":         "",
+			"digits":        "cappuccino2",
+			"symbols":       "cappuccino!",
+			"too long":      "cappuccinocappuccinocappuccinoxyz",
+			"leading space": " cappuccino",
+		}
+
+		for name, flavour := range invalidFlavours {
+			flavour := flavour
+			It("Should reject a flavour: "+name, func() {
+				result := mock.MockInvoke("0000", [][]byte{
+					[]byte("CreateCoffee"),
+					[]byte(flavour),
+				})
+				Expect(int(result.Status)).To(Equal(shim.ERROR))
+				Expect(result.Message).To(ContainSubstring("flavour"))
+
+				_, err := st.GetCoffee("0000")
+				Expect(err).To(HaveOccurred())
+			})
+		}
+	})
+
+	Context("UseCoffee revision", func() {
+		It("Should not use a coffee changed since the expected revision", func() {
+			createTestCoffee(mock, st, model.NewCoffee("0000", "cappuccino"))
+
+			result := mock.MockInvoke("0000", [][]byte{
+				[]byte("UseCoffee"),
+				[]byte("0000"),
+				[]byte("test-owner"),
+				[]byte("0"),
+			})
+			Expect(int(result.Status)).To(Equal(http.StatusConflict))
+
+			result = mock.MockInvoke("0001", [][]byte{
+				[]byte("UseCoffee"),
+				[]byte("0000"),
+				[]byte("test-owner"),
+				[]byte("1"),
+			})
+			Expect(int(result.Status)).To(Equal(shim.OK))
+
+			coffee, err := st.GetCoffee("0000")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(coffee.Revision).To(Equal(2))
+		})
+	})
+
 	Context("GetCoffee Method", func() {
 		const method = "GetCoffee"
 
@@ -92,10 +183,24 @@ var _ = Describe("Coffee", func() {
 				[]byte(method),
 				[]byte("0000"),
 			})
-			Expect(int(result.Status)).To(Equal(shim.ERROR))
+			Expect(int(result.Status)).To(Equal(http.StatusNotFound))
 			Expect(result.Payload).To(BeEmpty())
 		})
 
+		It("Should return error if the stored coffee is invalid", func() {
+			key, _ := mock.CreateCompositeKey(model.CoffeeDocType, []string{"0000"})
+			mock.MockTransactionStart("raw")
+			mock.PutState(key, []byte(`{"docType":"coffee","schemaVersion":1,"id":"0000","flavour":"!"}`))
+			mock.MockTransactionEnd("raw")
+
+			result := mock.MockInvoke("0000", [][]byte{
+				[]byte(method),
+				[]byte("0000"),
+			})
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+			Expect(result.Message).To(ContainSubstring("flavour"))
+		})
+
 		It("Should return a valid coffee if it exists", func() {
 			createTestCoffee(mock, st, model.NewCoffee("0000", "cappuccino"))
 
@@ -136,6 +241,31 @@ var _ = Describe("Coffee", func() {
 			Expect(result.Payload).To(BeEmpty())
 		})
 
+		It("Should return NotFound if the coffee doesn't exist", func() {
+			result := mock.MockInvoke("0000", [][]byte{
+				[]byte("UseCoffee"),
+				[]byte("0000"),
+				[]byte("test-owner"),
+			})
+
+			Expect(int(result.Status)).To(Equal(http.StatusNotFound))
+			_, err := st.GetCoffee("0000")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Should reject an invalid user", func() {
+			createTestCoffee(mock, st, model.NewCoffee("0000", "cappuccino"))
+
+			result := mock.MockInvoke("0000", [][]byte{
+				[]byte("UseCoffee"),
+				[]byte("0000"),
+				[]byte("test owner!"),
+			})
+
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+			Expect(result.Message).To(ContainSubstring("owner"))
+		})
+
 		It("Should execute successfuly", func() {
 			// create asset for testing
 			createTestCoffee(mock, st, model.NewCoffee("0000", "cappuccino"))
@@ -161,6 +291,15 @@ var _ = Describe("Coffee", func() {
 	Context("DeleteCoffee", func() {
 		const method = "DeleteCoffee"
 
+		It("Should return NotFound if the coffee doesn't exist", func() {
+			result := mock.MockInvoke("0000", [][]byte{
+				[]byte(method),
+				[]byte("0000"),
+			})
+
+			Expect(int(result.Status)).To(Equal(http.StatusNotFound))
+		})
+
 		It("Should execute successfuly", func() {
 			createTestCoffee(mock, st, model.NewCoffee("0000", "cappuccino"))
 
@@ -178,10 +317,214 @@ var _ = Describe("Coffee", func() {
 			_, err := st.GetCoffee("0000")
 			Expect(err).To(HaveOccurred())
 		})
+
+		It("Should keep a tombstone of the deleted coffee", func() {
+			createTestCoffee(mock, st, model.NewCoffee("0000", "cappuccino"))
+
+			result := mock.MockInvoke("0000", [][]byte{
+				[]byte(method),
+				[]byte("0000"),
+				[]byte("expired"),
+			})
+			Expect(int(result.Status)).To(Equal(shim.OK))
+
+			coffees, err := st.AllCoffee(true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(coffees).To(HaveLen(1))
+			Expect(coffees[0].Deleted).NotTo(BeNil())
+			Expect(coffees[0].Deleted.DeletedBy).To(Equal("Org1MSP/someone"))
+			Expect(coffees[0].Deleted.Reason).To(Equal("expired"))
+			Expect(coffees[0].Deleted.DeletedAt.IsZero()).To(BeFalse())
+		})
+
+		It("Should hide deleted coffees unless asked", func() {
+			createTestCoffee(mock, st, model.NewCoffee("0000", "cappuccino"))
+			createTestCoffee(mock, st, model.NewCoffee("0001", "chocolate"))
+			mock.MockInvoke("0000", [][]byte{[]byte(method), []byte("0000")})
+
+			allCoffee := func(args ...string) []*model.Coffee {
+				invokeArgs := [][]byte{[]byte("AllCoffee")}
+				for _, arg := range args {
+					invokeArgs = append(invokeArgs, []byte(arg))
+				}
+
+				result := mock.MockInvoke("0001", invokeArgs)
+				Expect(int(result.Status)).To(Equal(shim.OK))
+
+				var res struct {
+					Coffees []*model.Coffee `json:"coffees"`
+				}
+				Expect(json.Unmarshal(result.Payload, &res)).To(Succeed())
+				return res.Coffees
+			}
+
+			Expect(allCoffee()).To(HaveLen(1))
+			Expect(allCoffee("false")).To(HaveLen(1))
+			Expect(allCoffee("true")).To(HaveLen(2))
+		})
+	})
+
+	Context("Indexes", func() {
+		BeforeEach(func() {
+			used := model.NewCoffee("0000", "cappuccino")
+			Expect(used.SetOwner("alice")).To(Succeed())
+			createTestCoffee(mock, st, used)
+			createTestCoffee(mock, st, model.NewCoffee("0001", "chocolate"))
+		})
+
+		query := func(args ...string) []*model.Coffee {
+			invokeArgs := [][]byte{}
+			for _, arg := range args {
+				invokeArgs = append(invokeArgs, []byte(arg))
+			}
+
+			result := mock.MockInvoke("0002", invokeArgs)
+			Expect(int(result.Status)).To(Equal(shim.OK), result.Message)
+
+			var res struct {
+				Coffees []*model.Coffee `json:"coffees"`
+			}
+			Expect(json.Unmarshal(result.Payload, &res)).To(Succeed())
+			return res.Coffees
+		}
+
+		It("Should return coffees by owner", func() {
+			coffees := query("CoffeeByOwner", "alice")
+			Expect(coffees).To(HaveLen(1))
+			Expect(coffees[0].ID).To(Equal("0000"))
+		})
+
+		It("Should return coffees by flavour", func() {
+			coffees := query("CoffeeByFlavour", "chocolate")
+			Expect(coffees).To(HaveLen(1))
+			Expect(coffees[0].ID).To(Equal("0001"))
+		})
+
+		It("Should return coffees by state", func() {
+			Expect(query("CoffeeByState", model.CoffeeAvailable)).To(HaveLen(1))
+
+			result := mock.MockInvoke("0002", [][]byte{[]byte("CoffeeByState"), []byte("broken")})
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+		})
+
+		It("Should only let administrators verify and rebuild indexes", func() {
+			result := mock.MockInvoke("0002", [][]byte{[]byte("VerifyIndexes")})
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+
+			Expect(mock.SetCreator("Org1MSP", "admin", map[string]string{
+				AdminAttribute: "true",
+			})).To(Succeed())
+
+			for _, method := range []string{"VerifyIndexes", "RebuildIndexes"} {
+				result = mock.MockInvoke("0002", [][]byte{[]byte(method)})
+				Expect(int(result.Status)).To(Equal(shim.OK))
+
+				var report store.IndexReport
+				Expect(json.Unmarshal(result.Payload, &report)).To(Succeed())
+				Expect(report.Checked).To(Equal(2))
+				Expect(report.Consistent()).To(BeTrue())
+			}
+		})
+	})
+
+	Context("Indexes of invalid coffees", func() {
+		BeforeEach(func() {
+			createTestCoffee(mock, st, model.NewCoffee("0000", "cappuccino"))
+			createTestCoffee(mock, st, model.NewCoffee("0001", "cappuccino"))
+
+			// changed to an invalid coffee, keeping it's index entries
+			key, _ := mock.CreateCompositeKey(model.CoffeeDocType, []string{"0001"})
+			mock.MockTransactionStart("raw")
+			mock.PutState(key, []byte(`{"docType":"coffee","schemaVersion":1,"id":"0001","flavour":"cappuccino","revision":-1}`))
+			mock.MockTransactionEnd("raw")
+		})
+
+		It("Should skip invalid coffees in queries", func() {
+			result := mock.MockInvoke("0002", [][]byte{[]byte("CoffeeByFlavour"), []byte("cappuccino")})
+			Expect(int(result.Status)).To(Equal(shim.OK), result.Message)
+
+			var res struct {
+				Coffees []*model.Coffee `json:"coffees"`
+			}
+			Expect(json.Unmarshal(result.Payload, &res)).To(Succeed())
+			Expect(res.Coffees).To(HaveLen(1))
+			Expect(res.Coffees[0].ID).To(Equal("0000"))
+		})
+
+		It("Should keep the index entries of invalid coffees", func() {
+			Expect(mock.SetCreator("Org1MSP", "admin", map[string]string{
+				AdminAttribute: "true",
+			})).To(Succeed())
+
+			result := mock.MockInvoke("0002", [][]byte{[]byte("RebuildIndexes")})
+			Expect(int(result.Status)).To(Equal(shim.OK), result.Message)
+
+			var report store.IndexReport
+			Expect(json.Unmarshal(result.Payload, &report)).To(Succeed())
+			Expect(report.Checked).To(Equal(2))
+			Expect(report.Consistent()).To(BeTrue())
+		})
+	})
+
+	Context("RestoreCoffee", func() {
+		const method = "RestoreCoffee"
+
+		BeforeEach(func() {
+			createTestCoffee(mock, st, model.NewCoffee("0000", "cappuccino"))
+			mock.MockInvoke("0000", [][]byte{[]byte("DeleteCoffee"), []byte("0000")})
+		})
+
+		It("Should only be called by administrators", func() {
+			result := mock.MockInvoke("0001", [][]byte{[]byte(method), []byte("0000")})
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+		})
+
+		It("Should restore a deleted coffee", func() {
+			Expect(mock.SetCreator("Org1MSP", "admin", map[string]string{
+				AdminAttribute: "true",
+			})).To(Succeed())
+
+			result := mock.MockInvoke("0001", [][]byte{[]byte(method), []byte("0000")})
+			Expect(int(result.Status)).To(Equal(shim.OK))
+
+			coffee, err := st.GetCoffee("0000")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(coffee.IsDeleted()).To(BeFalse())
+		})
+	})
+
+	Context("PurgeCoffee", func() {
+		const method = "PurgeCoffee"
+
+		BeforeEach(func() {
+			createTestCoffee(mock, st, model.NewCoffee("0000", "cappuccino"))
+			mock.MockInvoke("0000", [][]byte{[]byte("DeleteCoffee"), []byte("0000")})
+
+			Expect(mock.SetCreator("Org1MSP", "admin", map[string]string{
+				AdminAttribute: "true",
+			})).To(Succeed())
+		})
+
+		It("Should not purge coffees before the retention period", func() {
+			result := mock.MockInvoke("0001", [][]byte{[]byte(method), []byte("0000")})
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+			Expect(result.Message).To(ContainSubstring("can't be purged before"))
+		})
+
+		It("Should purge coffees after the retention period", func() {
+			mock.MockInit("init", [][]byte{[]byte("init"), []byte(`{"retentionDays":0}`)})
+
+			result := mock.MockInvoke("0001", [][]byte{[]byte(method), []byte("0000")})
+			Expect(int(result.Status)).To(Equal(shim.OK))
+
+			coffees, err := st.AllCoffee(true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(coffees).To(BeEmpty())
+		})
 	})
 })
 
-func createTestCoffee(mock *shim.MockStub, st *store.CoffeeStore, coffee *model.Coffee) {
+func createTestCoffee(mock *mockstub.Stub, st *store.CoffeeStore, coffee *model.Coffee) {
 	mock.MockTransactionStart("int")
 	defer mock.MockTransactionEnd("int")
 
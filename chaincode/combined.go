@@ -0,0 +1,147 @@
+package chaincode
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cdtlab19/coffee-chaincode/logging"
+	"github.com/cdtlab19/coffee-chaincode/store"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/vtfr/rocha"
+)
+
+// Namespaces of the functions of the combined chaincode
+const (
+	CoffeeNamespace = "coffee"
+	UserNamespace   = "user"
+)
+
+// CombinedChaincode deploys the coffee and user chaincodes in a single
+// namespace, so transactions changing both assets are atomic. It's functions
+// are namespaced, such as `coffee:CreateCoffee` and `user:DrinkCoffee`.
+// Both chaincodes share the configuration and the client request IDs, so
+// batches and snapshots of both namespaces have their own functions, which
+// aren't namespaced
+type CombinedChaincode struct {
+	configurable
+	logger  *logging.Logger
+	router  *rocha.Router
+	routers map[string]*rocha.Router
+}
+
+var _ shim.Chaincode = &CombinedChaincode{}
+
+// NewCombinedChaincode cria uma nova instância do CombinedChaincode, com os
+// chaincodes de cafés e usuários no mesmo namespace
+func NewCombinedChaincode(logger *logging.Logger) *CombinedChaincode {
+	coffee := NewCoffeeChaincode(logger)
+	user := NewUserChaincode(logger)
+	user.coffeeRouter = coffee.router
+
+	chaincode := &CombinedChaincode{
+		configurable: configurable{logger: logger},
+		logger:       logger,
+		routers: map[string]*rocha.Router{
+			CoffeeNamespace: coffee.router,
+			UserNamespace:   user.router,
+		},
+	}
+
+	fns := newFunctions(&chaincode.configurable, "combined")
+	fns.
+		respond(batchFunction, chaincode.Batch).
+		handle(exportFunction, chaincode.Export).
+		respond(importFunction, chaincode.Import).
+		respond(describeFunction, fns.Describe)
+	chaincode.router = fns.router.NotFoundHandler(chaincode.notNamespaced)
+
+	return chaincode
+}
+
+// Init realiza as operações de inicialização do CombinedChaincode,
+// armazenando a configuração compartilhada pelos dois chaincodes
+func (cc *CombinedChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	return cc.initConfig(stub)
+}
+
+// Invoke encaminha a função recebida ao chaincode do seu namespace
+func (cc *CombinedChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	stub, err := invocationStub(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	fn, args := stub.GetFunctionAndParameters()
+	if !strings.Contains(fn, ":") {
+		return cc.router.Invoke(stub, fn, args)
+	}
+	return cc.invoke(stub, fn, args)
+}
+
+// invoke routes a namespaced function to it's chaincode
+func (cc *CombinedChaincode) invoke(stub shim.ChaincodeStubInterface, fn string, args []string) pb.Response {
+	parts := strings.SplitN(fn, ":", 2)
+	router, ok := cc.routers[parts[0]]
+	if len(parts) != 2 || !ok {
+		return cc.notNamespaced(rocha.NewContext(stub, fn, args))
+	}
+
+	return router.Invoke(stub, parts[1], args)
+}
+
+// notNamespaced fails functions which are neither namespaced nor shared by
+// both namespaces
+func (cc *CombinedChaincode) notNamespaced(c rocha.Context) pb.Response {
+	return shim.Error(fmt.Sprintf("function '%s' must be namespaced as '%s:<function>' or '%s:<function>'",
+		c.Method(), CoffeeNamespace, UserNamespace))
+}
+
+// Batch executa operações de ambos os namespaces, como `coffee:UseCoffee` e
+// `user:DrinkCoffee`, em uma única transação
+func (cc *CombinedChaincode) Batch(c rocha.Context) (interface{}, error) {
+	return runBatch(c, cc.invoke)
+}
+
+// Export exporta uma página do estado de ambos os namespaces
+func (cc *CombinedChaincode) Export(c rocha.Context) pb.Response {
+	return exportSnapshot(c, store.NewCombinedSnapshot(c.Stub(), cc.logger.For(c.Stub())))
+}
+
+// Import importa uma página exportada por Export
+func (cc *CombinedChaincode) Import(c rocha.Context) (interface{}, error) {
+	return importSnapshot(c, store.NewCombinedSnapshot(c.Stub(), cc.logger.For(c.Stub())))
+}
+
+// callStub is the stub of a function called by another in the same
+// transaction, with it's own function and arguments. The client request ID
+// identifies the calling function, so it's removed from the transient map
+type callStub struct {
+	shim.ChaincodeStubInterface
+	function string
+	args     []string
+}
+
+func newCallStub(stub shim.ChaincodeStubInterface, function string, args []string) *callStub {
+	return &callStub{stub, function, args}
+}
+
+func (s *callStub) GetFunctionAndParameters() (string, []string) {
+	return s.function, s.args
+}
+
+func (s *callStub) GetStringArgs() []string {
+	return append([]string{s.function}, s.args...)
+}
+
+func (s *callStub) GetArgs() [][]byte {
+	args := [][]byte{}
+	for _, arg := range s.GetStringArgs() {
+		args = append(args, []byte(arg))
+	}
+	return args
+}
+
+func (s *callStub) GetTransient() (map[string][]byte, error) {
+	return withoutRequestID(s.ChaincodeStubInterface)
+}
@@ -0,0 +1,217 @@
+package chaincode_test
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cdtlab19/coffee-chaincode/chaincode"
+	"github.com/cdtlab19/coffee-chaincode/logging"
+	"github.com/cdtlab19/coffee-chaincode/mockstub"
+	"github.com/cdtlab19/coffee-chaincode/model"
+	"github.com/cdtlab19/coffee-chaincode/store"
+)
+
+var _ = Describe("Combined", func() {
+	var mock *mockstub.Stub
+	var logger *logging.Logger
+	var coffees *store.CoffeeStore
+	var users *store.UserStore
+
+	BeforeEach(func() {
+		logger = logging.New("combined-test")
+		mock = mockstub.NewStub("combined", NewCombinedChaincode(logger))
+		coffees = store.NewCoffeeStore(mock, logger)
+		users = store.NewUserStore(mock, logger)
+		Expect(mock.SetCreator("Org1MSP", "someone", nil)).To(Succeed())
+	})
+
+	It("Should Init", func() {
+		result := mock.MockInit("0000", [][]byte{[]byte("init"), []byte(`{"defaultCredits":5}`)})
+		Expect(int(result.Status)).To(Equal(shim.OK))
+	})
+
+	It("Should route namespaced functions to both chaincodes", func() {
+		createTestCoffee(mock, coffees, model.NewCoffee("0000", "cappuccino"))
+		createTestUser(mock, users, model.NewUser("0001", 3))
+
+		result := mock.MockInvoke("0002", [][]byte{[]byte("coffee:UseCoffee"), []byte("0000"), []byte("0001")})
+		Expect(int(result.Status)).To(Equal(shim.OK))
+
+		result = mock.MockInvoke("0003", [][]byte{[]byte("user:DrinkCoffee"), []byte("0001")})
+		Expect(int(result.Status)).To(Equal(shim.OK))
+
+		coffee, err := coffees.GetCoffee("0000")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(coffee.Owner).To(Equal("0001"))
+
+		user, err := users.GetUser("0001")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(user.RemainingCoffee).To(Equal(2))
+	})
+
+	It("Should describe each namespace", func() {
+		result := mock.MockInvoke("0000", [][]byte{[]byte("user:Describe")})
+		Expect(int(result.Status)).To(Equal(shim.OK))
+
+		api := &API{}
+		Expect(json.Unmarshal(result.Payload, api)).To(Succeed())
+		Expect(api.Chaincode).To(Equal("user"))
+	})
+
+	It("Should reject functions without a known namespace", func() {
+		for _, fn := range []string{"GetCoffee", "tea:GetCoffee"} {
+			result := mock.MockInvoke("0000", [][]byte{[]byte(fn), []byte("0000")})
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+			Expect(result.Message).To(ContainSubstring("must be namespaced"))
+		}
+
+		result := mock.MockInvoke("0000", [][]byte{[]byte("coffee:DrinkCoffee"), []byte("0000")})
+		Expect(int(result.Status)).To(Equal(shim.ERROR))
+		Expect(result.Message).To(Equal("method 'DrinkCoffee' not found"))
+	})
+
+	It("Should close the coffees of deleted users in the same transaction", func() {
+		coffee := model.NewCoffee("0000", "cappuccino")
+		Expect(coffee.SetOwner("0001")).To(Succeed())
+		createTestCoffee(mock, coffees, coffee)
+		createTestUser(mock, users, model.NewUser("0001", 0))
+
+		Expect(mock.SetCreator("Org1MSP", "admin", map[string]string{
+			AdminAttribute: "true",
+		})).To(Succeed())
+
+		result := mock.MockInvokeWithTransient("0002", [][]byte{
+			[]byte("user:DeleteUser"), []byte("0001"), []byte("left"), []byte("true"),
+		}, map[string][]byte{RequestIDKey: []byte("request-1")})
+		Expect(int(result.Status)).To(Equal(shim.OK))
+
+		_, err := coffees.GetCoffee("0000")
+		Expect(store.IsNotFound(err)).To(BeTrue())
+
+		// the request is recorded for the namespaced function only
+		replay := mock.MockInvokeWithTransient("0003", [][]byte{
+			[]byte("user:DeleteUser"), []byte("0001"), []byte("left"), []byte("true"),
+		}, map[string][]byte{RequestIDKey: []byte("request-1")})
+		Expect(replay).To(Equal(result))
+	})
+
+	Context("Batch", func() {
+		BeforeEach(func() {
+			createTestCoffee(mock, coffees, model.NewCoffee("0000", "cappuccino"))
+			createTestUser(mock, users, model.NewUser("0001", 1))
+		})
+
+		batch := func(operations ...Operation) pb.Response {
+			data, err := json.Marshal(operations)
+			Expect(err).NotTo(HaveOccurred())
+			return mock.MockInvoke("0002", [][]byte{[]byte("Batch"), data})
+		}
+
+		It("Should run operations of both namespaces in one transaction", func() {
+			result := batch(
+				Operation{Function: "coffee:UseCoffee", Args: []string{"0000", "0001"}},
+				Operation{Function: "user:DrinkCoffee", Args: []string{"0001"}},
+			)
+			Expect(int(result.Status)).To(Equal(shim.OK), result.Message)
+
+			coffee, err := coffees.GetCoffee("0000")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(coffee.Owner).To(Equal("0001"))
+
+			user, err := users.GetUser("0001")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(user.RemainingCoffee).To(Equal(0))
+		})
+
+		It("Should discard the operations of both namespaces if one fails", func() {
+			result := batch(
+				Operation{Function: "coffee:UseCoffee", Args: []string{"0000", "0001"}},
+				Operation{Function: "user:DrinkCoffee", Args: []string{"0001"}},
+				Operation{Function: "user:DrinkCoffee", Args: []string{"0001"}},
+			)
+			Expect(int(result.Status)).NotTo(Equal(shim.OK))
+			Expect(result.Message).To(ContainSubstring("operation 2 (user:DrinkCoffee) failed"))
+
+			coffee, err := coffees.GetCoffee("0000")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(coffee.Owner).To(BeEmpty())
+
+			user, err := users.GetUser("0001")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(user.RemainingCoffee).To(Equal(1))
+		})
+
+		It("Should only run namespaced operations", func() {
+			for _, fn := range []string{"UseCoffee", "Batch", "coffee:Batch"} {
+				result := batch(Operation{Function: fn, Args: []string{"0000", "0001"}})
+				Expect(int(result.Status)).To(Equal(shim.ERROR))
+			}
+		})
+	})
+
+	Context("Snapshots", func() {
+		var target *mockstub.Stub
+
+		asAdmin := func(mock *mockstub.Stub) {
+			Expect(mock.SetCreator("Org1MSP", "admin", map[string]string{
+				AdminAttribute: "true",
+			})).To(Succeed())
+		}
+
+		BeforeEach(func() {
+			target = mockstub.NewStub("combined", NewCombinedChaincode(logger))
+			asAdmin(mock)
+			asAdmin(target)
+
+			mock.MockInit("0000", [][]byte{[]byte("init"), []byte(`{"defaultCredits":3}`)})
+			target.MockInit("0000", [][]byte{[]byte("init")})
+
+			info := model.NewUserInfo("0001", "someone", "someone@example.com", "")
+			user := model.NewUser("0001", 3)
+			user.SetInfo(info)
+			createTestUser(mock, users, user)
+			createTestUserInfo(mock, users, info)
+			createTestCoffee(mock, coffees, model.NewCoffee("0002", "mocha"))
+		})
+
+		It("Should export and import both namespaces", func() {
+			bookmark := ""
+			for {
+				result := mock.MockInvoke("0003", [][]byte{[]byte("Export"), []byte("2"), []byte(bookmark)})
+				Expect(int(result.Status)).To(Equal(shim.OK), result.Message)
+
+				var header store.SnapshotHeader
+				line := bytes.SplitN(result.Payload, []byte("\n"), 2)[0]
+				Expect(json.Unmarshal(line, &header)).To(Succeed())
+				Expect(header.Chaincode).To(Equal("combined"))
+
+				public, private, err := store.SplitSnapshot(result.Payload)
+				Expect(err).NotTo(HaveOccurred())
+
+				imported := target.MockInvokeWithTransient("0004", [][]byte{[]byte("Import"), public},
+					map[string][]byte{PrivateSnapshotKey: private})
+				Expect(int(imported.Status)).To(Equal(shim.OK), imported.Message)
+
+				if header.Done {
+					break
+				}
+				bookmark = header.Next
+			}
+
+			Expect(target.State).To(Equal(mock.State))
+			Expect(target.PvtState).To(Equal(mock.PvtState))
+		})
+
+		It("Should only be called by administrators", func() {
+			Expect(mock.SetCreator("Org1MSP", "someone", nil)).To(Succeed())
+
+			result := mock.MockInvoke("0003", [][]byte{[]byte("Export"), []byte("10")})
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+		})
+	})
+})
@@ -0,0 +1,138 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/cdtlab19/coffee-chaincode/logging"
+	"github.com/cdtlab19/coffee-chaincode/model"
+	"github.com/cdtlab19/coffee-chaincode/store"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/vtfr/rocha"
+)
+
+// configurable implements the configuration handlers shared by all chaincodes
+type configurable struct {
+	logger *logging.Logger
+
+	// level guards the revision of the configuration whose log level was
+	// applied, if any
+	level    sync.Mutex
+	applied  bool
+	revision int
+}
+
+func (cf *configurable) configStore(stub shim.ChaincodeStubInterface) *store.ConfigStore {
+	return store.NewConfigStore(stub, cf.logger.For(stub))
+}
+
+// config returns the current chaincode configuration
+func (cf *configurable) config(stub shim.ChaincodeStubInterface) (*model.Config, error) {
+	return cf.configStore(stub).GetConfig()
+}
+
+// initConfig stores the configuration received as the Init parameter, which
+// is merged with the current configuration, already migrated to the current
+// version by the store if the chaincode is being upgraded. Init may be called without parameters,
+// keeping the current or default configuration
+func (cf *configurable) initConfig(stub shim.ChaincodeStubInterface) pb.Response {
+	_, params := stub.GetFunctionAndParameters()
+	if len(params) > 1 {
+		return shim.Error("Invalid number of arguments. Expected at most 1")
+	}
+
+	st := cf.configStore(stub)
+
+	config, err := st.GetConfig()
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if len(params) == 1 {
+		if err := json.Unmarshal([]byte(params[0]), config); err != nil {
+			return shim.Error(fmt.Sprintf("Invalid config: %s", err.Error()))
+		}
+	}
+
+	if err := st.SetConfig(config); err != nil {
+		return shim.Error(fmt.Sprintf("Invalid config: %s", err.Error()))
+	}
+
+	return shim.Success(nil)
+}
+
+// featureEnabled is a middleware which only calls the next handler if a
+// feature is enabled in the configuration
+func (cf *configurable) featureEnabled(feature string) rocha.Middleware {
+	return func(next rocha.Handler) rocha.Handler {
+		return func(c rocha.Context) pb.Response {
+			config, err := cf.config(c.Stub())
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+			if !config.Enabled(feature) {
+				return shim.Error(fmt.Sprintf("%s is disabled: the '%s' feature isn't enabled", c.Method(), feature))
+			}
+			return next(c)
+		}
+	}
+}
+
+// GetConfig retorna a configuração do chaincode
+func (cf *configurable) GetConfig(c rocha.Context) (interface{}, error) {
+	config, err := cf.config(c.Stub())
+	if err != nil {
+		return nil, err
+	}
+
+	return struct {
+		Config *model.Config `json:"config"`
+	}{config}, nil
+}
+
+// UpdateConfig altera a configuração do chaincode. Apenas os campos enviados
+// são alterados
+func (cf *configurable) UpdateConfig(c rocha.Context) (interface{}, error) {
+	st := cf.configStore(c.Stub())
+
+	config, err := st.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := expectRevision(c, model.ConfigDocType, model.ConfigDocType, config.Revision); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(c.String("config")), config); err != nil {
+		return nil, err
+	}
+
+	if err := st.SetConfig(config); err != nil {
+		return nil, err
+	}
+
+	return struct {
+		Config *model.Config `json:"config"`
+	}{config}, nil
+}
+
+var getConfigFunction = Function{
+	Name:        "GetConfig",
+	Description: "Returns the chaincode configuration",
+	Role:        RoleAdmin,
+	Response:    object("config", model.Config{}),
+}
+
+var updateConfigFunction = Function{
+	Name:        "UpdateConfig",
+	Description: "Updates the chaincode configuration with a JSON object, if it's still at the optional `revision`",
+	Args: []Argument{
+		stringArg("config", "configuration fields to update").format(FormatJSON),
+		intArg("revision", "revision the client read, to detect concurrent changes").optional(nil),
+	},
+	Role:     RoleAdmin,
+	Response: object("config", model.Config{}),
+}
@@ -0,0 +1,291 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cdtlab19/coffee-chaincode/chaincode"
+	"github.com/cdtlab19/coffee-chaincode/logging"
+	"github.com/cdtlab19/coffee-chaincode/mockstub"
+	"github.com/cdtlab19/coffee-chaincode/model"
+	"github.com/cdtlab19/coffee-chaincode/store"
+)
+
+var _ = Describe("Config", func() {
+	var mock *mockstub.Stub
+	var logger *logging.Logger
+	var st *store.ConfigStore
+
+	BeforeEach(func() {
+		logger = logging.New("config-test")
+		mock = mockstub.NewStub("user", NewUserChaincode(logger))
+		st = store.NewConfigStore(mock, logger)
+		Expect(mock.SetCreator("Org1MSP", "admin", map[string]string{
+			AdminAttribute: "true",
+		})).To(Succeed())
+	})
+
+	getConfig := func() *model.Config {
+		mock.MockTransactionStart("get")
+		defer mock.MockTransactionEnd("get")
+
+		config, err := st.GetConfig()
+		Expect(err).NotTo(HaveOccurred())
+		return config
+	}
+
+	Context("Init", func() {
+		It("Should store the default config", func() {
+			result := mock.MockInit("0000", [][]byte{[]byte("init")})
+			Expect(int(result.Status)).To(Equal(shim.OK))
+
+			expected := model.NewConfig()
+			expected.Revision = 1
+			Expect(getConfig()).To(Equal(expected))
+		})
+
+		It("Should store the received config", func() {
+			result := mock.MockInit("0000", [][]byte{
+				[]byte("init"),
+				[]byte(`{"defaultCredits":5,"admins":[{"mspId":"Org1MSP","name":"admin"}],"features":{"feature":true}}`),
+			})
+			Expect(int(result.Status)).To(Equal(shim.OK))
+
+			config := getConfig()
+			Expect(config.DefaultCredits).To(Equal(5))
+			Expect(config.MaxCredits).To(Equal(model.NewConfig().MaxCredits))
+			Expect(config.IsAdmin("Org1MSP", "admin")).To(BeTrue())
+			Expect(config.Enabled("feature")).To(BeTrue())
+		})
+
+		It("Should reject invalid configs", func() {
+			result := mock.MockInit("0000", [][]byte{
+				[]byte("init"),
+				[]byte(`{"defaultCredits":-1}`),
+			})
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+
+			result = mock.MockInit("0000", [][]byte{
+				[]byte("init"),
+				[]byte(`not json`),
+			})
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+		})
+
+		It("Should keep the current config on upgrade", func() {
+			result := mock.MockInit("0000", [][]byte{
+				[]byte("init"),
+				[]byte(`{"defaultCredits":5}`),
+			})
+			Expect(int(result.Status)).To(Equal(shim.OK))
+
+			result = mock.MockInit("0001", [][]byte{
+				[]byte("upgrade"),
+				[]byte(`{"maxCredits":50}`),
+			})
+			Expect(int(result.Status)).To(Equal(shim.OK))
+
+			config := getConfig()
+			Expect(config.DefaultCredits).To(Equal(5))
+			Expect(config.MaxCredits).To(Equal(50))
+		})
+
+		It("Should migrate configs from older versions on upgrade", func() {
+			key, _ := mock.CreateCompositeKey(model.ConfigDocType, []string{})
+			mock.MockTransactionStart("old")
+			Expect(mock.PutState(key, []byte(`{"defaultCredits":3}`))).To(Succeed())
+			mock.MockTransactionEnd("old")
+
+			result := mock.MockInit("0000", [][]byte{[]byte("upgrade")})
+			Expect(int(result.Status)).To(Equal(shim.OK))
+
+			config := getConfig()
+			Expect(config.DocType).To(Equal(model.ConfigDocType))
+			Expect(config.Version).To(Equal(model.ConfigVersion))
+			Expect(config.DefaultCredits).To(Equal(3))
+			Expect(config.MaxCredits).To(Equal(model.NewConfig().MaxCredits))
+			Expect(config.RetentionDays).To(Equal(model.NewConfig().RetentionDays))
+		})
+
+		It("Should set the default retention of configs from version 1", func() {
+			key, _ := mock.CreateCompositeKey(model.ConfigDocType, []string{})
+			mock.MockTransactionStart("old")
+			Expect(mock.PutState(key, []byte(`{"docType":"config","version":1,"maxCredits":50}`))).To(Succeed())
+			mock.MockTransactionEnd("old")
+
+			result := mock.MockInit("0000", [][]byte{[]byte("upgrade")})
+			Expect(int(result.Status)).To(Equal(shim.OK))
+
+			config := getConfig()
+			Expect(config.Version).To(Equal(model.ConfigVersion))
+			Expect(config.MaxCredits).To(Equal(50))
+			Expect(config.RetentionDays).To(Equal(model.NewConfig().RetentionDays))
+		})
+
+		It("Should set the default request expiry of configs from version 2", func() {
+			key, _ := mock.CreateCompositeKey(model.ConfigDocType, []string{})
+			mock.MockTransactionStart("old")
+			Expect(mock.PutState(key, []byte(`{"docType":"config","version":2,"retentionDays":7}`))).To(Succeed())
+			mock.MockTransactionEnd("old")
+
+			result := mock.MockInit("0000", [][]byte{[]byte("upgrade")})
+			Expect(int(result.Status)).To(Equal(shim.OK))
+
+			config := getConfig()
+			Expect(config.Version).To(Equal(model.ConfigVersion))
+			Expect(config.RetentionDays).To(Equal(7))
+			Expect(config.RequestExpiryHours).To(Equal(model.NewConfig().RequestExpiryHours))
+		})
+
+		It("Should keep batches enabled in configs from version 3", func() {
+			key, _ := mock.CreateCompositeKey(model.ConfigDocType, []string{})
+			mock.MockTransactionStart("old")
+			Expect(mock.PutState(key, []byte(`{"docType":"config","version":3,"requestExpiryHours":1}`))).To(Succeed())
+			mock.MockTransactionEnd("old")
+
+			result := mock.MockInit("0000", [][]byte{[]byte("upgrade")})
+			Expect(int(result.Status)).To(Equal(shim.OK))
+
+			config := getConfig()
+			Expect(config.Version).To(Equal(model.ConfigVersion))
+			Expect(config.RequestExpiryHours).To(Equal(1))
+			Expect(config.Enabled(model.FeatureBatch)).To(BeTrue())
+		})
+
+		It("Should set no payment processors in configs from version 4", func() {
+			key, _ := mock.CreateCompositeKey(model.ConfigDocType, []string{})
+			mock.MockTransactionStart("old")
+			Expect(mock.PutState(key, []byte(`{"docType":"config","version":4,"requestExpiryHours":1}`))).To(Succeed())
+			mock.MockTransactionEnd("old")
+
+			result := mock.MockInit("0000", [][]byte{[]byte("upgrade")})
+			Expect(int(result.Status)).To(Equal(shim.OK))
+
+			config := getConfig()
+			Expect(config.Version).To(Equal(model.ConfigVersion))
+			Expect(config.PaymentProcessors).To(BeEmpty())
+			Expect(config.PaymentProcessors).NotTo(BeNil())
+		})
+	})
+
+	Context("GetConfig", func() {
+		It("Should return the config to administrators", func() {
+			mock.MockInit("0000", [][]byte{[]byte("init"), []byte(`{"defaultCredits":5}`)})
+
+			result := mock.MockInvoke("0001", [][]byte{[]byte("GetConfig")})
+			Expect(int(result.Status)).To(Equal(shim.OK))
+
+			var response struct {
+				Config *model.Config `json:"config"`
+			}
+			Expect(json.Unmarshal(result.Payload, &response)).NotTo(HaveOccurred())
+			Expect(response.Config.DefaultCredits).To(Equal(5))
+		})
+
+		It("Should allow admins bootstrapped by Init", func() {
+			mock.MockInit("0000", [][]byte{
+				[]byte("init"),
+				[]byte(`{"admins":[{"mspId":"Org2MSP","name":"bootstrap"}]}`),
+			})
+
+			Expect(mock.SetCreator("Org2MSP", "bootstrap", nil)).To(Succeed())
+			result := mock.MockInvoke("0001", [][]byte{[]byte("GetConfig")})
+			Expect(int(result.Status)).To(Equal(shim.OK))
+
+			Expect(mock.SetCreator("Org1MSP", "bootstrap", nil)).To(Succeed())
+			result = mock.MockInvoke("0002", [][]byte{[]byte("GetConfig")})
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+		})
+
+		It("Should only accept the admin attribute until admins are configured", func() {
+			mock.MockInit("0000", [][]byte{
+				[]byte("init"),
+				[]byte(`{"admins":[{"mspId":"Org2MSP","name":"bootstrap"}]}`),
+			})
+
+			Expect(mock.SetCreator("Org3MSP", "admin", map[string]string{
+				AdminAttribute: "true",
+			})).To(Succeed())
+			result := mock.MockInvoke("0001", [][]byte{[]byte("GetConfig")})
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+			Expect(result.Message).To(ContainSubstring("Permission denied"))
+
+			Expect(mock.SetCreator("Org2MSP", "bootstrap", nil)).To(Succeed())
+			result = mock.MockInvoke("0002", [][]byte{[]byte("UpdateConfig"), []byte(`{"admins":[]}`)})
+			Expect(int(result.Status)).To(Equal(shim.OK), result.Message)
+
+			Expect(mock.SetCreator("Org3MSP", "admin", map[string]string{
+				AdminAttribute: "true",
+			})).To(Succeed())
+			result = mock.MockInvoke("0003", [][]byte{[]byte("GetConfig")})
+			Expect(int(result.Status)).To(Equal(shim.OK), result.Message)
+		})
+
+		It("Should not return the config to other users", func() {
+			Expect(mock.SetCreator("Org1MSP", "someone", nil)).To(Succeed())
+
+			result := mock.MockInvoke("0000", [][]byte{[]byte("GetConfig")})
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+		})
+	})
+
+	Context("UpdateConfig", func() {
+		const method = "UpdateConfig"
+
+		BeforeEach(func() {
+			mock.MockInit("0000", [][]byte{[]byte("init")})
+		})
+
+		It("Should update only the received fields", func() {
+			result := mock.MockInvoke("0001", [][]byte{
+				[]byte(method),
+				[]byte(`{"defaultCredits":20}`),
+			})
+			Expect(int(result.Status)).To(Equal(shim.OK))
+
+			config := getConfig()
+			Expect(config.DefaultCredits).To(Equal(20))
+			Expect(config.MaxCredits).To(Equal(model.NewConfig().MaxCredits))
+		})
+
+		It("Should reject invalid configs", func() {
+			result := mock.MockInvoke("0001", [][]byte{
+				[]byte(method),
+				[]byte(`{"maxCredits":1}`),
+			})
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+			Expect(getConfig().MaxCredits).To(Equal(model.NewConfig().MaxCredits))
+		})
+
+		It("Should not update a config changed since the expected revision", func() {
+			result := mock.MockInvoke("0001", [][]byte{
+				[]byte(method),
+				[]byte(`{"defaultCredits":20}`),
+				[]byte("1"),
+			})
+			Expect(int(result.Status)).To(Equal(shim.OK))
+
+			result = mock.MockInvoke("0002", [][]byte{
+				[]byte(method),
+				[]byte(`{"defaultCredits":30}`),
+				[]byte("1"),
+			})
+			Expect(int(result.Status)).To(Equal(http.StatusConflict))
+			Expect(getConfig().DefaultCredits).To(Equal(20))
+		})
+
+		It("Should only be called by administrators", func() {
+			Expect(mock.SetCreator("Org1MSP", "someone", nil)).To(Succeed())
+
+			result := mock.MockInvoke("0001", [][]byte{
+				[]byte(method),
+				[]byte(`{"defaultCredits":20}`),
+			})
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+		})
+	})
+})
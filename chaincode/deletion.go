@@ -0,0 +1,76 @@
+package chaincode
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cdtlab19/coffee-chaincode/model"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/core/chaincode/shim/ext/cid"
+)
+
+// txTime returns the transaction timestamp, which is the same for every
+// endorser, unlike the local clock
+func txTime(stub shim.ChaincodeStubInterface) (time.Time, error) {
+	timestamp, err := stub.GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return ptypes.Timestamp(timestamp)
+}
+
+// creatorName identifies the transaction creator as "<mspID>/<common name>"
+func creatorName(stub shim.ChaincodeStubInterface) (string, error) {
+	identity, err := cid.New(stub)
+	if err != nil {
+		return "", err
+	}
+
+	mspID, err := identity.GetMSPID()
+	if err != nil {
+		return "", err
+	}
+
+	cert, err := identity.GetX509Certificate()
+	if err != nil {
+		return "", err
+	}
+	if cert == nil {
+		return mspID, nil
+	}
+
+	return fmt.Sprintf("%s/%s", mspID, cert.Subject.CommonName), nil
+}
+
+// tombstone creates the tombstone of an asset deleted by the transaction
+// creator
+func tombstone(stub shim.ChaincodeStubInterface, reason string) (*model.Tombstone, error) {
+	deletedAt, err := txTime(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	deletedBy, err := creatorName(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	return model.NewTombstone(deletedAt, deletedBy, reason), nil
+}
+
+// retention returns the configured retention of deleted assets and the
+// transaction time, used to decide if an asset may be purged
+func (cf *configurable) retention(stub shim.ChaincodeStubInterface) (time.Duration, time.Time, error) {
+	config, err := cf.config(stub)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	now, err := txTime(stub)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	return config.Retention(), now, nil
+}
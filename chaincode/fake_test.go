@@ -0,0 +1,128 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cdtlab19/coffee-chaincode/chaincode"
+	"github.com/cdtlab19/coffee-chaincode/logging"
+	"github.com/cdtlab19/coffee-chaincode/mockstub"
+	"github.com/cdtlab19/coffee-chaincode/model"
+	"github.com/cdtlab19/coffee-chaincode/store"
+	"github.com/cdtlab19/coffee-chaincode/store/fake"
+)
+
+var _ = Describe("Chaincodes with fake stores", func() {
+	var mock *mockstub.Stub
+	var logger *logging.Logger
+
+	BeforeEach(func() {
+		logger = logging.New("fake-test")
+	})
+
+	Context("Coffee", func() {
+		var coffees *fake.CoffeeStore
+
+		BeforeEach(func() {
+			coffees = fake.NewCoffeeStore()
+			mock = mockstub.NewStub("coffee", NewCoffeeChaincode(logger,
+				WithCoffeeStore(func(shim.ChaincodeStubInterface, store.Logger) store.CoffeeRepository {
+					return coffees
+				})))
+		})
+
+		It("Should create coffees in the injected store", func() {
+			result := mock.MockInvoke("0000", [][]byte{[]byte("CreateCoffee"), []byte("cappuccino")})
+			Expect(int(result.Status)).To(Equal(shim.OK))
+
+			Expect(coffees.Coffees).To(HaveKey("0000"))
+			Expect(mock.State).To(BeEmpty())
+		})
+
+		It("Should fail if the store fails to write", func() {
+			coffees.Fail("SetCoffee", errors.New("disk full"))
+
+			result := mock.MockInvoke("0000", [][]byte{[]byte("CreateCoffee"), []byte("cappuccino")})
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+			Expect(result.Message).To(Equal("disk full"))
+			Expect(coffees.Coffees).To(BeEmpty())
+		})
+
+		It("Should not change the owner if the store fails to write", func() {
+			coffees.Coffees["0000"] = model.NewCoffee("0000", "cappuccino")
+			coffees.Fail("SetCoffee", errors.New("disk full"))
+
+			result := mock.MockInvoke("0000", [][]byte{[]byte("UseCoffee"), []byte("0000"), []byte("someone")})
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+			Expect(coffees.Coffees["0000"].HasOwner()).To(BeFalse())
+		})
+
+		It("Should fail if the store fails to list", func() {
+			coffees.Fail("AllCoffee", errors.New("unavailable"))
+
+			result := mock.MockInvoke("0000", [][]byte{[]byte("AllCoffee")})
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+			Expect(result.Message).To(Equal("unavailable"))
+		})
+	})
+
+	Context("User", func() {
+		var users *fake.UserStore
+
+		BeforeEach(func() {
+			users = fake.NewUserStore()
+			mock = mockstub.NewStub("user", NewUserChaincode(logger,
+				WithUserStore(func(shim.ChaincodeStubInterface, store.Logger) store.UserRepository {
+					return users
+				})))
+			Expect(mock.SetCreator("Org1MSP", "someone", nil)).To(Succeed())
+		})
+
+		It("Should create users in the injected store", func() {
+			result := mock.MockInvokeWithTransient("0000", [][]byte{
+				[]byte("CreateUser"),
+				[]byte("3"),
+			}, personalInfo("someone"))
+			Expect(int(result.Status)).To(Equal(shim.OK))
+
+			Expect(users.Users).To(HaveKey("0000"))
+			Expect(users.Infos["0000"].Name).To(Equal("someone"))
+			Expect(users.Endorsements["0000"]).To(ConsistOf("Org1MSP"))
+		})
+
+		It("Should fail if the endorsement policy can't be set", func() {
+			users.Fail("SetUserEndorsement", errors.New("forbidden"))
+
+			result := mock.MockInvokeWithTransient("0000", [][]byte{
+				[]byte("CreateUser"),
+				[]byte("3"),
+			}, personalInfo("someone"))
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+			Expect(result.Message).To(Equal("forbidden"))
+		})
+
+		It("Should not charge the user if the store fails to write", func() {
+			users.Users["0000"] = model.NewUser("0000", 3)
+			users.Fail("SetUser", errors.New("disk full"))
+
+			result := mock.MockInvoke("0000", [][]byte{[]byte("DrinkCoffee"), []byte("0000")})
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+			Expect(users.Users["0000"].RemainingCoffee).To(Equal(3))
+
+			users.Fail("SetUser", nil)
+
+			result = mock.MockInvoke("0001", [][]byte{[]byte("DrinkCoffee"), []byte("0000")})
+			Expect(int(result.Status)).To(Equal(shim.OK))
+
+			var response struct {
+				User *model.User `json:"user"`
+			}
+			Expect(json.Unmarshal(result.Payload, &response)).To(Succeed())
+			Expect(response.User.RemainingCoffee).To(Equal(2))
+		})
+	})
+})
@@ -0,0 +1,337 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cdtlab19/coffee-chaincode/utils"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/vtfr/rocha"
+	"github.com/vtfr/rocha/argsmw"
+)
+
+// Roles which may call a function
+const (
+	RoleAny   = "any"
+	RoleAdmin = "admin"
+	// RolePayments is held by the administrators and the configured payment
+	// processors
+	RolePayments = "payments"
+)
+
+// Argument types
+const (
+	TypeString  = "string"
+	TypeInteger = "integer"
+	TypeBoolean = "boolean"
+)
+
+// Formats of string arguments holding documents
+const (
+	FormatJSON      = "json"
+	FormatJSONLines = "json-lines"
+)
+
+// Argument describes an argument of a function, or a key of it's transient
+// map
+type Argument struct {
+	Name        string      `json:"name"`
+	Type        string      `json:"type"`
+	Format      string      `json:"format,omitempty"`
+	Required    bool        `json:"required"`
+	Default     interface{} `json:"default,omitempty"`
+	Description string      `json:"description"`
+	// Random is true for transient keys generated anew by each attempt, such
+	// as salts, which aren't compared when replaying client requests
+	Random bool `json:"random,omitempty"`
+
+	parse argsmw.Definition
+}
+
+func stringArg(name, description string) Argument {
+	return Argument{Name: name, Type: TypeString, Required: true, Description: description,
+		parse: argsmw.String(name)}
+}
+
+func intArg(name, description string) Argument {
+	return Argument{Name: name, Type: TypeInteger, Required: true, Description: description,
+		parse: argsmw.Int(name, 10)}
+}
+
+func boolArg(name, description string) Argument {
+	return Argument{Name: name, Type: TypeBoolean, Required: true, Description: description,
+		parse: utils.Bool(name)}
+}
+
+// jsonArg is a JSON document argument, decoded into a pointer of the same
+// type as `value`
+func jsonArg(name, description string, value interface{}) Argument {
+	return Argument{Name: name, Type: TypeString, Format: FormatJSON, Required: true,
+		Description: description, parse: argsmw.JSON(name, value)}
+}
+
+// optional makes an argument optional, documenting the value used when it
+// isn't sent
+func (a Argument) optional(def interface{}) Argument {
+	a.Required = false
+	a.Default = def
+	return a
+}
+
+// random marks a transient key whose value is generated anew by each attempt
+func (a Argument) random() Argument {
+	a.Random = true
+	return a
+}
+
+// format documents that a string argument holds a document, decoded by the
+// handler
+func (a Argument) format(format string) Argument {
+	a.Format = format
+	return a
+}
+
+// value converts the JSON value of a named argument to it's positional form.
+// JSON documents may be sent as they are, or encoded as a string
+func (a Argument) value(raw json.RawMessage) (string, error) {
+	if a.Format == FormatJSON && !strings.HasPrefix(string(raw), `"`) {
+		return string(raw), nil
+	}
+
+	var err error
+	switch a.Type {
+	case TypeInteger:
+		var v int
+		if err = json.Unmarshal(raw, &v); err == nil {
+			return strconv.Itoa(v), nil
+		}
+	case TypeBoolean:
+		var v bool
+		if err = json.Unmarshal(raw, &v); err == nil {
+			return strconv.FormatBool(v), nil
+		}
+	default:
+		var v string
+		if err = json.Unmarshal(raw, &v); err == nil {
+			return v, nil
+		}
+	}
+	return "", fmt.Errorf("must be a JSON %s", a.Type)
+}
+
+// Function describes a chaincode function: it's arguments, who may call it
+// and what it responds
+type Function struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Args        []Argument `json:"args"`
+	Transient   []Argument `json:"transient,omitempty"`
+	// NamedArgs is true if the arguments may also be sent as a single JSON
+	// object, by name
+	NamedArgs  bool   `json:"namedArgs"`
+	Role       string `json:"role"`
+	Idempotent bool   `json:"idempotent"`
+	// Envelope is true if the responses are wrapped in an utils.Envelope
+	Envelope bool `json:"envelope"`
+	// Feature is the feature which must be enabled in the configuration to
+	// call the function, if any
+	Feature string `json:"feature,omitempty"`
+	// Response describes the JSON payload of successful responses, or their
+	// `data` if enveloped, nil if they have none
+	Response *Schema `json:"response,omitempty"`
+}
+
+// API is the contract of a chaincode, as returned by Describe
+type API struct {
+	Chaincode string      `json:"chaincode"`
+	Functions []*Function `json:"functions"`
+}
+
+// functions registers the functions of a chaincode in a router, keeping
+// their metadata for Describe
+type functions struct {
+	cf     *configurable
+	router *rocha.Router
+	api    *API
+}
+
+func newFunctions(cf *configurable, chaincode string) *functions {
+	return &functions{cf, rocha.NewRouter(), &API{chaincode, []*Function{}}}
+}
+
+// handle registers a function. It's arguments are parsed as described, only
+// administrators may call it if it's role is RoleAdmin, and only them or the
+// payment processors if it's role is RolePayments, it's responses are
+// replayed for client request IDs if it's idempotent, it may only be called
+// if it's feature is enabled, and it's result is logged
+func (f *functions) handle(fn Function, handler rocha.Handler) *functions {
+	if fn.Role == "" {
+		fn.Role = RoleAny
+	}
+	if fn.Args == nil {
+		fn.Args = []Argument{}
+	}
+
+	required := 0
+	defs := make([]argsmw.Definition, len(fn.Args))
+	for i, arg := range fn.Args {
+		if arg.Required {
+			if required != i {
+				panic(fmt.Sprintf("%s: required argument '%s' follows optional arguments", fn.Name, arg.Name))
+			}
+			required++
+		}
+		defs[i] = arg.parse
+	}
+
+	positional := utils.OptionalArguments(required, defs...)
+	if required == len(defs) {
+		positional = argsmw.Arguments(defs...)
+	}
+
+	// functions whose first argument is a document can't tell it apart from
+	// named arguments
+	fn.NamedArgs = len(fn.Args) > 0 && fn.Args[0].Format == ""
+
+	middlewares := []rocha.Middleware{positional}
+	if fn.NamedArgs {
+		middlewares[0] = namedArguments(fn.Args, positional)
+	}
+	if len(fn.Transient) > 0 {
+		middlewares = append(middlewares, transientArguments(fn.Transient))
+	}
+	if fn.Idempotent {
+		middlewares = append(middlewares, f.cf.idempotent(fn.Transient))
+	}
+	if fn.Role == RoleAdmin {
+		middlewares = append(middlewares, f.cf.adminOnly)
+	}
+	if fn.Role == RolePayments {
+		middlewares = append(middlewares, f.cf.paymentsOnly)
+	}
+	if fn.Feature != "" {
+		middlewares = append(middlewares, f.cf.featureEnabled(fn.Feature))
+	}
+	middlewares = append(middlewares, f.cf.logged)
+
+	f.router.Handle(fn.Name, handler, middlewares...)
+	f.api.Functions = append(f.api.Functions, &fn)
+	return f
+}
+
+// respond registers a function whose handler returns a value responded as
+// JSON, enveloped if the function opts in
+func (f *functions) respond(fn Function, h func(c rocha.Context) (interface{}, error)) *functions {
+	options := []utils.ResponseOption{}
+	if fn.Envelope {
+		options = append(options, utils.WithEnvelope())
+	}
+	return f.handle(fn, utils.RespondJSON(h, options...))
+}
+
+// namedArguments is an argument parsing middleware which also accepts the
+// arguments as a single JSON object, such as `{"id": "..", "user": ".."}`.
+// Named arguments are validated against their descriptions and stored in the
+// context like positional ones, which are parsed by `positional`
+func namedArguments(args []Argument, positional rocha.Middleware) rocha.Middleware {
+	return func(next rocha.Handler) rocha.Handler {
+		parsePositional := positional(next)
+
+		return func(c rocha.Context) pb.Response {
+			if len(c.Args()) != 1 || !strings.HasPrefix(strings.TrimSpace(c.Args()[0]), "{") {
+				return parsePositional(c)
+			}
+
+			values := map[string]json.RawMessage{}
+			if err := json.Unmarshal([]byte(c.Args()[0]), &values); err != nil {
+				return shim.Error(fmt.Sprintf("Invalid named arguments: %s", err.Error()))
+			}
+
+			described := map[string]bool{}
+			for _, arg := range args {
+				described[arg.Name] = true
+
+				raw, ok := values[arg.Name]
+				if !ok || string(raw) == "null" {
+					if arg.Required {
+						return shim.Error(fmt.Sprintf("Missing argument '%s'", arg.Name))
+					}
+					continue
+				}
+
+				value, err := arg.value(raw)
+				if err == nil {
+					err = arg.parse(c, value)
+				}
+				if err != nil {
+					return shim.Error(fmt.Sprintf("Invalid argument '%s': %s", arg.Name, err.Error()))
+				}
+			}
+
+			for name := range values {
+				if !described[name] {
+					return shim.Error(fmt.Sprintf("Unknown argument '%s'", name))
+				}
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// transientArguments is a middleware which parses the described keys of the
+// transient map and stores them in the context like arguments, so sensitive
+// inputs aren't recorded in the transaction. Empty values are missing
+func transientArguments(args []Argument) rocha.Middleware {
+	return func(next rocha.Handler) rocha.Handler {
+		return func(c rocha.Context) pb.Response {
+			transient, err := c.Stub().GetTransient()
+			if err != nil {
+				return shim.Error(fmt.Sprintf("Invalid transient map: %s", err.Error()))
+			}
+
+			invalid := []string{}
+			for _, arg := range args {
+				value := transient[arg.Name]
+				if len(value) == 0 {
+					if arg.Required {
+						invalid = append(invalid, fmt.Sprintf("%s: missing", arg.Name))
+					}
+					continue
+				}
+
+				if err := arg.parse(c, string(value)); err != nil {
+					invalid = append(invalid, fmt.Sprintf("%s: %s", arg.Name, err.Error()))
+				}
+			}
+
+			if len(invalid) > 0 {
+				return shim.Error(fmt.Sprintf("Invalid transient map: %s", strings.Join(invalid, "; ")))
+			}
+			return next(c)
+		}
+	}
+}
+
+// Describe retorna o contrato do chaincode: suas funções, argumentos e
+// respostas
+func (f *functions) Describe(c rocha.Context) (interface{}, error) {
+	return f.api, nil
+}
+
+// pageArgs are the arguments of paged functions
+func pageArgs() []Argument {
+	return []Argument{
+		intArg("pageSize", "number of records read in this page"),
+		stringArg("bookmark", "bookmark returned by the previous page").optional(""),
+	}
+}
+
+var describeFunction = Function{
+	Name:        "Describe",
+	Description: "Returns the chaincode's functions, their arguments and responses",
+	Response:    &Schema{Type: "object", Description: "the chaincode API"},
+}
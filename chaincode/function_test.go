@@ -0,0 +1,244 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cdtlab19/coffee-chaincode/chaincode"
+	"github.com/cdtlab19/coffee-chaincode/logging"
+	"github.com/cdtlab19/coffee-chaincode/mockstub"
+	"github.com/cdtlab19/coffee-chaincode/model"
+	"github.com/cdtlab19/coffee-chaincode/store"
+)
+
+var _ = Describe("Function", func() {
+	var logger *logging.Logger
+
+	BeforeEach(func() {
+		logger = logging.New("function-test")
+	})
+
+	describe := func(mock *mockstub.Stub) *API {
+		result := mock.MockInvoke("0001", [][]byte{[]byte("Describe")})
+		Expect(int(result.Status)).To(Equal(shim.OK))
+
+		api := &API{}
+		Expect(json.Unmarshal(result.Payload, api)).To(Succeed())
+		return api
+	}
+
+	signature := func(arg Argument) string {
+		return fmt.Sprintf("%s %s required=%t", arg.Name, arg.Type, arg.Required)
+	}
+
+	find := func(api *API, name string) *Function {
+		for _, fn := range api.Functions {
+			if fn.Name == name {
+				return fn
+			}
+		}
+		Fail("function " + name + " not described")
+		return nil
+	}
+
+	Context("Describe", func() {
+		It("Should describe the arguments of the coffee functions in order", func() {
+			api := describe(mockstub.NewStub("coffee", NewCoffeeChaincode(logger)))
+			Expect(api.Chaincode).To(Equal("coffee"))
+
+			fn := find(api, "UseCoffee")
+			Expect(fn.Role).To(Equal(RoleAny))
+			Expect(fn.Idempotent).To(BeTrue())
+			Expect(fn.Response).To(BeNil())
+			Expect(fn.Args).To(HaveLen(3))
+			Expect(signature(fn.Args[0])).To(Equal("id string required=true"))
+			Expect(signature(fn.Args[1])).To(Equal("user string required=true"))
+			Expect(signature(fn.Args[2])).To(Equal("revision integer required=false"))
+
+			fn = find(api, "AllCoffee")
+			Expect(signature(fn.Args[0])).To(Equal("includeDeleted boolean required=false"))
+			Expect(fn.Args[0].Default).To(Equal(false))
+		})
+
+		It("Should describe the required role", func() {
+			api := describe(mockstub.NewStub("coffee", NewCoffeeChaincode(logger)))
+
+			Expect(find(api, "RestoreCoffee").Role).To(Equal(RoleAdmin))
+			Expect(find(api, "GetConfig").Role).To(Equal(RoleAdmin))
+			Expect(find(api, "GetCoffee").Role).To(Equal(RoleAny))
+			Expect(find(api, "Describe").Role).To(Equal(RoleAny))
+		})
+
+		It("Should describe the response schema, including embedded fields", func() {
+			api := describe(mockstub.NewStub("coffee", NewCoffeeChaincode(logger)))
+
+			response := find(api, "GetCoffee").Response
+			Expect(response.Type).To(Equal("object"))
+
+			coffee := response.Properties["coffee"]
+			Expect(coffee.Type).To(Equal("object"))
+			Expect(coffee.Properties).To(HaveKey("id"))
+			Expect(coffee.Properties).To(HaveKey("flavour"))
+			Expect(coffee.Properties["revision"].Type).To(Equal("integer"))
+			Expect(coffee.Properties["deleted"].Properties["deletedAt"].Format).To(Equal("date-time"))
+
+			coffees := find(api, "AllCoffee").Response.Properties["coffees"]
+			Expect(coffees.Type).To(Equal("array"))
+			Expect(coffees.Items.Properties).To(HaveKey("owner"))
+		})
+
+		It("Should describe the transient keys of the user functions", func() {
+			api := describe(mockstub.NewStub("user", NewUserChaincode(logger)))
+			Expect(api.Chaincode).To(Equal("user"))
+
+			fn := find(api, "CreateUser")
+			Expect(fn.Args).To(HaveLen(1))
+			Expect(signature(fn.Args[0])).To(Equal("remainingCoffee integer required=false"))
+			Expect(fn.Transient).To(HaveLen(4))
+			Expect(signature(fn.Transient[0])).To(Equal("name string required=true"))
+
+			fn = find(api, "TopUp")
+			Expect(fn.Transient).To(HaveLen(1))
+			Expect(signature(fn.Transient[0])).To(Equal("paymentReference string required=true"))
+			Expect(fn.Envelope).To(BeTrue())
+			Expect(find(api, "GetUser").Envelope).To(BeFalse())
+
+			fn = find(api, "SetUserEndorsement")
+			Expect(fn.Args[1].Format).To(Equal(FormatJSON))
+		})
+
+		It("Should describe every registered function", func() {
+			for _, mock := range []*mockstub.Stub{
+				mockstub.NewStub("coffee", NewCoffeeChaincode(logger)),
+				mockstub.NewStub("user", NewUserChaincode(logger)),
+			} {
+				for _, fn := range describe(mock).Functions {
+					Expect(fn.Description).NotTo(BeEmpty())
+
+					result := mock.MockInvoke("0002", [][]byte{[]byte(fn.Name)})
+					Expect(result.Message).NotTo(HavePrefix("method '"), fn.Name)
+				}
+			}
+		})
+	})
+
+	Context("Arguments", func() {
+		It("Should reject arguments not described", func() {
+			mock := mockstub.NewStub("coffee", NewCoffeeChaincode(logger))
+
+			result := mock.MockInvoke("0001", [][]byte{[]byte("Describe"), []byte("extra")})
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+			Expect(strings.ToLower(result.Message)).To(ContainSubstring("invalid number of arguments"))
+		})
+	})
+
+	Context("Named arguments", func() {
+		var mock *mockstub.Stub
+		var st *store.CoffeeStore
+
+		BeforeEach(func() {
+			mock = mockstub.NewStub("coffee", NewCoffeeChaincode(logger))
+			st = store.NewCoffeeStore(mock, logger)
+			Expect(mock.SetCreator("Org1MSP", "someone", nil)).To(Succeed())
+			createTestCoffee(mock, st, model.NewCoffee("0000", "cappuccino"))
+		})
+
+		invoke := func(function, args string) pb.Response {
+			return mock.MockInvoke("0001", [][]byte{[]byte(function), []byte(args)})
+		}
+
+		getCoffee := func() *model.Coffee {
+			mock.MockTransactionStart("get")
+			defer mock.MockTransactionEnd("get")
+
+			coffee, err := st.GetCoffee("0000")
+			Expect(err).NotTo(HaveOccurred())
+			return coffee
+		}
+
+		It("Should accept arguments as a JSON object", func() {
+			result := invoke("UseCoffee", `{"user":"someone","id":"0000"}`)
+			Expect(int(result.Status)).To(Equal(shim.OK))
+			Expect(getCoffee().Owner).To(Equal("someone"))
+		})
+
+		It("Should parse optional typed arguments", func() {
+			result := invoke("UseCoffee", `{"id":"0000","user":"someone","revision":5}`)
+			Expect(int(result.Status)).To(Equal(http.StatusConflict))
+
+			result = invoke("AllCoffee", `{"includeDeleted":true}`)
+			Expect(int(result.Status)).To(Equal(shim.OK))
+		})
+
+		It("Should treat null as a missing argument", func() {
+			result := invoke("UseCoffee", `{"id":"0000","user":"someone","revision":null}`)
+			Expect(int(result.Status)).To(Equal(shim.OK))
+		})
+
+		It("Should reject missing required arguments", func() {
+			result := invoke("UseCoffee", `{"id":"0000"}`)
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+			Expect(result.Message).To(Equal("Missing argument 'user'"))
+		})
+
+		It("Should reject unknown arguments", func() {
+			result := invoke("UseCoffee", `{"id":"0000","user":"someone","owner":"someone"}`)
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+			Expect(result.Message).To(Equal("Unknown argument 'owner'"))
+		})
+
+		It("Should reject arguments of the wrong type", func() {
+			result := invoke("UseCoffee", `{"id":"0000","user":"someone","revision":"1"}`)
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+			Expect(result.Message).To(Equal("Invalid argument 'revision': must be a JSON integer"))
+
+			result = invoke("UseCoffee", `{"id":0,"user":"someone"}`)
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+			Expect(result.Message).To(Equal("Invalid argument 'id': must be a JSON string"))
+		})
+
+		It("Should reject invalid JSON objects", func() {
+			result := invoke("UseCoffee", `{"id":`)
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+			Expect(result.Message).To(HavePrefix("Invalid named arguments"))
+		})
+
+		It("Should accept JSON document arguments as they are", func() {
+			mock := mockstub.NewStub("user", NewUserChaincode(logger))
+			Expect(mock.SetCreator("Org1MSP", "admin", map[string]string{
+				AdminAttribute: "true",
+			})).To(Succeed())
+
+			result := mock.MockInvoke("0001", [][]byte{
+				[]byte("SetUserEndorsement"),
+				[]byte(`{"id":"missing","orgs":["Org1MSP"]}`),
+			})
+			Expect(int(result.Status)).To(Equal(http.StatusNotFound))
+		})
+
+		It("Should keep documents as positional arguments", func() {
+			mock := mockstub.NewStub("user", NewUserChaincode(logger))
+			Expect(mock.SetCreator("Org1MSP", "admin", map[string]string{
+				AdminAttribute: "true",
+			})).To(Succeed())
+			Expect(int(mock.MockInit("0000", [][]byte{[]byte("init")}).Status)).To(Equal(shim.OK))
+
+			result := mock.MockInvoke("0001", [][]byte{
+				[]byte("UpdateConfig"),
+				[]byte(`{"defaultCredits":5}`),
+			})
+			Expect(int(result.Status)).To(Equal(shim.OK))
+
+			api := describe(mock)
+			Expect(find(api, "UpdateConfig").NamedArgs).To(BeFalse())
+			Expect(find(api, "GetUser").NamedArgs).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,259 @@
+package chaincode
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cdtlab19/coffee-chaincode/model"
+	"github.com/cdtlab19/coffee-chaincode/store"
+	"github.com/cdtlab19/coffee-chaincode/utils"
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/core/chaincode/shim/ext/cid"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/vtfr/rocha"
+)
+
+// RequestIDKey is the transient map key of the optional client request ID.
+// Invocations retried with the same request ID return the response of the
+// first invocation instead of being executed again
+const RequestIDKey = "requestId"
+
+// RequestMismatchError is returned when a client request ID is reused for a
+// different invocation
+type RequestMismatchError struct {
+	RequestID string
+	Function  string
+}
+
+func (e *RequestMismatchError) Error() string {
+	return fmt.Sprintf("request '%s' was already used for a different invocation of %s",
+		e.RequestID, e.Function)
+}
+
+// Status returns the response status of the error
+func (e *RequestMismatchError) Status() int32 {
+	return http.StatusConflict
+}
+
+func (cf *configurable) requestStore(stub shim.ChaincodeStubInterface) *store.RequestStore {
+	return store.NewRequestStore(stub, cf.logger.For(stub))
+}
+
+// requestCreator identifies the creator of a request by it's MSP ID and
+// certificate subject and issuer
+func requestCreator(stub shim.ChaincodeStubInterface) (string, error) {
+	identity, err := cid.New(stub)
+	if err != nil {
+		return "", err
+	}
+
+	mspID, err := identity.GetMSPID()
+	if err != nil {
+		return "", err
+	}
+
+	id, err := identity.GetID()
+	if err != nil {
+		return "", err
+	}
+	return mspID + "/" + id, nil
+}
+
+// chaincodeCallStub is the stub of an invocation by another chaincode. Fabric
+// sends the caller's transient map along, whose client request ID identifies
+// the caller's invocation, so it's removed
+type chaincodeCallStub struct {
+	shim.ChaincodeStubInterface
+}
+
+func (s *chaincodeCallStub) GetTransient() (map[string][]byte, error) {
+	return withoutRequestID(s.ChaincodeStubInterface)
+}
+
+// invocationStub returns the stub of an invocation, which doesn't see the
+// client request ID when invoked by another chaincode. Fabric sends these
+// invocations with the caller's signed proposal, so they're recognized by
+// arguments which differ from the proposal's
+func invocationStub(stub shim.ChaincodeStubInterface) (shim.ChaincodeStubInterface, error) {
+	signed, err := stub.GetSignedProposal()
+	if err != nil {
+		return nil, err
+	}
+	if signed == nil || len(signed.ProposalBytes) == 0 {
+		return stub, nil
+	}
+
+	proposal := &pb.Proposal{}
+	if err := proto.Unmarshal(signed.ProposalBytes, proposal); err != nil {
+		return nil, err
+	}
+
+	payload := &pb.ChaincodeProposalPayload{}
+	if err := proto.Unmarshal(proposal.Payload, payload); err != nil {
+		return nil, err
+	}
+
+	spec := &pb.ChaincodeInvocationSpec{}
+	if err := proto.Unmarshal(payload.Input, spec); err != nil {
+		return nil, err
+	}
+
+	proposed, args := spec.GetChaincodeSpec().GetInput().GetArgs(), stub.GetArgs()
+	if len(proposed) != len(args) {
+		return &chaincodeCallStub{stub}, nil
+	}
+	for i := range args {
+		if !bytes.Equal(proposed[i], args[i]) {
+			return &chaincodeCallStub{stub}, nil
+		}
+	}
+	return stub, nil
+}
+
+// declaredTransient returns the non-empty values of the transient map keys
+// declared by a function, except for random ones
+func declaredTransient(transient map[string][]byte, args []Argument) map[string][]byte {
+	values := map[string][]byte{}
+	for _, arg := range args {
+		if value := transient[arg.Name]; len(value) > 0 && !arg.Random {
+			values[arg.Name] = value
+		}
+	}
+	return values
+}
+
+// idempotent is a middleware which records the response of invocations with a
+// client request ID, returning it again when the same creator retries the
+// invocation with the same ID until the request expires. Retries must send
+// the same arguments and the same values of the function's `transient` keys.
+// Failed invocations aren't recorded, so they may be retried
+func (cf *configurable) idempotent(transientArgs []Argument) rocha.Middleware {
+	return func(next rocha.Handler) rocha.Handler {
+		return func(c rocha.Context) pb.Response {
+			return cf.replay(c, next, transientArgs)
+		}
+	}
+}
+
+// replay returns the recorded response of a request, or invokes `next` and
+// records it's response
+func (cf *configurable) replay(c rocha.Context, next rocha.Handler, transientArgs []Argument) pb.Response {
+	stub := c.Stub()
+
+	transient, err := stub.GetTransient()
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	requestID := string(transient[RequestIDKey])
+	if requestID == "" {
+		return next(c)
+	}
+
+	creator, err := requestCreator(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	config, err := cf.config(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	now, err := txTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	st := cf.requestStore(stub)
+	fn, args := stub.GetFunctionAndParameters()
+	inputs := declaredTransient(transient, transientArgs)
+
+	request, err := st.GetRequest(creator, requestID)
+	if err != nil && !store.IsNotFound(err) {
+		return shim.Error(err.Error())
+	}
+
+	if err == nil && !request.Expired(now, config.RequestExpiry()) {
+		if !request.Matches(fn, args, inputs) {
+			return utils.Error(&RequestMismatchError{requestID, fn})
+		}
+
+		cf.logger.For(stub).Debugf("Replaying response of request '%s'", requestID)
+		return request.Response()
+	}
+
+	response := next(c)
+	if response.Status >= shim.ERRORTHRESHOLD {
+		return response
+	}
+
+	if err := st.SetRequest(model.NewRequest(creator, requestID, fn, args, inputs, now, response)); err != nil {
+		return shim.Error(err.Error())
+	}
+	return response
+}
+
+// requestExpiry returns the configured expiry of requests and the
+// transaction time
+func (cf *configurable) requestExpiry(stub shim.ChaincodeStubInterface) (time.Duration, time.Time, error) {
+	config, err := cf.config(stub)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	now, err := txTime(stub)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return config.RequestExpiry(), now, nil
+}
+
+// ExpiredRequests lista as chaves de uma página de requisições registradas há
+// mais tempo que a expiração configurada, que devem ser apagadas por
+// ExpireRequests
+func (cf *configurable) ExpiredRequests(c rocha.Context) (interface{}, error) {
+	stub := c.Stub()
+
+	expiry, now, err := cf.requestExpiry(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	return cf.requestStore(stub).ExpiredRequests(c.Int("pageSize"), c.String("bookmark"), expiry, now)
+}
+
+// ExpireRequests apaga as requisições listadas por ExpiredRequests que ainda
+// estão expiradas
+func (cf *configurable) ExpireRequests(c rocha.Context) (interface{}, error) {
+	stub := c.Stub()
+
+	expiry, now, err := cf.requestExpiry(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	return cf.requestStore(stub).ExpireRequests(*c.Value("keys").(*[]string), expiry, now)
+}
+
+var expiredRequestsFunction = Function{
+	Name: "ExpiredRequests",
+	Description: "Lists the keys of a page of client requests recorded for longer than the configured expiry, " +
+		"starting at the optional `bookmark`. It must be called as a query",
+	Args:     pageArgs(),
+	Role:     RoleAdmin,
+	Envelope: true,
+	Response: schemaOf(store.ScanResult{}),
+}
+
+var expireRequestsFunction = Function{
+	Name:        "ExpireRequests",
+	Description: "Erases the client requests with the `keys` listed by ExpiredRequests, if they're still expired",
+	Args:        []Argument{jsonArg("keys", "JSON array of request keys", &[]string{})},
+	Role:        RoleAdmin,
+	Envelope:    true,
+	Response:    schemaOf(store.RewriteResult{}),
+}
@@ -0,0 +1,218 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/core/chaincode/shim/ext/cid"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cdtlab19/coffee-chaincode/chaincode"
+	"github.com/cdtlab19/coffee-chaincode/logging"
+	"github.com/cdtlab19/coffee-chaincode/mockstub"
+	"github.com/cdtlab19/coffee-chaincode/model"
+	"github.com/cdtlab19/coffee-chaincode/store"
+	"github.com/cdtlab19/coffee-chaincode/utils"
+)
+
+var _ = Describe("Idempotency", func() {
+	var mock *mockstub.Stub
+	var logger *logging.Logger
+	var st *store.CoffeeStore
+
+	BeforeEach(func() {
+		logger = logging.New("idempotency-test")
+		mock = mockstub.NewStub("coffee", NewCoffeeChaincode(logger))
+		st = store.NewCoffeeStore(mock, logger)
+		Expect(mock.SetCreator("Org1MSP", "someone", nil)).To(Succeed())
+	})
+
+	withRequestID := func(id string) map[string][]byte {
+		return map[string][]byte{RequestIDKey: []byte(id)}
+	}
+
+	createdCoffee := func(result pb.Response) *model.Coffee {
+		var response struct {
+			Coffee *model.Coffee `json:"coffee"`
+		}
+		Expect(json.Unmarshal(result.Payload, &response)).To(Succeed())
+		return response.Coffee
+	}
+
+	It("Should replay the response of a retried request", func() {
+		args := [][]byte{[]byte("CreateCoffee"), []byte("cappuccino")}
+
+		first := mock.MockInvokeWithTransient("0000", args, withRequestID("req-1"))
+		Expect(int(first.Status)).To(Equal(shim.OK))
+
+		retry := mock.MockInvokeWithTransient("0001", args, withRequestID("req-1"))
+		Expect(retry).To(Equal(first))
+		Expect(createdCoffee(retry).ID).To(Equal("0000"))
+
+		coffees, err := st.AllCoffee(false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(coffees).To(HaveLen(1))
+	})
+
+	It("Should execute invocations without request ID every time", func() {
+		args := [][]byte{[]byte("CreateCoffee"), []byte("cappuccino")}
+
+		Expect(int(mock.MockInvoke("0000", args).Status)).To(Equal(shim.OK))
+		Expect(int(mock.MockInvoke("0001", args).Status)).To(Equal(shim.OK))
+
+		coffees, err := st.AllCoffee(false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(coffees).To(HaveLen(2))
+	})
+
+	It("Should reject request IDs reused for other invocations", func() {
+		result := mock.MockInvokeWithTransient("0000", [][]byte{
+			[]byte("CreateCoffee"), []byte("cappuccino"),
+		}, withRequestID("req-1"))
+		Expect(int(result.Status)).To(Equal(shim.OK))
+
+		result = mock.MockInvokeWithTransient("0001", [][]byte{
+			[]byte("CreateCoffee"), []byte("mocha"),
+		}, withRequestID("req-1"))
+		Expect(int(result.Status)).To(Equal(http.StatusConflict))
+	})
+
+	It("Should keep the request IDs of each creator apart", func() {
+		args := [][]byte{[]byte("CreateCoffee"), []byte("cappuccino")}
+
+		first := mock.MockInvokeWithTransient("0000", args, withRequestID("req-1"))
+		Expect(int(first.Status)).To(Equal(shim.OK))
+
+		Expect(mock.SetCreator("Org2MSP", "someone", nil)).To(Succeed())
+		other := mock.MockInvokeWithTransient("0001", [][]byte{
+			[]byte("CreateCoffee"), []byte("mocha"),
+		}, withRequestID("req-1"))
+		Expect(int(other.Status)).To(Equal(shim.OK))
+		Expect(createdCoffee(other).ID).To(Equal("0001"))
+	})
+
+	It("Should reject request IDs reused with other transient inputs", func() {
+		mock = mockstub.NewStub("user", NewUserChaincode(logger))
+		Expect(mock.SetCreator("Org1MSP", "someone", map[string]string{
+			AdminAttribute: "true",
+		})).To(Succeed())
+		createTestUser(mock, store.NewUserStore(mock, logger), model.NewUser("0000", 3))
+		setTestPaymentKey(mock, store.NewUserStore(mock, logger))
+
+		topUp := func(txID, reference string) pb.Response {
+			return mock.MockInvokeWithTransient(txID, [][]byte{
+				[]byte("TopUp"), []byte("0000"), []byte("5"),
+			}, map[string][]byte{RequestIDKey: []byte("req-1"), "paymentReference": []byte(reference)})
+		}
+
+		first := topUp("0001", "PAY-0001")
+		Expect(int(first.Status)).To(Equal(shim.OK))
+		Expect(topUp("0002", "PAY-0001")).To(Equal(first))
+		Expect(int(topUp("0003", "PAY-0002").Status)).To(Equal(http.StatusConflict))
+	})
+
+	It("Should replay retries sending new random transient inputs", func() {
+		mock = mockstub.NewStub("user", NewUserChaincode(logger))
+		Expect(mock.SetCreator("Org1MSP", "someone", nil)).To(Succeed())
+
+		createUser := func(txID, name, salt string) pb.Response {
+			return mock.MockInvokeWithTransient(txID, [][]byte{[]byte("CreateUser"), []byte("3")},
+				map[string][]byte{RequestIDKey: []byte("req-1"), "name": []byte(name), "salt": []byte(salt)})
+		}
+
+		first := createUser("0000", "someone", "0123456789abcdef")
+		Expect(int(first.Status)).To(Equal(shim.OK), first.Message)
+		Expect(createUser("0001", "someone", "fedcba9876543210")).To(Equal(first))
+		Expect(int(createUser("0002", "anyone", "0123456789abcdef").Status)).To(Equal(http.StatusConflict))
+	})
+
+	It("Should not record failed invocations", func() {
+		args := [][]byte{[]byte("UseCoffee"), []byte("0000"), []byte("user")}
+
+		result := mock.MockInvokeWithTransient("0001", args, withRequestID("req-1"))
+		Expect(int(result.Status)).To(Equal(http.StatusNotFound))
+
+		createTestCoffee(mock, st, model.NewCoffee("0000", "cappuccino"))
+
+		result = mock.MockInvokeWithTransient("0002", args, withRequestID("req-1"))
+		Expect(int(result.Status)).To(Equal(shim.OK))
+	})
+
+	It("Should execute again requests after they expire", func() {
+		requests := store.NewRequestStore(mock, logger)
+		mock.MockTransactionStart("old")
+		identity, err := cid.New(mock)
+		Expect(err).NotTo(HaveOccurred())
+		mspID, err := identity.GetMSPID()
+		Expect(err).NotTo(HaveOccurred())
+		id, err := identity.GetID()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requests.SetRequest(model.NewRequest(mspID+"/"+id, "req-1", "CreateCoffee", []string{"cappuccino"},
+			nil, time.Now().Add(-48*time.Hour), shim.Success(nil)))).To(Succeed())
+		mock.MockTransactionEnd("old")
+
+		result := mock.MockInvokeWithTransient("0000", [][]byte{
+			[]byte("CreateCoffee"), []byte("cappuccino"),
+		}, withRequestID("req-1"))
+		Expect(int(result.Status)).To(Equal(shim.OK))
+		Expect(createdCoffee(result).ID).To(Equal("0000"))
+	})
+
+	Context("ExpireRequests", func() {
+		BeforeEach(func() {
+			requests := store.NewRequestStore(mock, logger)
+			mock.MockTransactionStart("old")
+			Expect(requests.SetRequest(model.NewRequest("Org1MSP/someone", "req-1", "CreateCoffee", nil, nil,
+				time.Now().Add(-48*time.Hour), shim.Success(nil)))).To(Succeed())
+			Expect(requests.SetRequest(model.NewRequest("Org1MSP/someone", "req-2", "CreateCoffee", nil, nil,
+				time.Now(), shim.Success(nil)))).To(Succeed())
+			mock.MockTransactionEnd("old")
+		})
+
+		It("Should erase expired requests listed by a query", func() {
+			Expect(mock.SetCreator("Org1MSP", "admin", map[string]string{
+				AdminAttribute: "true",
+			})).To(Succeed())
+
+			result := mock.MockInvoke("0000", [][]byte{[]byte("ExpiredRequests"), []byte("10")})
+			Expect(int(result.Status)).To(Equal(shim.OK))
+
+			var envelope utils.Envelope
+			Expect(json.Unmarshal(result.Payload, &envelope)).To(Succeed())
+			Expect(envelope.TxID).To(Equal("0000"))
+			Expect(envelope.Page).To(Equal(&utils.Page{Bookmark: "", Done: true}))
+
+			var scan store.ScanResult
+			Expect(json.Unmarshal(envelope.Data, &scan)).To(Succeed())
+			Expect(scan.Scanned).To(Equal(2))
+			Expect(scan.IDs).To(Equal([]string{model.RequestKey("Org1MSP/someone", "req-1")}))
+
+			keys, _ := json.Marshal(scan.IDs)
+			result = mock.MockInvoke("0001", [][]byte{[]byte("ExpireRequests"), keys})
+			Expect(int(result.Status)).To(Equal(shim.OK))
+
+			Expect(json.Unmarshal(result.Payload, &envelope)).To(Succeed())
+			var expired store.RewriteResult
+			Expect(json.Unmarshal(envelope.Data, &expired)).To(Succeed())
+			Expect(expired.Changed).To(Equal(1))
+
+			requests := store.NewRequestStore(mock, logger)
+			_, err := requests.GetRequest("Org1MSP/someone", "req-1")
+			Expect(store.IsNotFound(err)).To(BeTrue())
+			_, err = requests.GetRequest("Org1MSP/someone", "req-2")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should only be called by administrators", func() {
+			result := mock.MockInvoke("0000", [][]byte{[]byte("ExpiredRequests"), []byte("10")})
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+
+			result = mock.MockInvoke("0000", [][]byte{[]byte("ExpireRequests"), []byte(`[]`)})
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+		})
+	})
+})
@@ -0,0 +1,103 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/cdtlab19/coffee-chaincode/model"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// OutstandingStateError is returned when deleting an user who still has
+// remaining coffees or owns coffees, which would be left dangling
+type OutstandingStateError struct {
+	UserID          string   `json:"userId"`
+	RemainingCoffee int      `json:"remainingCoffee"`
+	Coffees         []string `json:"coffees"`
+}
+
+// Error describes the user's outstanding state
+func (e *OutstandingStateError) Error() string {
+	outstanding := []string{}
+	if e.RemainingCoffee > 0 {
+		outstanding = append(outstanding, fmt.Sprintf("%d remaining coffees", e.RemainingCoffee))
+	}
+	if len(e.Coffees) > 0 {
+		outstanding = append(outstanding, fmt.Sprintf("owns coffees %s", strings.Join(e.Coffees, ", ")))
+	}
+	return fmt.Sprintf("user '%s' has outstanding state: %s; delete it with force to close it",
+		e.UserID, strings.Join(outstanding, "; "))
+}
+
+// Status responds outstanding state as a conflict
+func (e *OutstandingStateError) Status() int32 {
+	return http.StatusConflict
+}
+
+// outstanding returns the user's outstanding state, or nil if it may be
+// deleted
+func (u *UserChaincode) outstanding(stub shim.ChaincodeStubInterface, user *model.User) (*OutstandingStateError, error) {
+	coffees, err := u.ownedCoffees(stub, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.RemainingCoffee == 0 && len(coffees) == 0 {
+		return nil, nil
+	}
+
+	return &OutstandingStateError{
+		UserID:          user.ID,
+		RemainingCoffee: user.RemainingCoffee,
+		Coffees:         coffees,
+	}, nil
+}
+
+// invokeCoffee invokes a function of the coffee chaincode. In a combined
+// deployment it's routed in-process, in the same transaction
+func (u *UserChaincode) invokeCoffee(stub shim.ChaincodeStubInterface, function string, args ...string) pb.Response {
+	if u.coffeeRouter != nil {
+		return u.coffeeRouter.Invoke(newCallStub(stub, function, args), function, args)
+	}
+
+	raw := [][]byte{[]byte(function)}
+	for _, arg := range args {
+		raw = append(raw, []byte(arg))
+	}
+	return stub.InvokeChaincode(u.coffeeChaincode, raw, "")
+}
+
+// ownedCoffees queries the coffee chaincode for the IDs of the coffees owned
+// by an user
+func (u *UserChaincode) ownedCoffees(stub shim.ChaincodeStubInterface, userID string) ([]string, error) {
+	response := u.invokeCoffee(stub, "CoffeeByOwner", userID)
+	if response.Status != shim.OK {
+		return nil, fmt.Errorf("failed querying chaincode '%s': %s", u.coffeeChaincode, response.Message)
+	}
+
+	var payload struct {
+		Coffees []*model.Coffee `json:"coffees"`
+	}
+	if err := json.Unmarshal(response.Payload, &payload); err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for _, coffee := range payload.Coffees {
+		ids = append(ids, coffee.ID)
+	}
+	return ids, nil
+}
+
+// closeCoffee deletes a coffee owned by a deleted user through the coffee
+// chaincode
+func (u *UserChaincode) closeCoffee(stub shim.ChaincodeStubInterface, coffeeID, userID string) error {
+	response := u.invokeCoffee(stub, "DeleteCoffee", coffeeID, fmt.Sprintf("owner '%s' was deleted", userID))
+	if response.Status != shim.OK {
+		return fmt.Errorf("failed closing coffee '%s': %s", coffeeID, response.Message)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package chaincode
+
+import (
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/vtfr/rocha"
+)
+
+// logged is a middleware which logs the result of each invocation, with it's
+// status and duration, at the log level of the committed configuration
+func (cf *configurable) logged(next rocha.Handler) rocha.Handler {
+	return func(c rocha.Context) pb.Response {
+		cf.applyLogLevel(c.Stub())
+
+		start := time.Now()
+		response := next(c)
+
+		logger := cf.logger.For(c.Stub()).
+			With("status", response.Status).
+			With("durationMs", time.Since(start).Nanoseconds()/int64(time.Millisecond))
+		if response.Status >= shim.ERRORTHRESHOLD {
+			logger.Noticef("%s failed: %s", c.Method(), response.Message)
+		} else {
+			logger.Infof("%s succeeded", c.Method())
+		}
+		return response
+	}
+}
+
+// applyLogLevel overrides the level of the logs with the one of the committed
+// configuration, or restores the default level if it isn't configured. The
+// configuration is read before the invocation changes it, and in every
+// invocation, so all endorsers record the same reads, but the level is only
+// applied again when the configuration's revision changes. Init and
+// UpdateConfig don't apply it, as their changes may not be committed
+func (cf *configurable) applyLogLevel(stub shim.ChaincodeStubInterface) {
+	config, err := cf.config(stub)
+	if err != nil {
+		cf.logger.For(stub).Warningf("Ignoring log level: %s", err.Error())
+		return
+	}
+
+	cf.level.Lock()
+	defer cf.level.Unlock()
+
+	if cf.applied && cf.revision == config.Revision {
+		return
+	}
+
+	if err := cf.logger.OverrideLevel(config.LogLevel); err != nil {
+		cf.logger.For(stub).Warningf("Ignoring log level: %s", err.Error())
+	}
+	cf.applied, cf.revision = true, config.Revision
+}
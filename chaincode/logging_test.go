@@ -0,0 +1,91 @@
+package chaincode_test
+
+import (
+	"bytes"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cdtlab19/coffee-chaincode/chaincode"
+	"github.com/cdtlab19/coffee-chaincode/logging"
+	"github.com/cdtlab19/coffee-chaincode/mockstub"
+)
+
+var _ = Describe("Logging", func() {
+	var mock *mockstub.Stub
+	var out *bytes.Buffer
+
+	BeforeEach(func() {
+		out = &bytes.Buffer{}
+		logger := logging.New("coffee", logging.WithOutput(out), logging.WithLevel(shim.LogInfo))
+		mock = mockstub.NewStub("coffee", NewCoffeeChaincode(logger))
+		Expect(mock.SetCreator("Org1MSP", "someone", nil)).To(Succeed())
+	})
+
+	It("Should log the result of each invocation", func() {
+		mock.MockInvoke("0001", [][]byte{[]byte("CreateCoffee"), []byte("mocha")})
+		Expect(out.String()).To(ContainSubstring("INFO [coffee] CreateCoffee succeeded txId=0001"))
+		Expect(out.String()).To(ContainSubstring("function=CreateCoffee mspId=Org1MSP status=200 durationMs="))
+
+		out.Reset()
+		mock.MockInvoke("0002", [][]byte{[]byte("GetCoffee"), []byte("missing")})
+		Expect(out.String()).To(ContainSubstring("NOTICE [coffee] GetCoffee failed:"))
+		Expect(out.String()).To(ContainSubstring("status=404"))
+	})
+
+	It("Should apply the configured log level", func() {
+		result := mock.MockInit("0000", [][]byte{[]byte("init"), []byte(`{"logLevel":"DEBUG"}`)})
+		Expect(int(result.Status)).To(Equal(shim.OK))
+
+		out.Reset()
+		mock.MockInvoke("0001", [][]byte{[]byte("GetCoffee"), []byte("missing")})
+		Expect(out.String()).To(ContainSubstring("DEBUG [coffee] Get: searching for coffee"))
+		Expect(out.String()).To(ContainSubstring("txId=0001"))
+
+		result = mock.MockInit("0002", [][]byte{[]byte("init"), []byte(`{"logLevel":"WARNING"}`)})
+		Expect(int(result.Status)).To(Equal(shim.OK))
+
+		out.Reset()
+		mock.MockInvoke("0003", [][]byte{[]byte("CreateCoffee"), []byte("mocha")})
+		Expect(out.String()).NotTo(ContainSubstring("CreateCoffee succeeded"))
+
+		Expect(mock.SetCreator("Org1MSP", "admin", map[string]string{
+			AdminAttribute: "true",
+		})).To(Succeed())
+		result = mock.MockInvoke("0004", [][]byte{[]byte("UpdateConfig"), []byte(`{"logLevel":""}`)})
+		Expect(int(result.Status)).To(Equal(shim.OK))
+
+		out.Reset()
+		mock.MockInvoke("0005", [][]byte{[]byte("CreateCoffee"), []byte("latte")})
+		Expect(out.String()).To(ContainSubstring("INFO [coffee] CreateCoffee succeeded txId=0005"))
+	})
+
+	It("Should only apply the level once the configuration is committed", func() {
+		Expect(mock.SetCreator("Org1MSP", "admin", map[string]string{
+			AdminAttribute: "true",
+		})).To(Succeed())
+
+		result := mock.MockInvoke("0001", [][]byte{[]byte("UpdateConfig"), []byte(`{"logLevel":"WARNING"}`)})
+		Expect(int(result.Status)).To(Equal(shim.OK))
+		Expect(out.String()).To(ContainSubstring("INFO [coffee] UpdateConfig succeeded txId=0001"))
+
+		out.Reset()
+		mock.MockInvoke("0002", [][]byte{[]byte("CreateCoffee"), []byte("mocha")})
+		Expect(out.String()).To(BeEmpty())
+	})
+
+	It("Should apply the committed level after restarts", func() {
+		result := mock.MockInit("0000", [][]byte{[]byte("init"), []byte(`{"logLevel":"WARNING"}`)})
+		Expect(int(result.Status)).To(Equal(shim.OK))
+
+		restarted := &bytes.Buffer{}
+		logger := logging.New("coffee", logging.WithOutput(restarted), logging.WithLevel(shim.LogInfo))
+		other := mockstub.NewStub("coffee", NewCoffeeChaincode(logger))
+		other.State = mock.State
+		other.Creator = mock.Creator
+
+		other.MockInvoke("0001", [][]byte{[]byte("CreateCoffee"), []byte("mocha")})
+		Expect(restarted.String()).To(BeEmpty())
+	})
+})
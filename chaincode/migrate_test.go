@@ -0,0 +1,182 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cdtlab19/coffee-chaincode/chaincode"
+	"github.com/cdtlab19/coffee-chaincode/logging"
+	"github.com/cdtlab19/coffee-chaincode/mockstub"
+	"github.com/cdtlab19/coffee-chaincode/model"
+	"github.com/cdtlab19/coffee-chaincode/store"
+	"github.com/cdtlab19/coffee-chaincode/utils"
+)
+
+var _ = Describe("Migrate", func() {
+	var mock *mockstub.Stub
+	var logger *logging.Logger
+
+	// putRaw stores a document exactly as it was written by older versions
+	putRaw := func(docType, id, data string) {
+		key, _ := mock.CreateCompositeKey(docType, []string{id})
+		mock.MockTransactionStart("raw")
+		defer mock.MockTransactionEnd("raw")
+		Expect(mock.PutState(key, []byte(data))).To(Succeed())
+	}
+
+	// data returns the data of an enveloped response
+	data := func(result pb.Response, response interface{}) {
+		Expect(int(result.Status)).To(Equal(shim.OK), result.Message)
+
+		var envelope utils.Envelope
+		Expect(json.Unmarshal(result.Payload, &envelope)).To(Succeed())
+		Expect(json.Unmarshal(envelope.Data, response)).To(Succeed())
+	}
+
+	pending := func(args ...string) *store.ScanResult {
+		invokeArgs := [][]byte{[]byte("PendingMigrations")}
+		for _, arg := range args {
+			invokeArgs = append(invokeArgs, []byte(arg))
+		}
+
+		response := &store.ScanResult{}
+		data(mock.MockInvoke("pending", invokeArgs), response)
+		return response
+	}
+
+	migrate := func(ids []string) *store.RewriteResult {
+		raw, _ := json.Marshal(ids)
+
+		response := &store.RewriteResult{}
+		data(mock.MockInvoke("migrate", [][]byte{[]byte("Migrate"), raw}), response)
+		return response
+	}
+
+	BeforeEach(func() {
+		logger = logging.New("migrate-test")
+	})
+
+	Context("Users", func() {
+		var st *store.UserStore
+
+		BeforeEach(func() {
+			mock = mockstub.NewStub("user", NewUserChaincode(logger))
+			st = store.NewUserStore(mock, logger)
+			Expect(mock.SetCreator("Org1MSP", "admin", map[string]string{
+				AdminAttribute: "true",
+			})).To(Succeed())
+
+			putRaw(model.UserDocType, "0000", `{"docType":"user","id":"0000","name":"someone","remainingCoffee":3}`)
+			putRaw(model.UserDocType, "0001", `{"docType":"user","id":"0001","name":"anyone","remainingCoffee":2}`)
+			createTestUser(mock, st, model.NewUser("0002", 1))
+		})
+
+		It("Should upgrade old users on read", func() {
+			result := mock.MockInvoke("0000", [][]byte{[]byte("GetUser"), []byte("0000")})
+			Expect(int(result.Status)).To(Equal(shim.OK))
+
+			var response struct {
+				User *model.User `json:"user"`
+			}
+			Expect(json.Unmarshal(result.Payload, &response)).To(Succeed())
+			Expect(response.User.SchemaVersion).To(Equal(model.UserSchemaVersion))
+			Expect(response.User.RemainingCoffee).To(Equal(3))
+			Expect(string(result.Payload)).NotTo(ContainSubstring("someone"))
+		})
+
+		It("Should reject unknown schema versions", func() {
+			putRaw(model.UserDocType, "0003", `{"docType":"user","schemaVersion":99,"id":"0003"}`)
+
+			result := mock.MockInvoke("0000", [][]byte{[]byte("GetUser"), []byte("0003")})
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+		})
+
+		It("Should migrate users listed in pages", func() {
+			scan := pending("2")
+			Expect(scan.Scanned).To(Equal(2))
+			Expect(scan.IDs).To(Equal([]string{"0000", "0001"}))
+			Expect(scan.Bookmark).NotTo(BeEmpty())
+			Expect(scan.Done).To(BeFalse())
+
+			Expect(migrate(scan.IDs)).To(Equal(&store.RewriteResult{Changed: 2}))
+
+			scan = pending("2", scan.Bookmark)
+			Expect(scan.Scanned).To(Equal(1))
+			Expect(scan.IDs).To(BeEmpty())
+			Expect(scan.Done).To(BeTrue())
+
+			Expect(pending("10").IDs).To(BeEmpty())
+			Expect(migrate([]string{"0000", "0002", "0009"})).To(Equal(&store.RewriteResult{Unchanged: 3}))
+
+			for _, value := range mock.State {
+				Expect(string(value)).NotTo(ContainSubstring("someone"))
+			}
+
+			info, err := st.GetUserInfo("0000")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Name).To(Equal("someone"))
+
+			user, err := st.GetUser("0000")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(user.InfoHash).To(Equal(info.Hash()))
+		})
+
+		It("Should skip users which would be invalid once migrated", func() {
+			putRaw(model.UserDocType, "0003", `{"docType":"user","id":"0003","name":"someone","remainingCoffee":-1}`)
+
+			scan := pending("10")
+			Expect(scan.IDs).To(Equal([]string{"0000", "0001", "0003"}))
+
+			Expect(migrate(scan.IDs)).To(Equal(&store.RewriteResult{Changed: 2, Invalid: []string{"0003"}}))
+			Expect(pending("10").IDs).To(Equal([]string{"0003"}))
+
+			_, err := st.GetUserInfo("0003")
+			Expect(store.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("Should only be called by administrators", func() {
+			Expect(mock.SetCreator("Org1MSP", "someone", nil)).To(Succeed())
+
+			result := mock.MockInvoke("0000", [][]byte{[]byte("PendingMigrations"), []byte("10")})
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+
+			result = mock.MockInvoke("0000", [][]byte{[]byte("Migrate"), []byte(`["0000"]`)})
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+		})
+	})
+
+	Context("Coffees", func() {
+		BeforeEach(func() {
+			mock = mockstub.NewStub("coffee", NewCoffeeChaincode(logger))
+			Expect(mock.SetCreator("Org1MSP", "admin", map[string]string{
+				AdminAttribute: "true",
+			})).To(Succeed())
+
+			putRaw(model.CoffeeDocType, "0000", `{"docType":"coffee","id":"0000","flavour":"cappuccino","owner":""}`)
+		})
+
+		It("Should migrate coffees", func() {
+			scan := pending("10")
+			Expect(scan.IDs).To(Equal([]string{"0000"}))
+			Expect(scan.Done).To(BeTrue())
+
+			Expect(migrate(scan.IDs)).To(Equal(&store.RewriteResult{Changed: 1}))
+
+			key, _ := mock.CreateCompositeKey(model.CoffeeDocType, []string{"0000"})
+
+			var coffee model.Coffee
+			Expect(json.Unmarshal(mock.State[key], &coffee)).To(Succeed())
+			Expect(coffee.SchemaVersion).To(Equal(model.CoffeeSchemaVersion))
+			Expect(coffee.Flavour).To(Equal("cappuccino"))
+		})
+
+		It("Should reject invalid page sizes", func() {
+			result := mock.MockInvoke("0000", [][]byte{[]byte("PendingMigrations"), []byte("0")})
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+		})
+	})
+})
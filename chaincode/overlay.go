@@ -0,0 +1,305 @@
+package chaincode
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/ledger/queryresult"
+)
+
+// publicState is the collection name used by an overlay for the public state
+const publicState = ""
+
+// write is a buffered write to a key, either a new value or a deletion
+type write struct {
+	value   []byte
+	deleted bool
+}
+
+// overlayStub buffers the writes of an invocation over another stub, so they
+// may be discarded or flushed to it. Reads return the buffered writes, unlike
+// Fabric, which doesn't read it's own writes within a transaction. Paginated
+// and rich queries aren't buffered, and read the other stub directly
+type overlayStub struct {
+	shim.ChaincodeStubInterface
+	writes     map[string]map[string]*write
+	parameters map[string]map[string][]byte
+}
+
+// newOverlayStub creates an overlayStub buffering writes to `stub`
+func newOverlayStub(stub shim.ChaincodeStubInterface) *overlayStub {
+	return &overlayStub{
+		ChaincodeStubInterface: stub,
+		writes:                 map[string]map[string]*write{},
+		parameters:             map[string]map[string][]byte{},
+	}
+}
+
+func (o *overlayStub) get(collection, key string, read func() ([]byte, error)) ([]byte, error) {
+	if w, ok := o.writes[collection][key]; ok {
+		if w.deleted {
+			return nil, nil
+		}
+		return w.value, nil
+	}
+	return read()
+}
+
+func (o *overlayStub) put(collection, key string, w *write) error {
+	if o.writes[collection] == nil {
+		o.writes[collection] = map[string]*write{}
+	}
+	o.writes[collection][key] = w
+	return nil
+}
+
+func (o *overlayStub) setParameter(collection, key string, ep []byte) error {
+	if o.parameters[collection] == nil {
+		o.parameters[collection] = map[string][]byte{}
+	}
+	o.parameters[collection][key] = ep
+	return nil
+}
+
+// GetState returns the buffered value of a key, or it's value in the stub
+func (o *overlayStub) GetState(key string) ([]byte, error) {
+	return o.get(publicState, key, func() ([]byte, error) {
+		return o.ChaincodeStubInterface.GetState(key)
+	})
+}
+
+// PutState buffers a write to the public state
+func (o *overlayStub) PutState(key string, value []byte) error {
+	return o.put(publicState, key, &write{value: value})
+}
+
+// DelState buffers a deletion from the public state
+func (o *overlayStub) DelState(key string) error {
+	return o.put(publicState, key, &write{deleted: true})
+}
+
+// GetPrivateData returns the buffered value of a private key, or it's value
+// in the stub
+func (o *overlayStub) GetPrivateData(collection, key string) ([]byte, error) {
+	return o.get(collection, key, func() ([]byte, error) {
+		return o.ChaincodeStubInterface.GetPrivateData(collection, key)
+	})
+}
+
+// PutPrivateData buffers a write to a private data collection
+func (o *overlayStub) PutPrivateData(collection, key string, value []byte) error {
+	return o.put(collection, key, &write{value: value})
+}
+
+// DelPrivateData buffers a deletion from a private data collection
+func (o *overlayStub) DelPrivateData(collection, key string) error {
+	return o.put(collection, key, &write{deleted: true})
+}
+
+// GetStateValidationParameter returns the buffered endorsement policy of a
+// key, or it's policy in the stub
+func (o *overlayStub) GetStateValidationParameter(key string) ([]byte, error) {
+	if ep, ok := o.parameters[publicState][key]; ok {
+		return ep, nil
+	}
+	return o.ChaincodeStubInterface.GetStateValidationParameter(key)
+}
+
+// SetStateValidationParameter buffers the endorsement policy of a key
+func (o *overlayStub) SetStateValidationParameter(key string, ep []byte) error {
+	return o.setParameter(publicState, key, ep)
+}
+
+// GetPrivateDataValidationParameter returns the buffered endorsement policy
+// of a private key, or it's policy in the stub
+func (o *overlayStub) GetPrivateDataValidationParameter(collection, key string) ([]byte, error) {
+	if ep, ok := o.parameters[collection][key]; ok {
+		return ep, nil
+	}
+	return o.ChaincodeStubInterface.GetPrivateDataValidationParameter(collection, key)
+}
+
+// SetPrivateDataValidationParameter buffers the endorsement policy of a
+// private key
+func (o *overlayStub) SetPrivateDataValidationParameter(collection, key string, ep []byte) error {
+	return o.setParameter(collection, key, ep)
+}
+
+// GetStateByRange merges the buffered writes between `startKey` and `endKey`
+// into the stub's range query
+func (o *overlayStub) GetStateByRange(startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	iterator, err := o.ChaincodeStubInterface.GetStateByRange(startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+	return o.merge(publicState, iterator, inRange(startKey, endKey))
+}
+
+// GetStateByPartialCompositeKey merges the buffered writes with the same
+// prefix into the stub's partial composite key query
+func (o *overlayStub) GetStateByPartialCompositeKey(objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	iterator, err := o.ChaincodeStubInterface.GetStateByPartialCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix, err := o.CreateCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, err
+	}
+	return o.merge(publicState, iterator, hasPrefix(prefix))
+}
+
+// GetPrivateDataByRange merges the buffered private writes between
+// `startKey` and `endKey` into the stub's range query
+func (o *overlayStub) GetPrivateDataByRange(collection, startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	iterator, err := o.ChaincodeStubInterface.GetPrivateDataByRange(collection, startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+	return o.merge(collection, iterator, inRange(startKey, endKey))
+}
+
+// GetPrivateDataByPartialCompositeKey merges the buffered private writes with
+// the same prefix into the stub's partial composite key query
+func (o *overlayStub) GetPrivateDataByPartialCompositeKey(collection, objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	iterator, err := o.ChaincodeStubInterface.GetPrivateDataByPartialCompositeKey(collection, objectType, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix, err := o.CreateCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, err
+	}
+	return o.merge(collection, iterator, hasPrefix(prefix))
+}
+
+func inRange(startKey, endKey string) func(key string) bool {
+	return func(key string) bool {
+		return key >= startKey && (endKey == "" || key < endKey)
+	}
+}
+
+func hasPrefix(prefix string) func(key string) bool {
+	return func(key string) bool {
+		return strings.HasPrefix(key, prefix)
+	}
+}
+
+// merge reads all results of a query, replacing them by the buffered writes
+// to the keys selected by `match`, in key order
+func (o *overlayStub) merge(collection string, iterator shim.StateQueryIteratorInterface,
+	match func(key string) bool) (shim.StateQueryIteratorInterface, error) {
+	defer iterator.Close()
+
+	values := map[string][]byte{}
+	for iterator.HasNext() {
+		kv, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		values[kv.Key] = kv.Value
+	}
+
+	for key, w := range o.writes[collection] {
+		if !match(key) {
+			continue
+		}
+		if w.deleted {
+			delete(values, key)
+		} else {
+			values[key] = w.value
+		}
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	results := make([]*queryresult.KV, len(keys))
+	for i, key := range keys {
+		results[i] = &queryresult.KV{Key: key, Value: values[key]}
+	}
+	return &resultsIterator{results: results}, nil
+}
+
+// flush applies the buffered writes to the stub, in key order
+func (o *overlayStub) flush() error {
+	stub := o.ChaincodeStubInterface
+
+	for _, collection := range sortedKeys(o.writes) {
+		writes := o.writes[collection]
+		keys := make([]string, 0, len(writes))
+		for key := range writes {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			if err := flushWrite(stub, collection, key, writes[key]); err != nil {
+				return err
+			}
+		}
+	}
+
+	for collection, parameters := range o.parameters {
+		for key, ep := range parameters {
+			var err error
+			if collection == publicState {
+				err = stub.SetStateValidationParameter(key, ep)
+			} else {
+				err = stub.SetPrivateDataValidationParameter(collection, key, ep)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func flushWrite(stub shim.ChaincodeStubInterface, collection, key string, w *write) error {
+	switch {
+	case collection == publicState && w.deleted:
+		return stub.DelState(key)
+	case collection == publicState:
+		return stub.PutState(key, w.value)
+	case w.deleted:
+		return stub.DelPrivateData(collection, key)
+	default:
+		return stub.PutPrivateData(collection, key, w.value)
+	}
+}
+
+func sortedKeys(m map[string]map[string]*write) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// resultsIterator iterates over the merged results of a query
+type resultsIterator struct {
+	results []*queryresult.KV
+	next    int
+}
+
+func (i *resultsIterator) HasNext() bool {
+	return i.next < len(i.results)
+}
+
+func (i *resultsIterator) Next() (*queryresult.KV, error) {
+	kv := i.results[i.next]
+	i.next++
+	return kv, nil
+}
+
+func (i *resultsIterator) Close() error {
+	return nil
+}
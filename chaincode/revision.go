@@ -0,0 +1,22 @@
+package chaincode
+
+import (
+	"github.com/cdtlab19/coffee-chaincode/store"
+	"github.com/vtfr/rocha"
+)
+
+// expectRevision verifies the optional `revision` argument, the revision of
+// an asset when the client read it, against the asset's current revision
+func expectRevision(c rocha.Context, docType, id string, current int) error {
+	expected, ok := c.Get("revision")
+	if !ok || expected.(int) == current {
+		return nil
+	}
+
+	return &store.ConflictError{
+		DocType:  docType,
+		ID:       id,
+		Expected: expected.(int),
+		Actual:   current,
+	}
+}
@@ -0,0 +1,50 @@
+package chaincode
+
+import (
+	"github.com/cdtlab19/coffee-chaincode/store"
+	"github.com/cdtlab19/coffee-chaincode/utils"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/vtfr/rocha"
+)
+
+// PrivateSnapshotKey is the transient map key of the entries of private
+// collections of a snapshot page
+const PrivateSnapshotKey = "privateSnapshot"
+
+// exportSnapshot responds a snapshot page as JSON lines, which can't be
+// responded by utils.RespondJSON
+func exportSnapshot(c rocha.Context, snapshot *store.Snapshot) pb.Response {
+	data, err := snapshot.Export(c.Int("pageSize"), c.String("bookmark"))
+	if err != nil {
+		return utils.Error(err)
+	}
+	return shim.Success(data)
+}
+
+// importSnapshot imports the snapshot page received as the `snapshot`
+// argument, with the entries of private collections received in the
+// transient map
+func importSnapshot(c rocha.Context, snapshot *store.Snapshot) (interface{}, error) {
+	return snapshot.Import([]byte(c.String("snapshot")), []byte(c.String(PrivateSnapshotKey)))
+}
+
+var exportFunction = Function{
+	Name:        "Export",
+	Description: "Returns a page of the chaincode state as JSON lines, starting at the optional `bookmark`",
+	Args:        pageArgs(),
+	Role:        RoleAdmin,
+	Response:    &Schema{Type: "string", Format: FormatJSONLines, Description: "a snapshot header followed by it's entries"},
+}
+
+var importFunction = Function{
+	Name: "Import",
+	Description: "Loads a page exported by Export into an empty chaincode. The entries of private collections " +
+		"are read from the transient map, so they aren't recorded in the transaction",
+	Args: []Argument{stringArg("snapshot", "page returned by Export, without private entries").format(FormatJSONLines)},
+	Transient: []Argument{
+		stringArg(PrivateSnapshotKey, "private entries of the page").format(FormatJSONLines).optional(""),
+	},
+	Role:     RoleAdmin,
+	Response: schemaOf(store.ImportResult{}),
+}
@@ -0,0 +1,76 @@
+package chaincode_test
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cdtlab19/coffee-chaincode/chaincode"
+	"github.com/cdtlab19/coffee-chaincode/logging"
+	"github.com/cdtlab19/coffee-chaincode/mockstub"
+	"github.com/cdtlab19/coffee-chaincode/model"
+	"github.com/cdtlab19/coffee-chaincode/store"
+)
+
+var _ = Describe("Snapshot", func() {
+	var source, target *mockstub.Stub
+	var logger *logging.Logger
+
+	asAdmin := func(mock *mockstub.Stub) {
+		Expect(mock.SetCreator("Org1MSP", "admin", map[string]string{
+			AdminAttribute: "true",
+		})).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		logger = logging.New("snapshot-test")
+		source = mockstub.NewStub("coffee", NewCoffeeChaincode(logger))
+		target = mockstub.NewStub("coffee", NewCoffeeChaincode(logger))
+		asAdmin(source)
+		asAdmin(target)
+
+		source.MockInit("0000", [][]byte{[]byte("init"), []byte(`{"defaultCredits":3}`)})
+		target.MockInit("0000", [][]byte{[]byte("init")})
+
+		st := store.NewCoffeeStore(source, logger)
+		createTestCoffee(source, st, model.NewCoffee("c1", "mocha"))
+		createTestCoffee(source, st, model.NewCoffee("c2", "latte"))
+	})
+
+	It("Should export and import the chaincode state", func() {
+		bookmark := ""
+		for {
+			result := source.MockInvoke("0001", [][]byte{
+				[]byte("Export"), []byte("2"), []byte(bookmark),
+			})
+			Expect(int(result.Status)).To(Equal(shim.OK))
+
+			var header store.SnapshotHeader
+			line := bytes.SplitN(result.Payload, []byte("\n"), 2)[0]
+			Expect(json.Unmarshal(line, &header)).To(Succeed())
+
+			imported := target.MockInvoke("0002", [][]byte{[]byte("Import"), result.Payload})
+			Expect(int(imported.Status)).To(Equal(shim.OK))
+
+			if header.Done {
+				break
+			}
+			bookmark = header.Next
+		}
+
+		Expect(target.State).To(Equal(source.State))
+	})
+
+	It("Should only be called by administrators", func() {
+		Expect(source.SetCreator("Org1MSP", "someone", nil)).To(Succeed())
+
+		result := source.MockInvoke("0001", [][]byte{[]byte("Export"), []byte("10")})
+		Expect(int(result.Status)).To(Equal(shim.ERROR))
+
+		result = source.MockInvoke("0002", [][]byte{[]byte("Import"), []byte("{}")})
+		Expect(int(result.Status)).To(Equal(shim.ERROR))
+	})
+})
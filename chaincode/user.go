@@ -1,74 +1,279 @@
 package chaincode
 
 import (
-	"github.com/vtfr/rocha/argsmw"
+	"errors"
+	"fmt"
 
+	"github.com/cdtlab19/coffee-chaincode/logging"
 	"github.com/cdtlab19/coffee-chaincode/model"
 	"github.com/cdtlab19/coffee-chaincode/store"
-	"github.com/cdtlab19/coffee-chaincode/utils"
 	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/core/chaincode/shim/ext/cid"
 	pb "github.com/hyperledger/fabric/protos/peer"
 	"github.com/vtfr/rocha"
 )
 
 // UserChaincode is a chaincode controller for user assets
 type UserChaincode struct {
-	logger *shim.ChaincodeLogger
-	router *rocha.Router
+	configurable
+	logger          *logging.Logger
+	router          *rocha.Router
+	newStore        UserStoreFactory
+	coffeeChaincode string
+	// coffeeRouter routes the coffee functions in-process when both
+	// chaincodes are combined, instead of invoking the coffee chaincode
+	coffeeRouter *rocha.Router
+}
+
+// UserStoreFactory creates the user repository used by a transaction
+type UserStoreFactory func(stub shim.ChaincodeStubInterface, logger store.Logger) store.UserRepository
+
+// UserOption configures an UserChaincode
+type UserOption func(u *UserChaincode)
+
+// WithUserStore replaces the factory of user repositories, allowing fakes to
+// be used in tests
+func WithUserStore(factory UserStoreFactory) UserOption {
+	return func(u *UserChaincode) {
+		u.newStore = factory
+	}
+}
+
+// WithCoffeeChaincode sets the name of the coffee chaincode, queried for the
+// coffees owned by users. Defaults to "coffee"
+func WithCoffeeChaincode(name string) UserOption {
+	return func(u *UserChaincode) {
+		u.coffeeChaincode = name
+	}
 }
 
 var _ shim.Chaincode = &UserChaincode{}
 
 // NewUserChaincode cria uma nova instância do UserChaincode para gerenciamento de
-// usuários com os parâmetros default
-func NewUserChaincode(logger *shim.ChaincodeLogger) *UserChaincode {
-	chaincode := &UserChaincode{logger: logger}
-	chaincode.router = rocha.NewRouter().
-		// CreateUser creates a new user with a certain amount of remaining coffees
-		Handle("CreateUser",
-			utils.RespondJSON(chaincode.CreateUser),
-			argsmw.Arguments(
-				argsmw.String("name"),
-				argsmw.Int("remainingCoffee", 10))).
-		// GetUser returns an user by it's id
-		Handle("GetUser", utils.RespondJSON(chaincode.GetUser),
-			argsmw.Arguments(argsmw.String("id"))).
-		// DrinkCoffee removes one unit of user's remaining coffees
-		Handle("DrinkCoffee", utils.RespondJSON(chaincode.DrinkCoffee),
-			argsmw.Arguments(argsmw.String("id"))).
-		// AllUser returns all users
-		Handle("AllUser", utils.RespondJSON(chaincode.AllUser)).
-		// DeleteUser deles an user by it's `id`
-		Handle("DeleteUser", utils.RespondJSON(chaincode.DeleteUser),
-			argsmw.Arguments(argsmw.String("id")))
+// usuários com os parâmetros default, alterados pelas opções recebidas
+func NewUserChaincode(logger *logging.Logger, options ...UserOption) *UserChaincode {
+	chaincode := &UserChaincode{
+		configurable: configurable{logger: logger},
+		logger:       logger,
+		newStore: func(stub shim.ChaincodeStubInterface, logger store.Logger) store.UserRepository {
+			return store.NewUserStore(stub, logger)
+		},
+		coffeeChaincode: "coffee",
+	}
+
+	for _, option := range options {
+		option(chaincode)
+	}
+
+	fns := newFunctions(&chaincode.configurable, "user")
+	fns.
+		respond(Function{
+			Name: "CreateUser",
+			Description: "Creates a new user with a certain amount of remaining coffees, or the configured " +
+				"default credits if not set. It's personal information is read from the transient map",
+			Args: []Argument{
+				intArg("remainingCoffee", "user's remaining coffees, or the configured defaultCredits").optional(nil),
+			},
+			Transient: []Argument{
+				stringArg("name", "user's name"),
+				stringArg("email", "user's e-mail").optional(""),
+				stringArg("badge", "user's badge").optional(""),
+				stringArg("salt", "random value of at least 16 characters, which keys the hash of the personal "+
+					"information kept in the public state. Retries may send a new salt").random(),
+			},
+			Idempotent: true,
+			Response:   object("user", model.User{}),
+		}, chaincode.CreateUser).
+		respond(Function{
+			Name:        "GetUser",
+			Description: "Returns an user by it's `id`",
+			Args:        []Argument{stringArg("id", "user's ID")},
+			Response:    object("user", model.User{}),
+		}, chaincode.GetUser).
+		respond(Function{
+			Name:        "GetUserInfo",
+			Description: "Returns an user's private information by it's `id`",
+			Args:        []Argument{stringArg("id", "user's ID")},
+			Response:    object("info", model.UserInfo{}),
+		}, chaincode.GetUserInfo).
+		respond(Function{
+			Name:        "DrinkCoffee",
+			Description: "Removes one unit of user's remaining coffees, if it's still at the optional `revision`",
+			Args: []Argument{
+				stringArg("id", "user's ID"),
+				intArg("revision", "revision the client read, to detect concurrent changes").optional(nil),
+			},
+			Idempotent: true,
+			Response:   object("user", model.User{}),
+		}, chaincode.DrinkCoffee).
+		respond(Function{
+			Name: "TopUp",
+			Description: "Adds purchased `credits` to an user's remaining coffees, if it's still at the " +
+				"optional `revision`. The payment reference is read from the transient map and can only be used once. " +
+				"It may only be called by administrators and payment processors",
+			Args: []Argument{
+				stringArg("id", "user's ID"),
+				intArg("credits", "purchased coffees"),
+				intArg("revision", "revision the client read, to detect concurrent changes").optional(nil),
+			},
+			Transient: []Argument{
+				stringArg("paymentReference", "reference of the payment, kept in the private data collection"),
+			},
+			Role:       RolePayments,
+			Idempotent: true,
+			Envelope:   true,
+			Response:   object("user", model.User{}),
+		}, chaincode.TopUp).
+		respond(Function{
+			Name: "SetPaymentKey",
+			Description: "Sets the secret key of the payments' IDs, read from the transient map. " +
+				"It must be set before TopUp and can only be set once",
+			Transient: []Argument{
+				stringArg("paymentKey", fmt.Sprintf("secret key with at least %d bytes, kept in the private data collection",
+					model.MinPaymentKeySize)),
+			},
+			Role: RoleAdmin,
+		}, chaincode.SetPaymentKey).
+		respond(Function{
+			Name:        "SetUserEndorsement",
+			Description: "Sets the organizations which must endorse changes to an user",
+			Args: []Argument{
+				stringArg("id", "user's ID"),
+				jsonArg("orgs", "JSON array of MSP IDs", &[]string{}),
+			},
+			Role:     RoleAdmin,
+			Response: object("orgs", []string{}),
+		}, chaincode.SetUserEndorsement).
+		respond(Function{
+			Name:        "AllUser",
+			Description: "Returns all users, including deleted ones if `includeDeleted` is set",
+			Args:        []Argument{boolArg("includeDeleted", "include deleted users").optional(false)},
+			Response:    object("users", []model.User{}),
+		}, chaincode.AllUser).
+		respond(Function{
+			Name: "DeleteUser",
+			Description: "Deletes an user by it's `id`, for an optional `reason`. Users with remaining " +
+				"coffees or owned coffees are only deleted with `force`, which closes them",
+			Args: []Argument{
+				stringArg("id", "user's ID"),
+				stringArg("reason", "why the user is deleted").optional(""),
+				boolArg("force", "close users with remaining or owned coffees, for administrators").optional(false),
+			},
+			Idempotent: true,
+		}, chaincode.DeleteUser).
+		respond(Function{
+			Name:        "RestoreUser",
+			Description: "Restores a deleted user by it's `id`",
+			Args:        []Argument{stringArg("id", "user's ID")},
+			Role:        RoleAdmin,
+			Response:    object("user", model.User{}),
+		}, chaincode.RestoreUser).
+		respond(Function{
+			Name:        "PurgeUser",
+			Description: "Erases an user deleted for longer than the configured retention, and it's personal information",
+			Args:        []Argument{stringArg("id", "user's ID")},
+			Role:        RoleAdmin,
+		}, chaincode.PurgeUser).
+		respond(Function{
+			Name: "PendingMigrations",
+			Description: "Lists the IDs of a page of users stored with older schema versions, " +
+				"starting at the optional `bookmark`. It must be called as a query",
+			Args:     pageArgs(),
+			Role:     RoleAdmin,
+			Envelope: true,
+			Response: schemaOf(store.ScanResult{}),
+		}, chaincode.PendingMigrations).
+		respond(Function{
+			Name:        "Migrate",
+			Description: "Rewrites the users with the `ids` listed by PendingMigrations to the current schema version",
+			Args:        []Argument{jsonArg("ids", "JSON array of user IDs", &[]string{})},
+			Role:        RoleAdmin,
+			Envelope:    true,
+			Response:    schemaOf(store.RewriteResult{}),
+		}, chaincode.Migrate).
+		respond(expiredRequestsFunction, chaincode.ExpiredRequests).
+		respond(expireRequestsFunction, chaincode.ExpireRequests).
+		respond(batchFunction, chaincode.Batch).
+		handle(exportFunction, chaincode.Export).
+		respond(importFunction, chaincode.Import).
+		respond(getConfigFunction, chaincode.GetConfig).
+		respond(updateConfigFunction, chaincode.UpdateConfig).
+		respond(describeFunction, fns.Describe)
+	chaincode.router = fns.router
 
 	return chaincode
 
 }
 
-// Init realiza as operações de inicialização do UserChaincode
+// Init realiza as operações de inicialização do UserChaincode, armazenando a
+// configuração recebida
 func (u *UserChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
-	return shim.Success(nil)
+	return u.initConfig(stub)
 }
 
 // Invoke é chamado toda vez que o Chaicode é invocado
 func (u *UserChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	stub, err := invocationStub(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
 	fn, args := stub.GetFunctionAndParameters()
 	return u.router.Invoke(stub, fn, args)
 }
 
 // store
-func (u *UserChaincode) store(stub shim.ChaincodeStubInterface) *store.UserStore {
-	return store.NewUserStore(stub, u.logger)
+func (u *UserChaincode) store(stub shim.ChaincodeStubInterface) store.UserRepository {
+	return u.newStore(stub, u.logger.For(stub))
 }
 
-// CreateUser cria um novo usuário
+// CreateUser cria um novo usuário. Os dados pessoais (`name`, `email` e
+// `badge`) são lidos do transient map e armazenados na coleção privada, com o
+// `salt` aleatório do hash mantido no estado público.
+// Alterações no usuário devem ser endossadas pela organização que o criou
 func (u *UserChaincode) CreateUser(c rocha.Context) (interface{}, error) {
 	stub := c.Stub()
 
-	user := model.NewUser(stub.GetTxID(), c.String("name"), c.Int("remainingCoffee"))
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return nil, err
+	}
 
-	if err := u.store(stub).SetUser(user); err != nil {
+	info := model.NewUserInfo(stub.GetTxID(), c.String("name"), c.String("email"), c.String("badge"))
+	info.Salt = c.String("salt")
+
+	config, err := u.config(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	remainingCoffee := config.DefaultCredits
+	if _, ok := c.Get("remainingCoffee"); ok {
+		remainingCoffee = c.Int("remainingCoffee")
+	}
+
+	if remainingCoffee > config.MaxCredits {
+		return nil, fmt.Errorf("user can't have more than %d remaining coffees", config.MaxCredits)
+	}
+
+	user := model.NewUser(stub.GetTxID(), remainingCoffee)
+	user.SetInfo(info)
+
+	// valida os dados pessoais antes de criar o usuário
+	if err := info.Valid(); err != nil {
+		return nil, err
+	}
+
+	st := u.store(stub)
+	if err := st.CreateUser(user); err != nil {
+		return nil, err
+	}
+
+	if err := st.SetUserInfo(info); err != nil {
+		return nil, err
+	}
+
+	if err := st.SetUserEndorsement(user.ID, mspID); err != nil {
 		return nil, err
 	}
 
@@ -77,6 +282,28 @@ func (u *UserChaincode) CreateUser(c rocha.Context) (interface{}, error) {
 	}{user}, nil
 }
 
+// SetUserEndorsement altera as organizações que devem endossar alterações em
+// um usuário
+func (u *UserChaincode) SetUserEndorsement(c rocha.Context) (interface{}, error) {
+	orgs := *c.Value("orgs").(*[]string)
+	if len(orgs) == 0 {
+		return nil, errors.New("at least one organization must endorse an user")
+	}
+
+	st := u.store(c.Stub())
+	if _, err := st.GetUser(c.String("id")); err != nil {
+		return nil, err
+	}
+
+	if err := st.SetUserEndorsement(c.String("id"), orgs...); err != nil {
+		return nil, err
+	}
+
+	return struct {
+		Orgs []string `json:"orgs"`
+	}{orgs}, nil
+}
+
 // GetUser retorna um usuário
 func (u *UserChaincode) GetUser(c rocha.Context) (interface{}, error) {
 	user, err := u.store(c.Stub()).GetUser(c.String("id"))
@@ -89,6 +316,18 @@ func (u *UserChaincode) GetUser(c rocha.Context) (interface{}, error) {
 	}{user}, nil
 }
 
+// GetUserInfo retorna os dados pessoais de um usuário
+func (u *UserChaincode) GetUserInfo(c rocha.Context) (interface{}, error) {
+	info, err := u.store(c.Stub()).GetUserInfo(c.String("id"))
+	if err != nil {
+		return nil, err
+	}
+
+	return struct {
+		Info *model.UserInfo `json:"info"`
+	}{info}, nil
+}
+
 // DrinkCoffee retira uma unidade dos cafés restantes
 func (u *UserChaincode) DrinkCoffee(c rocha.Context) (interface{}, error) {
 	// retrieves the store
@@ -99,11 +338,15 @@ func (u *UserChaincode) DrinkCoffee(c rocha.Context) (interface{}, error) {
 		return nil, err
 	}
 
+	if err := expectRevision(c, model.UserDocType, user.ID, user.Revision); err != nil {
+		return nil, err
+	}
+
 	if err = user.DrinkCoffee(); err != nil {
 		return nil, err
 	}
 
-	if err = st.SetUser(user); err != nil {
+	if err = st.UpdateUser(user); err != nil {
 		return nil, err
 	}
 
@@ -112,9 +355,68 @@ func (u *UserChaincode) DrinkCoffee(c rocha.Context) (interface{}, error) {
 	}{user}, nil
 }
 
+// TopUp adiciona créditos comprados aos cafés restantes de um usuário. A
+// referência do pagamento é lida do transient map e armazenada na coleção
+// privada, e cada referência só pode ser usada uma vez
+func (u *UserChaincode) TopUp(c rocha.Context) (interface{}, error) {
+	stub := c.Stub()
+	st := u.store(stub)
+
+	user, err := st.GetUser(c.String("id"))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := expectRevision(c, model.UserDocType, user.ID, user.Revision); err != nil {
+		return nil, err
+	}
+
+	config, err := u.config(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := user.TopUp(c.Int("credits"), config.MaxCredits); err != nil {
+		return nil, err
+	}
+
+	key, err := st.GetPaymentKey()
+	if store.IsNotFound(err) {
+		return nil, errors.New("the payment key wasn't set")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := txTime(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	payment := model.NewPayment(key, c.String("paymentReference"), user.ID, c.Int("credits"), now)
+	if err := st.CreatePayment(payment); err != nil {
+		return nil, err
+	}
+
+	if err := st.UpdateUser(user); err != nil {
+		return nil, err
+	}
+
+	return struct {
+		User *model.User `json:"user"`
+	}{user}, nil
+}
+
+// SetPaymentKey armazena a chave secreta dos IDs dos pagamentos, lida do
+// transient map, na coleção privada. A chave só pode ser definida uma vez
+func (u *UserChaincode) SetPaymentKey(c rocha.Context) (interface{}, error) {
+	return nil, u.store(c.Stub()).SetPaymentKey(model.NewPaymentKey([]byte(c.String("paymentKey"))))
+}
+
 // AllUser retorna todos os usuários
 func (u *UserChaincode) AllUser(c rocha.Context) (interface{}, error) {
-	users, err := u.store(c.Stub()).AllUser()
+	includeDeleted, _ := c.Get("includeDeleted")
+	users, err := u.store(c.Stub()).AllUser(includeDeleted == true)
 	if err != nil {
 		return nil, err
 	}
@@ -124,7 +426,119 @@ func (u *UserChaincode) AllUser(c rocha.Context) (interface{}, error) {
 	}{users}, nil
 }
 
-// DeleteUser deleta um usuário
+// DeleteUser marca um usuário como deletado, registrando quem o deletou,
+// quando e por quê. Os dados pessoais são mantidos até o expurgo.
+//
+// Usuários com cafés restantes ou donos de cafés só são deletados com
+// `force`, permitido apenas a administradores, que zera os cafés restantes,
+// deleta os cafés e registra as ações no tombstone
 func (u *UserChaincode) DeleteUser(c rocha.Context) (interface{}, error) {
-	return nil, u.store(c.Stub()).DeleteUser(c.String("id"))
+	stub := c.Stub()
+	st := u.store(stub)
+
+	user, err := st.GetUser(c.String("id"))
+	if err != nil {
+		return nil, err
+	}
+
+	outstanding, err := u.outstanding(stub, user)
+	if err != nil {
+		return nil, err
+	}
+
+	tombstone, err := tombstone(stub, c.String("reason"))
+	if err != nil {
+		return nil, err
+	}
+
+	if outstanding != nil {
+		if force, _ := c.Get("force"); force != true {
+			return nil, outstanding
+		}
+
+		admin, err := u.isAdmin(stub)
+		if err != nil {
+			return nil, err
+		}
+		if !admin {
+			return nil, errors.New("Permission denied: only administrators may force deletions")
+		}
+
+		if user.RemainingCoffee > 0 {
+			tombstone.Record("forfeited %d remaining coffees", user.RemainingCoffee)
+			user.RemainingCoffee = 0
+		}
+
+		for _, coffeeID := range outstanding.Coffees {
+			if err := u.closeCoffee(stub, coffeeID, user.ID); err != nil {
+				return nil, err
+			}
+			tombstone.Record("deleted owned coffee '%s'", coffeeID)
+		}
+	}
+
+	// o Fabric não lê as escritas da própria transação, então o usuário é
+	// alterado e deletado em uma única escrita
+	if err := user.Delete(tombstone); err != nil {
+		return nil, err
+	}
+
+	return nil, st.UpdateUser(user)
+}
+
+// RestoreUser restaura um usuário deletado
+func (u *UserChaincode) RestoreUser(c rocha.Context) (interface{}, error) {
+	st := u.store(c.Stub())
+	if err := st.RestoreUser(c.String("id")); err != nil {
+		return nil, err
+	}
+
+	user, err := st.GetUser(c.String("id"))
+	if err != nil {
+		return nil, err
+	}
+
+	return struct {
+		User *model.User `json:"user"`
+	}{user}, nil
+}
+
+// PurgeUser apaga definitivamente um usuário deletado há mais tempo que a
+// retenção configurada, junto com seus dados pessoais
+func (u *UserChaincode) PurgeUser(c rocha.Context) (interface{}, error) {
+	stub := c.Stub()
+
+	retention, now, err := u.retention(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, u.store(stub).PurgeUser(c.String("id"), retention, now)
+}
+
+// Batch executa várias operações do chaincode em uma única transação
+func (u *UserChaincode) Batch(c rocha.Context) (interface{}, error) {
+	return runBatch(c, u.router.Invoke)
+}
+
+// Export exporta uma página do estado do chaincode
+func (u *UserChaincode) Export(c rocha.Context) pb.Response {
+	return exportSnapshot(c, store.NewUserSnapshot(c.Stub(), u.logger.For(c.Stub())))
+}
+
+// Import importa uma página exportada por Export
+func (u *UserChaincode) Import(c rocha.Context) (interface{}, error) {
+	return importSnapshot(c, store.NewUserSnapshot(c.Stub(), u.logger.For(c.Stub())))
+}
+
+// PendingMigrations lista uma página de usuários armazenados em versões
+// antigas
+func (u *UserChaincode) PendingMigrations(c rocha.Context) (interface{}, error) {
+	return u.store(c.Stub()).PendingUserMigrations(c.Int("pageSize"), c.String("bookmark"))
+}
+
+// Migrate atualiza os usuários listados por PendingMigrations, movendo os
+// dados pessoais para a coleção privada
+func (u *UserChaincode) Migrate(c rocha.Context) (interface{}, error) {
+	return u.store(c.Stub()).MigrateUser(*c.Value("ids").(*[]string))
 }
@@ -3,25 +3,36 @@ package chaincode_test
 import (
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"strings"
 
+	"github.com/cdtlab19/coffee-chaincode/mockstub"
 	"github.com/cdtlab19/coffee-chaincode/model"
 	"github.com/cdtlab19/coffee-chaincode/store"
+	"github.com/cdtlab19/coffee-chaincode/utils"
 	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	. "github.com/cdtlab19/coffee-chaincode/chaincode"
+	"github.com/cdtlab19/coffee-chaincode/logging"
 )
 
 var _ = Describe("User", func() {
-	var mock *shim.MockStub
-	var logger *shim.ChaincodeLogger
+	var mock *mockstub.Stub
+	var coffeeMock *mockstub.Stub
+	var logger *logging.Logger
 	var st *store.UserStore
 
 	BeforeEach(func() {
-		logger = shim.NewLogger("user-test")
-		mock = shim.NewMockStub("user", NewUserChaincode(logger))
+		logger = logging.New("user-test")
+		mock = mockstub.NewStub("user", NewUserChaincode(logger))
 		st = store.NewUserStore(mock, logger)
+		Expect(mock.SetCreator("Org1MSP", "someone", nil)).To(Succeed())
+
+		coffeeMock = mockstub.NewStub("coffee", NewCoffeeChaincode(logger))
+		mock.MockPeerChaincode("coffee", coffeeMock)
 	})
 
 	It("Should Init", func() {
@@ -34,10 +45,14 @@ var _ = Describe("User", func() {
 		const method = "CreateUser"
 
 		It("Shoud create an user", func() {
-			result := mock.MockInvoke("0000", [][]byte{
+			result := mock.MockInvokeWithTransient("0000", [][]byte{
 				[]byte(method),
-				[]byte("name"),
 				[]byte("3"),
+			}, map[string][]byte{
+				"name":  []byte("name"),
+				"email": []byte("name@example.com"),
+				"badge": []byte("42"),
+				"salt":  []byte(testSalt),
 			})
 
 			Expect(int(result.Status)).To(Equal(shim.OK))
@@ -48,14 +63,212 @@ var _ = Describe("User", func() {
 			}
 
 			Expect(json.Unmarshal(result.Payload, &response)).ToNot(HaveOccurred())
-			Expect(response.User.Name).To(Equal("name"))
 			Expect(response.User.ID).To(Equal("0000"))
 			Expect(response.User.RemainingCoffee).To(Equal(3))
 
 			user, err := st.GetUser("0000")
 			Expect(err).NotTo(HaveOccurred())
-			Expect(user.Name).To(Equal("name"))
 			Expect(user.RemainingCoffee).To(Equal(3))
+
+			info, err := st.GetUserInfo("0000")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Name).To(Equal("name"))
+			Expect(info.Email).To(Equal("name@example.com"))
+			Expect(info.Badge).To(Equal("42"))
+			Expect(user.InfoHash).To(Equal(info.Hash()))
+		})
+
+		It("Should not create an user twice with the same ID", func() {
+			createTestUser(mock, st, model.NewUser("0000", 5))
+
+			result := mock.MockInvokeWithTransient("0000", [][]byte{
+				[]byte(method),
+				[]byte("3"),
+			}, personalInfo("name"))
+			Expect(int(result.Status)).To(Equal(http.StatusConflict))
+
+			user, err := st.GetUser("0000")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(user.RemainingCoffee).To(Equal(5))
+		})
+
+		It("Should require endorsement from the creator's organization", func() {
+			result := mock.MockInvokeWithTransient("0000", [][]byte{
+				[]byte(method),
+				[]byte("3"),
+			}, personalInfo("name"))
+			Expect(int(result.Status)).To(Equal(shim.OK))
+
+			key, _ := mock.CreateCompositeKey(model.UserDocType, []string{"0000"})
+			policy, err := mock.GetStateValidationParameter(key)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policy).NotTo(BeEmpty())
+
+			orgs, err := st.GetUserEndorsement("0000")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(orgs).To(ConsistOf("Org1MSP"))
+		})
+
+		It("Should not store personal information in the public state", func() {
+			result := mock.MockInvokeWithTransient("0000", [][]byte{
+				[]byte(method),
+				[]byte("3"),
+			}, personalInfo("secret-name"))
+			Expect(int(result.Status)).To(Equal(shim.OK))
+			Expect(string(result.Payload)).NotTo(ContainSubstring("secret-name"))
+
+			for _, value := range mock.State {
+				Expect(string(value)).NotTo(ContainSubstring("secret-name"))
+			}
+		})
+
+		It("Should use the configured default credits", func() {
+			mock.MockInit("init", [][]byte{[]byte("init"), []byte(`{"defaultCredits":7}`)})
+
+			result := mock.MockInvokeWithTransient("0000", [][]byte{
+				[]byte(method),
+			}, personalInfo("name"))
+			Expect(int(result.Status)).To(Equal(shim.OK))
+
+			user, err := st.GetUser("0000")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(user.RemainingCoffee).To(Equal(7))
+		})
+
+		It("Should not exceed the configured max credits", func() {
+			mock.MockInit("init", [][]byte{[]byte("init"), []byte(`{"maxCredits":20}`)})
+
+			result := mock.MockInvokeWithTransient("0000", [][]byte{
+				[]byte(method),
+				[]byte("21"),
+			}, personalInfo("name"))
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+		})
+
+		It("Should require a name in the transient map", func() {
+			result := mock.MockInvoke("0000", [][]byte{
+				[]byte(method),
+				[]byte("3"),
+			})
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+			Expect(result.Message).To(ContainSubstring("name: missing"))
+			Expect(result.Message).To(ContainSubstring("salt: missing"))
+		})
+
+		It("Should reject negative credits", func() {
+			result := mock.MockInvokeWithTransient("0000", [][]byte{
+				[]byte(method),
+				[]byte("-1"),
+			}, personalInfo("name"))
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+			Expect(result.Message).To(ContainSubstring("remainingCoffee"))
+		})
+
+		It("Should reject a name too long", func() {
+			result := mock.MockInvokeWithTransient("0000", [][]byte{
+				[]byte(method),
+				[]byte("3"),
+			}, personalInfo(strings.Repeat("a", 65)))
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+			Expect(result.Message).To(ContainSubstring("name"))
+		})
+
+		It("Should report every invalid personal field", func() {
+			result := mock.MockInvokeWithTransient("0000", [][]byte{
+				[]byte(method),
+				[]byte("3"),
+			}, map[string][]byte{
+				"name":  []byte(" "),
+				"email": []byte("not-an-email"),
+				"badge": []byte("#1"),
+				"salt":  []byte("short"),
+			})
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+			Expect(result.Message).To(ContainSubstring("name: "))
+			Expect(result.Message).To(ContainSubstring("email: "))
+			Expect(result.Message).To(ContainSubstring("badge: "))
+			Expect(result.Message).To(ContainSubstring("salt: "))
+
+			_, err := st.GetUser("0000")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("SetUserEndorsement", func() {
+		const method = "SetUserEndorsement"
+
+		BeforeEach(func() {
+			createTestUser(mock, st, model.NewUser("0000", 3))
+		})
+
+		It("Should only be called by administrators", func() {
+			result := mock.MockInvoke("0000", [][]byte{
+				[]byte(method),
+				[]byte("0000"),
+				[]byte(`["Org2MSP"]`),
+			})
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+		})
+
+		It("Should change the user's endorsement policy", func() {
+			Expect(mock.SetCreator("Org1MSP", "admin", map[string]string{
+				AdminAttribute: "true",
+			})).To(Succeed())
+
+			result := mock.MockInvoke("0000", [][]byte{
+				[]byte(method),
+				[]byte("0000"),
+				[]byte(`["Org1MSP","Org2MSP"]`),
+			})
+			Expect(int(result.Status)).To(Equal(shim.OK))
+
+			orgs, err := st.GetUserEndorsement("0000")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(orgs).To(ConsistOf("Org1MSP", "Org2MSP"))
+		})
+
+		It("Should require at least one organization", func() {
+			Expect(mock.SetCreator("Org1MSP", "admin", map[string]string{
+				AdminAttribute: "true",
+			})).To(Succeed())
+
+			result := mock.MockInvoke("0000", [][]byte{
+				[]byte(method),
+				[]byte("0000"),
+				[]byte(`[]`),
+			})
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+		})
+	})
+
+	Context("GetUserInfo", func() {
+		const method = "GetUserInfo"
+
+		It("Should return error if no user info was found", func() {
+			result := mock.MockInvoke("0000", [][]byte{
+				[]byte(method),
+				[]byte("0000"),
+			})
+			Expect(int(result.Status)).To(Equal(http.StatusNotFound))
+		})
+
+		It("Should return the user's private information", func() {
+			createTestUser(mock, st, model.NewUser("0000", 3))
+			createTestUserInfo(mock, st, model.NewUserInfo("0000", "someone", "", ""))
+
+			result := mock.MockInvoke("0000", [][]byte{
+				[]byte(method),
+				[]byte("0000"),
+			})
+			Expect(int(result.Status)).To(Equal(shim.OK))
+
+			var response struct {
+				Info *model.UserInfo `json:"info"`
+			}
+
+			Expect(json.Unmarshal(result.Payload, &response)).NotTo(HaveOccurred())
+			Expect(response.Info.ID).To(Equal("0000"))
+			Expect(response.Info.Name).To(Equal("someone"))
 		})
 	})
 
@@ -67,12 +280,12 @@ var _ = Describe("User", func() {
 				[]byte(method),
 				[]byte("0000"),
 			})
-			Expect(int(result.Status)).To(Equal(shim.ERROR))
+			Expect(int(result.Status)).To(Equal(http.StatusNotFound))
 			Expect(result.Payload).To(BeEmpty())
 		})
 
 		It("Should return an user if it exists", func() {
-			createTestUser(mock, st, model.NewUser("0000", "someone", 3))
+			createTestUser(mock, st, model.NewUser("0000", 3))
 
 			result := mock.MockInvoke("0000", [][]byte{
 				[]byte(method),
@@ -88,7 +301,7 @@ var _ = Describe("User", func() {
 
 			Expect(json.Unmarshal(result.Payload, &response)).NotTo(HaveOccurred())
 			Expect(response.User.ID).To(Equal("0000"))
-			Expect(response.User.Name).To(Equal("someone"))
+			Expect(response.User.RemainingCoffee).To(Equal(3))
 
 		})
 	})
@@ -96,9 +309,9 @@ var _ = Describe("User", func() {
 	Context("AllUser", func() {
 		const method = "AllUser"
 		It("Should return all users", func() {
-			user1 := model.NewUser("0000", "Someone", 3)
-			user2 := model.NewUser("0001", "Anyone", 3)
-			user3 := model.NewUser("0002", "Everybody", 3)
+			user1 := model.NewUser("0000", 3)
+			user2 := model.NewUser("0001", 3)
+			user3 := model.NewUser("0002", 3)
 
 			createTestUser(mock, st, user1)
 			createTestUser(mock, st, user2)
@@ -134,12 +347,12 @@ var _ = Describe("User", func() {
 				[]byte(method),
 				[]byte("0000"),
 			})
-			Expect(int(result.Status)).To(Equal(shim.ERROR))
+			Expect(int(result.Status)).To(Equal(http.StatusNotFound))
 			Expect(result.Payload).To(BeEmpty())
 		})
 
 		It("Should throw an error if there's no coffee available", func() {
-			createTestUser(mock, st, model.NewUser("0000", "someone", 0))
+			createTestUser(mock, st, model.NewUser("0000", 0))
 
 			result := mock.MockInvoke("0000", [][]byte{
 				[]byte(method),
@@ -151,7 +364,7 @@ var _ = Describe("User", func() {
 		})
 
 		It("Shoud drink an unit of it's available coffees", func() {
-			createTestUser(mock, st, model.NewUser("0000", "someone", 3))
+			createTestUser(mock, st, model.NewUser("0000", 3))
 
 			result := mock.MockInvoke("0000", [][]byte{
 				[]byte(method),
@@ -166,22 +379,222 @@ var _ = Describe("User", func() {
 			}
 
 			Expect(json.Unmarshal(result.Payload, &response)).ToNot(HaveOccurred())
-			Expect(response.User.Name).To(Equal("someone"))
 			Expect(response.User.ID).To(Equal("0000"))
 			Expect(response.User.RemainingCoffee).To(Equal(2))
 
 			user, err := st.GetUser("0000")
 			Expect(err).NotTo(HaveOccurred())
-			Expect(user.Name).To(Equal("someone"))
+			Expect(user.RemainingCoffee).To(Equal(2))
+		})
+
+		It("Should return the new revision", func() {
+			createTestUser(mock, st, model.NewUser("0000", 3))
+
+			result := mock.MockInvoke("0000", [][]byte{
+				[]byte(method),
+				[]byte("0000"),
+				[]byte("1"),
+			})
+			Expect(int(result.Status)).To(Equal(shim.OK))
+
+			var response struct {
+				User *model.User `json:"user"`
+			}
+			Expect(json.Unmarshal(result.Payload, &response)).To(Succeed())
+			Expect(response.User.Revision).To(Equal(2))
+		})
+
+		It("Should not drink from an user changed since the expected revision", func() {
+			createTestUser(mock, st, model.NewUser("0000", 3))
+			mock.MockInvoke("0000", [][]byte{[]byte(method), []byte("0000"), []byte("1")})
+
+			result := mock.MockInvoke("0001", [][]byte{
+				[]byte(method),
+				[]byte("0000"),
+				[]byte("1"),
+			})
+			Expect(int(result.Status)).To(Equal(http.StatusConflict))
+			Expect(result.Message).To(ContainSubstring("expected revision 1"))
+
+			user, err := st.GetUser("0000")
+			Expect(err).NotTo(HaveOccurred())
 			Expect(user.RemainingCoffee).To(Equal(2))
 		})
 	})
 
+	Context("SetPaymentKey", func() {
+		setKey := func(txID, key string) pb.Response {
+			return mock.MockInvokeWithTransient(txID, [][]byte{[]byte("SetPaymentKey")},
+				map[string][]byte{"paymentKey": []byte(key)})
+		}
+
+		It("Should only be called by administrators", func() {
+			result := setKey("0001", testSalt)
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+			Expect(result.Message).To(ContainSubstring("Permission denied"))
+		})
+
+		It("Should set the payment key once, privately", func() {
+			Expect(mock.SetCreator("Org1MSP", "admin", map[string]string{
+				AdminAttribute: "true",
+			})).To(Succeed())
+
+			result := setKey("0001", "short")
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+			Expect(result.Message).To(ContainSubstring("key: must have at least"))
+
+			result = setKey("0002", testSalt)
+			Expect(int(result.Status)).To(Equal(shim.OK), result.Message)
+			Expect(int(setKey("0003", "fedcba9876543210").Status)).To(Equal(http.StatusConflict))
+
+			mock.MockTransactionStart("get")
+			defer mock.MockTransactionEnd("get")
+
+			key, err := st.GetPaymentKey()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(key.Key)).To(Equal(testSalt))
+
+			for _, value := range mock.State {
+				Expect(string(value)).NotTo(ContainSubstring(testSalt))
+			}
+		})
+	})
+
+	Context("TopUp", func() {
+		const method = "TopUp"
+
+		topUp := func(txID, credits, reference string) pb.Response {
+			return mock.MockInvokeWithTransient(txID, [][]byte{
+				[]byte(method),
+				[]byte("0000"),
+				[]byte(credits),
+			}, map[string][]byte{"paymentReference": []byte(reference)})
+		}
+
+		BeforeEach(func() {
+			result := mock.MockInit("init", [][]byte{[]byte("init"),
+				[]byte(`{"paymentProcessors":[{"mspId":"Org1MSP","name":"someone"}]}`)})
+			Expect(int(result.Status)).To(Equal(shim.OK), result.Message)
+			setTestPaymentKey(mock, st)
+		})
+
+		It("Should only be called by administrators and payment processors", func() {
+			createTestUser(mock, st, model.NewUser("0000", 3))
+
+			Expect(mock.SetCreator("Org2MSP", "someone", nil)).To(Succeed())
+			result := topUp("0001", "5", "PAY-0001")
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+			Expect(result.Message).To(ContainSubstring("Permission denied"))
+
+			Expect(mock.SetCreator("Org2MSP", "admin", map[string]string{
+				AdminAttribute: "true",
+			})).To(Succeed())
+			Expect(int(topUp("0002", "5", "PAY-0001").Status)).To(Equal(shim.OK))
+		})
+
+		It("Should require the payment key", func() {
+			mock = mockstub.NewStub("user", NewUserChaincode(logger))
+			st = store.NewUserStore(mock, logger)
+			Expect(mock.SetCreator("Org1MSP", "admin", map[string]string{
+				AdminAttribute: "true",
+			})).To(Succeed())
+			createTestUser(mock, st, model.NewUser("0000", 3))
+
+			result := topUp("0001", "5", "PAY-0001")
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+			Expect(result.Message).To(ContainSubstring("the payment key wasn't set"))
+		})
+
+		It("Should return error if no user was found", func() {
+			result := topUp("0001", "5", "PAY-0001")
+			Expect(int(result.Status)).To(Equal(http.StatusNotFound))
+		})
+
+		It("Should add the purchased credits and record the payment privately", func() {
+			createTestUser(mock, st, model.NewUser("0000", 3))
+
+			result := topUp("0001", "5", "PAY-0001")
+			Expect(int(result.Status)).To(Equal(shim.OK))
+
+			var envelope utils.Envelope
+			Expect(json.Unmarshal(result.Payload, &envelope)).To(Succeed())
+			Expect(envelope.APIVersion).To(Equal(utils.APIVersion))
+			Expect(envelope.TxID).To(Equal("0001"))
+			Expect(envelope.Timestamp.IsZero()).To(BeFalse())
+
+			var response struct {
+				User *model.User `json:"user"`
+			}
+			Expect(json.Unmarshal(envelope.Data, &response)).To(Succeed())
+			Expect(response.User.RemainingCoffee).To(Equal(8))
+
+			mock.MockTransactionStart("get")
+			defer mock.MockTransactionEnd("get")
+
+			payment, err := st.GetPayment("PAY-0001")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(payment.UserID).To(Equal("0000"))
+			Expect(payment.Credits).To(Equal(5))
+
+			for _, value := range mock.State {
+				Expect(string(value)).NotTo(ContainSubstring("PAY-0001"))
+			}
+		})
+
+		It("Should require a payment reference in the transient map", func() {
+			createTestUser(mock, st, model.NewUser("0000", 3))
+
+			result := mock.MockInvoke("0001", [][]byte{[]byte(method), []byte("0000"), []byte("5")})
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+			Expect(result.Message).To(Equal("Invalid transient map: paymentReference: missing"))
+		})
+
+		It("Should not use a payment reference twice", func() {
+			createTestUser(mock, st, model.NewUser("0000", 3))
+
+			Expect(int(topUp("0001", "5", "PAY-0001").Status)).To(Equal(shim.OK))
+
+			result := topUp("0002", "5", "PAY-0001")
+			Expect(int(result.Status)).To(Equal(http.StatusConflict))
+
+			var envelope utils.Envelope
+			Expect(json.Unmarshal(result.Payload, &envelope)).To(Succeed())
+			Expect(envelope.Error).To(Equal(&utils.EnvelopeError{Status: http.StatusConflict, Message: result.Message}))
+			Expect(envelope.Data).To(BeEmpty())
+		})
+
+		It("Should not exceed the configured max credits", func() {
+			createTestUser(mock, st, model.NewUser("0000", 95))
+
+			result := topUp("0001", "10", "PAY-0001")
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+			Expect(result.Message).To(ContainSubstring("more than 100 remaining coffees"))
+		})
+
+		It("Should reject invalid payment references", func() {
+			createTestUser(mock, st, model.NewUser("0000", 3))
+
+			result := topUp("0001", "5", "has spaces")
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+			Expect(result.Message).To(ContainSubstring("reference"))
+		})
+	})
+
 	Context("DeleteUser", func() {
 		const method = "DeleteUser"
 
+		It("Should return NotFound if the user doesn't exist", func() {
+			result := mock.MockInvoke("0000", [][]byte{
+				[]byte(method),
+				[]byte("0000"),
+			})
+
+			Expect(int(result.Status)).To(Equal(http.StatusNotFound))
+		})
+
 		It("Should delete an user", func() {
-			createTestUser(mock, st, model.NewUser("0000", "someone", 3))
+			createTestUser(mock, st, model.NewUser("0000", 0))
+			createTestUserInfo(mock, st, model.NewUserInfo("0000", "someone", "", ""))
 
 			result := mock.MockInvoke("0000", [][]byte{
 				[]byte(method),
@@ -191,13 +604,178 @@ var _ = Describe("User", func() {
 			Expect(int(result.Status)).To(Equal(shim.OK))
 			Expect(result.Payload).To(BeEmpty())
 
+			_, err := st.GetUser("0000")
+			Expect(err).To(HaveOccurred())
+
+			// os dados pessoais são mantidos até o expurgo
+			_, err = st.GetUserInfo("0000")
+			Expect(err).NotTo(HaveOccurred())
+
+			users, err := st.AllUser(true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(users).To(HaveLen(1))
+			Expect(users[0].Deleted.DeletedBy).To(Equal("Org1MSP/someone"))
+		})
+
+		Context("with outstanding state", func() {
+			BeforeEach(func() {
+				createTestUser(mock, st, model.NewUser("0000", 3))
+
+				coffees := store.NewCoffeeStore(coffeeMock, logger)
+				owned := model.NewCoffee("0001", "cappuccino")
+				Expect(owned.SetOwner("0000")).To(Succeed())
+				createTestCoffee(coffeeMock, coffees, owned)
+				createTestCoffee(coffeeMock, coffees, model.NewCoffee("0002", "chocolate"))
+			})
+
+			It("Should refuse to delete the user", func() {
+				result := mock.MockInvoke("0000", [][]byte{[]byte(method), []byte("0000")})
+				Expect(int(result.Status)).To(Equal(http.StatusConflict))
+				Expect(result.Message).To(ContainSubstring("3 remaining coffees"))
+				Expect(result.Message).To(ContainSubstring("0001"))
+				Expect(result.Message).NotTo(ContainSubstring("0002"))
+
+				_, err := st.GetUser("0000")
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("Should only allow administrators to force the deletion", func() {
+				result := mock.MockInvoke("0000", [][]byte{
+					[]byte(method), []byte("0000"), []byte(""), []byte("true"),
+				})
+				Expect(int(result.Status)).To(Equal(shim.ERROR))
+				Expect(result.Message).To(ContainSubstring("Permission denied"))
+			})
+
+			It("Should close the outstanding state when forced", func() {
+				Expect(mock.SetCreator("Org1MSP", "admin", map[string]string{
+					AdminAttribute: "true",
+				})).To(Succeed())
+
+				result := mock.MockInvoke("0000", [][]byte{
+					[]byte(method), []byte("0000"), []byte("left"), []byte("true"),
+				})
+				Expect(int(result.Status)).To(Equal(shim.OK))
+
+				users, err := st.AllUser(true)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(users).To(HaveLen(1))
+				Expect(users[0].RemainingCoffee).To(Equal(0))
+				Expect(users[0].Deleted.Actions).To(Equal([]string{
+					"forfeited 3 remaining coffees",
+					"deleted owned coffee '0001'",
+				}))
+
+				coffees, err := store.NewCoffeeStore(coffeeMock, logger).AllCoffee(false)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(coffees).To(HaveLen(1))
+				Expect(coffees[0].ID).To(Equal("0002"))
+			})
+
+			It("Should close several owned coffees of a client request", func() {
+				coffees := store.NewCoffeeStore(coffeeMock, logger)
+				owned := model.NewCoffee("0003", "latte")
+				Expect(owned.SetOwner("0000")).To(Succeed())
+				createTestCoffee(coffeeMock, coffees, owned)
+
+				Expect(mock.SetCreator("Org1MSP", "admin", map[string]string{
+					AdminAttribute: "true",
+				})).To(Succeed())
+
+				args := [][]byte{[]byte(method), []byte("0000"), []byte("left"), []byte("true")}
+				transient := map[string][]byte{RequestIDKey: []byte("req-1")}
+
+				result := mock.MockInvokeWithTransient("0000", args, transient)
+				Expect(int(result.Status)).To(Equal(shim.OK), result.Message)
+
+				remaining, err := coffees.AllCoffee(false)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(remaining).To(HaveLen(1))
+				Expect(remaining[0].ID).To(Equal("0002"))
+
+				// the request is only recorded by the invoked chaincode
+				prefix, _ := coffeeMock.CreateCompositeKey(model.RequestDocType, []string{})
+				for key := range coffeeMock.State {
+					Expect(key).NotTo(HavePrefix(prefix))
+				}
+
+				replayed := mock.MockInvokeWithTransient("0001", args, transient)
+				Expect(replayed).To(Equal(result))
+			})
 		})
 
 	})
 
+	Context("RestoreUser", func() {
+		const method = "RestoreUser"
+
+		BeforeEach(func() {
+			createTestUser(mock, st, model.NewUser("0000", 0))
+			mock.MockInvoke("0000", [][]byte{[]byte("DeleteUser"), []byte("0000"), []byte("left")})
+		})
+
+		It("Should only be called by administrators", func() {
+			result := mock.MockInvoke("0001", [][]byte{[]byte(method), []byte("0000")})
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+		})
+
+		It("Should restore a deleted user", func() {
+			Expect(mock.SetCreator("Org1MSP", "admin", map[string]string{
+				AdminAttribute: "true",
+			})).To(Succeed())
+
+			result := mock.MockInvoke("0001", [][]byte{[]byte(method), []byte("0000")})
+			Expect(int(result.Status)).To(Equal(shim.OK))
+
+			_, err := st.GetUser("0000")
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Context("PurgeUser", func() {
+		const method = "PurgeUser"
+
+		BeforeEach(func() {
+			createTestUser(mock, st, model.NewUser("0000", 0))
+			createTestUserInfo(mock, st, model.NewUserInfo("0000", "someone", "", ""))
+			mock.MockInvoke("0000", [][]byte{[]byte("DeleteUser"), []byte("0000")})
+
+			Expect(mock.SetCreator("Org1MSP", "admin", map[string]string{
+				AdminAttribute: "true",
+			})).To(Succeed())
+		})
+
+		It("Should not purge users before the retention period", func() {
+			result := mock.MockInvoke("0001", [][]byte{[]byte(method), []byte("0000")})
+			Expect(int(result.Status)).To(Equal(shim.ERROR))
+		})
+
+		It("Should purge users and their personal information", func() {
+			mock.MockInit("init", [][]byte{[]byte("init"), []byte(`{"retentionDays":0}`)})
+
+			result := mock.MockInvoke("0001", [][]byte{[]byte(method), []byte("0000")})
+			Expect(int(result.Status)).To(Equal(shim.OK))
+
+			users, err := st.AllUser(true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(users).To(BeEmpty())
+
+			_, err = st.GetUserInfo("0000")
+			Expect(store.IsNotFound(err)).To(BeTrue())
+		})
+	})
+
 })
 
-func createTestUser(mock *shim.MockStub, st *store.UserStore, user *model.User) {
+// testSalt is the salt of the personal information sent in tests
+const testSalt = "0123456789abcdef"
+
+// personalInfo returns the transient map of CreateUser with a name
+func personalInfo(name string) map[string][]byte {
+	return map[string][]byte{"name": []byte(name), "salt": []byte(testSalt)}
+}
+
+func createTestUser(mock *mockstub.Stub, st *store.UserStore, user *model.User) {
 	mock.MockTransactionStart("int")
 	defer mock.MockTransactionEnd("int")
 
@@ -205,3 +783,21 @@ func createTestUser(mock *shim.MockStub, st *store.UserStore, user *model.User)
 		panic(err)
 	}
 }
+
+func createTestUserInfo(mock *mockstub.Stub, st *store.UserStore, info *model.UserInfo) {
+	mock.MockTransactionStart("int")
+	defer mock.MockTransactionEnd("int")
+
+	if err := st.SetUserInfo(info); err != nil {
+		panic(err)
+	}
+}
+
+func setTestPaymentKey(mock *mockstub.Stub, st *store.UserStore) {
+	mock.MockTransactionStart("int")
+	defer mock.MockTransactionEnd("int")
+
+	if err := st.SetPaymentKey(model.NewPaymentKey([]byte(testSalt))); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,82 @@
+// Package client invokes the coffee and user chaincodes through a pluggable
+// Transport, such as the in-process MockTransport
+package client
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// Names of the chaincodes, as they are deployed by default
+const (
+	CoffeeChaincode = "coffee"
+	UserChaincode   = "user"
+)
+
+// Transport sends an invocation to a chaincode. It fails only if the
+// invocation couldn't be sent, chaincode errors are error responses
+type Transport interface {
+	Invoke(chaincode string, args [][]byte, transient map[string][]byte) (pb.Response, error)
+}
+
+// SaltKey is the transient map key of the salt of the personal information
+// sent to CreateUser
+const SaltKey = "salt"
+
+// NewSalt returns a random salt for the personal information sent to
+// CreateUser, as 32 hexadecimal characters
+func NewSalt() (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(salt), nil
+}
+
+// Error is an error response of a chaincode
+type Error struct {
+	Code    int32
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (status %d)", e.Message, e.Code)
+}
+
+// Status returns the status of the response
+func (e *Error) Status() int32 {
+	return e.Code
+}
+
+// Client invokes chaincode functions through a Transport
+type Client struct {
+	transport Transport
+}
+
+// New creates a new Client
+func New(transport Transport) *Client {
+	return &Client{transport}
+}
+
+// Invoke invokes a chaincode function, returning the response payload or an
+// *Error if the chaincode responded with an error
+func (c *Client) Invoke(chaincode, function string, args []string, transient map[string][]byte) ([]byte, error) {
+	raw := [][]byte{[]byte(function)}
+	for _, arg := range args {
+		raw = append(raw, []byte(arg))
+	}
+
+	response, err := c.transport.Invoke(chaincode, raw, transient)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.Status >= shim.ERRORTHRESHOLD {
+		return nil, &Error{response.Status, response.Message}
+	}
+	return response.Payload, nil
+}
@@ -0,0 +1,33 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/cdtlab19/coffee-chaincode/utils"
+)
+
+// Decode decodes an enveloped response payload, returning the envelope and
+// decoding it's data into `v`, unless it's nil. Enveloped errors are returned
+// as an *Error
+func Decode(payload []byte, v interface{}) (*utils.Envelope, error) {
+	envelope := &utils.Envelope{}
+	if err := json.Unmarshal(payload, envelope); err != nil {
+		return nil, err
+	}
+
+	if envelope.APIVersion == "" {
+		return nil, errors.New("response isn't enveloped")
+	}
+
+	if envelope.Error != nil {
+		return envelope, &Error{envelope.Error.Status, envelope.Error.Message}
+	}
+
+	if v != nil && len(envelope.Data) > 0 {
+		if err := json.Unmarshal(envelope.Data, v); err != nil {
+			return envelope, err
+		}
+	}
+	return envelope, nil
+}
@@ -0,0 +1,36 @@
+package client_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cdtlab19/coffee-chaincode/client"
+	"github.com/cdtlab19/coffee-chaincode/utils"
+)
+
+var _ = Describe("Decode", func() {
+	It("Should decode the data of an envelope", func() {
+		var data struct {
+			Key string `json:"key"`
+		}
+
+		envelope, err := Decode([]byte(`{"apiVersion":"1","txId":"tx1","data":{"key":"value"},"page":{"bookmark":"b1","done":false}}`), &data)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(envelope.TxID).To(Equal("tx1"))
+		Expect(envelope.Page).To(Equal(&utils.Page{Bookmark: "b1"}))
+		Expect(data.Key).To(Equal("value"))
+	})
+
+	It("Should return enveloped errors", func() {
+		_, err := Decode([]byte(`{"apiVersion":"1","error":{"status":409,"message":"conflict"}}`), nil)
+		Expect(err).To(Equal(&Error{Code: 409, Message: "conflict"}))
+	})
+
+	It("Should not decode responses without an envelope", func() {
+		_, err := Decode([]byte(`{"key":"value"}`), nil)
+		Expect(err).To(MatchError("response isn't enveloped"))
+
+		_, err = Decode([]byte(`not json`), nil)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,171 @@
+package client
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/cdtlab19/coffee-chaincode/chaincode"
+	"github.com/cdtlab19/coffee-chaincode/logging"
+	"github.com/cdtlab19/coffee-chaincode/mockstub"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// MockTransport runs the coffee and user chaincodes in-process over
+// mockstub.Stub, so they may be used offline and in tests. The state may be
+// saved to a file and loaded again by another process. Invocations are
+// serialized, so it may be shared by goroutines
+type MockTransport struct {
+	stubs map[string]*mockstub.Stub
+	mutex sync.Mutex
+}
+
+var _ Transport = &MockTransport{}
+
+// mockState is the saved state of a chaincode
+type mockState struct {
+	State   map[string][]byte            `json:"state"`
+	Private map[string]map[string][]byte `json:"private"`
+}
+
+// NewMockTransport creates and initializes the coffee and user chaincodes,
+// with the default configuration
+func NewMockTransport(logger *logging.Logger) (*MockTransport, error) {
+	coffee := mockstub.NewStub(CoffeeChaincode, chaincode.NewCoffeeChaincode(logger))
+	user := mockstub.NewStub(UserChaincode, chaincode.NewUserChaincode(logger,
+		chaincode.WithCoffeeChaincode(CoffeeChaincode)))
+	user.MockPeerChaincode(CoffeeChaincode, coffee)
+
+	t := &MockTransport{stubs: map[string]*mockstub.Stub{
+		CoffeeChaincode: coffee,
+		UserChaincode:   user,
+	}}
+
+	if err := t.Init(""); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Init calls Init on all chaincodes, updating their configuration with the
+// fields of a JSON object, or keeping it if `config` is empty
+func (t *MockTransport) Init(config string) error {
+	args := [][]byte{[]byte("init")}
+	if config != "" {
+		args = append(args, []byte(config))
+	}
+
+	for name, stub := range t.stubs {
+		if response := stub.MockInit(txID(), args); response.Status >= shim.ERRORTHRESHOLD {
+			return fmt.Errorf("failed initializing the %s chaincode: %s", name, response.Message)
+		}
+	}
+	return nil
+}
+
+// txID returns a random transaction ID
+func txID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(id)
+}
+
+// SetIdentity sets the creator of the next invocations
+func (t *MockTransport) SetIdentity(mspID, name string, attrs map[string]string) error {
+	for _, stub := range t.stubs {
+		if err := stub.SetCreator(mspID, name, attrs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Invoke invokes a chaincode in a new transaction
+func (t *MockTransport) Invoke(chaincode string, args [][]byte, transient map[string][]byte) (pb.Response, error) {
+	stub, ok := t.stubs[chaincode]
+	if !ok {
+		return pb.Response{}, fmt.Errorf("unknown chaincode '%s'", chaincode)
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return stub.MockInvokeWithTransient(txID(), args, transient), nil
+}
+
+// Save writes the state of all chaincodes to a file
+func (t *MockTransport) Save(path string) error {
+	states := map[string]mockState{}
+	for name, stub := range t.stubs {
+		states[name] = mockState{stub.State, stub.PvtState}
+	}
+
+	data, err := json.Marshal(states)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+// Load replaces the state of all chaincodes by the one saved to a file, if
+// it exists
+func (t *MockTransport) Load(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	states := map[string]mockState{}
+	if err := json.Unmarshal(data, &states); err != nil {
+		return fmt.Errorf("invalid state file '%s': %s", path, err.Error())
+	}
+
+	for name, state := range states {
+		stub, ok := t.stubs[name]
+		if !ok {
+			return fmt.Errorf("unknown chaincode '%s' in state file '%s'", name, path)
+		}
+		if err := restore(stub, state); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restore writes a saved state in a transaction, so the stub's key index used
+// by range queries is updated
+func restore(stub *mockstub.Stub, state mockState) error {
+	id := txID()
+	stub.MockTransactionStart(id)
+	defer stub.MockTransactionEnd(id)
+
+	for key := range stub.State {
+		if err := stub.DelState(key); err != nil {
+			return err
+		}
+	}
+	stub.PvtState = map[string]map[string][]byte{}
+
+	for key, value := range state.State {
+		if err := stub.PutState(key, value); err != nil {
+			return err
+		}
+	}
+	for collection, values := range state.Private {
+		for key, value := range values {
+			if err := stub.PutPrivateData(collection, key, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package client_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cdtlab19/coffee-chaincode/client"
+	"github.com/cdtlab19/coffee-chaincode/logging"
+	"github.com/cdtlab19/coffee-chaincode/model"
+)
+
+var _ = Describe("MockTransport", func() {
+	var transport *MockTransport
+	var c *Client
+
+	BeforeEach(func() {
+		var err error
+		transport, err = NewMockTransport(logging.New("client-test"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(transport.SetIdentity("Org1MSP", "someone", nil)).To(Succeed())
+		c = New(transport)
+	})
+
+	It("Should invoke the chaincodes", func() {
+		payload, err := c.Invoke(CoffeeChaincode, "CreateCoffee", []string{"mocha"}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(payload)).To(ContainSubstring("mocha"))
+	})
+
+	It("Should return error responses as *Error", func() {
+		_, err := c.Invoke(CoffeeChaincode, "GetCoffee", []string{"missing"}, nil)
+		Expect(err).To(BeAssignableToTypeOf(&Error{}))
+		Expect(err.(*Error).Status()).To(Equal(int32(404)))
+	})
+
+	It("Should fail for unknown chaincodes", func() {
+		_, err := c.Invoke("tea", "CreateTea", nil, nil)
+		Expect(err).To(MatchError(ContainSubstring("unknown chaincode")))
+	})
+
+	It("Should let the user chaincode query the coffee chaincode", func() {
+		payload, err := c.Invoke(UserChaincode, "CreateUser", []string{"0"},
+			map[string][]byte{"name": []byte("someone"), SaltKey: []byte("0123456789abcdef")})
+		Expect(err).NotTo(HaveOccurred())
+
+		var response struct {
+			User *model.User `json:"user"`
+		}
+		Expect(json.Unmarshal(payload, &response)).To(Succeed())
+
+		// DeleteUser queries the coffees owned by the user
+		_, err = c.Invoke(UserChaincode, "DeleteUser", []string{response.User.ID}, nil)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("Should save and load the state", func() {
+		dir, err := ioutil.TempDir("", "client")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "state.json")
+
+		Expect(transport.Load(path)).To(Succeed())
+		_, err = c.Invoke(CoffeeChaincode, "CreateCoffee", []string{"mocha"}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(transport.Save(path)).To(Succeed())
+
+		other, err := NewMockTransport(logging.New("client-test"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(other.Load(path)).To(Succeed())
+
+		payload, err := New(other).Invoke(CoffeeChaincode, "CoffeeByFlavour", []string{"mocha"}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(payload)).To(ContainSubstring(`"flavour":"mocha"`))
+	})
+})
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/cdtlab19/coffee-chaincode/client"
+)
+
+// command is a subcommand mirroring a chaincode function
+type command struct {
+	chaincode string
+	name      string
+	function  string
+	// args are the names of the positional arguments, optional ones between
+	// brackets
+	args        []string
+	description string
+	// flags registers the command's flags, returning a function which
+	// completes the positional arguments and transient map with their values
+	flags func(fs *flag.FlagSet) completeFunc
+}
+
+// completeFunc completes the positional arguments and transient map of a
+// command with the values of it's flags
+type completeFunc func(args []string, transient map[string][]byte) ([]string, error)
+
+// usage describes the command's arguments
+func (c *command) usage() string {
+	return strings.TrimSpace(fmt.Sprintf("%s %s %s", c.chaincode, c.name, strings.Join(c.args, " ")))
+}
+
+// required returns the number of required positional arguments
+func (c *command) required() int {
+	required := 0
+	for _, arg := range c.args {
+		if !strings.HasPrefix(arg, "[") {
+			required++
+		}
+	}
+	return required
+}
+
+// variadic verifies if the last argument may be repeated
+func (c *command) variadic() bool {
+	return len(c.args) > 0 && strings.HasSuffix(c.args[len(c.args)-1], "...]")
+}
+
+// includeDeleted adds the `-deleted` flag to list commands
+func includeDeleted(fs *flag.FlagSet) completeFunc {
+	deleted := fs.Bool("deleted", false, "include deleted assets")
+	return func(args []string, transient map[string][]byte) ([]string, error) {
+		if *deleted {
+			return append(args, "true"), nil
+		}
+		return args, nil
+	}
+}
+
+// personalInfo adds the flags of the user's personal information, sent in
+// the transient map with the `-salt` flag, or a random salt
+func personalInfo(fs *flag.FlagSet) completeFunc {
+	info := map[string]*string{
+		"name":  fs.String("name", "", "user's name"),
+		"email": fs.String("email", "", "user's e-mail"),
+		"badge": fs.String("badge", "", "user's badge"),
+	}
+	salt := fs.String("salt", "", "salt of the personal information's hash, random if not set")
+	return func(args []string, transient map[string][]byte) ([]string, error) {
+		for key, value := range info {
+			if *value != "" {
+				transient[key] = []byte(*value)
+			}
+		}
+
+		if *salt == "" {
+			var err error
+			if *salt, err = client.NewSalt(); err != nil {
+				return nil, err
+			}
+		}
+		transient[client.SaltKey] = []byte(*salt)
+		return args, nil
+	}
+}
+
+// payment adds the `-payment` flag of TopUp, whose reference is sent in the
+// transient map
+func payment(fs *flag.FlagSet) completeFunc {
+	reference := fs.String("payment", "", "reference of the payment")
+	return func(args []string, transient map[string][]byte) ([]string, error) {
+		if *reference != "" {
+			transient["paymentReference"] = []byte(*reference)
+		}
+		return args, nil
+	}
+}
+
+// paymentKey adds the `-key` flag of SetPaymentKey, whose key is sent in the
+// transient map, or a random key
+func paymentKey(fs *flag.FlagSet) completeFunc {
+	key := fs.String("key", "", "secret key of the payments' IDs, random if not set")
+	return func(args []string, transient map[string][]byte) ([]string, error) {
+		if *key == "" {
+			var err error
+			if *key, err = client.NewSalt(); err != nil {
+				return nil, err
+			}
+		}
+		transient["paymentKey"] = []byte(*key)
+		return args, nil
+	}
+}
+
+// force adds the `-force` flag to DeleteUser, whose reason must then be sent
+func force(fs *flag.FlagSet) completeFunc {
+	force := fs.Bool("force", false, "close users with remaining or owned coffees")
+	return func(args []string, transient map[string][]byte) ([]string, error) {
+		if !*force {
+			return args, nil
+		}
+		if len(args) == 1 {
+			args = append(args, "")
+		}
+		return append(args, "true"), nil
+	}
+}
+
+// shared returns the commands of the functions shared by all chaincodes
+func shared(chaincode string) []*command {
+	return []*command{
+		{chaincode, "batch", "Batch", []string{"<operations>", "[mode]"},
+			"run a JSON array of operations, atomic or bestEffort", nil},
+		{chaincode, "pending-migrations", "PendingMigrations", []string{"<pageSize>", "[bookmark]"},
+			"list the IDs of a page of assets stored by older versions", nil},
+		{chaincode, "migrate", "Migrate", []string{"<ids>"},
+			"migrate a JSON array of assets stored by older versions", nil},
+		{chaincode, "expired-requests", "ExpiredRequests", []string{"<pageSize>", "[bookmark]"},
+			"list the keys of a page of expired client requests", nil},
+		{chaincode, "expire-requests", "ExpireRequests", []string{"<keys>"},
+			"erase a JSON array of expired client requests", nil},
+		{chaincode, "get-config", "GetConfig", nil,
+			"show the configuration", nil},
+		{chaincode, "update-config", "UpdateConfig", []string{"<config>", "[revision]"},
+			"update the configuration with a JSON object", nil},
+		{chaincode, "describe", "Describe", nil,
+			"show the functions, their arguments and responses", nil},
+		{chaincode, "invoke", "", []string{"<function>", "[args...]"},
+			"invoke any function", nil},
+	}
+}
+
+// commands are all subcommands, by chaincode
+var commands = append(append([]*command{
+	{client.CoffeeChaincode, "create", "CreateCoffee", []string{"<flavour>"},
+		"create a coffee", nil},
+	{client.CoffeeChaincode, "use", "UseCoffee", []string{"<id>", "<user>", "[revision]"},
+		"use a coffee", nil},
+	{client.CoffeeChaincode, "get", "GetCoffee", []string{"<id>"},
+		"show a coffee", nil},
+	{client.CoffeeChaincode, "list", "AllCoffee", nil,
+		"list all coffees", includeDeleted},
+	{client.CoffeeChaincode, "by-owner", "CoffeeByOwner", []string{"<owner>"},
+		"list the coffees used by an user", nil},
+	{client.CoffeeChaincode, "by-flavour", "CoffeeByFlavour", []string{"<flavour>"},
+		"list the coffees of a flavour", nil},
+	{client.CoffeeChaincode, "by-state", "CoffeeByState", []string{"<state>"},
+		"list the available, used or deleted coffees", nil},
+	{client.CoffeeChaincode, "delete", "DeleteCoffee", []string{"<id>", "[reason]"},
+		"delete a coffee", nil},
+	{client.CoffeeChaincode, "restore", "RestoreCoffee", []string{"<id>"},
+		"restore a deleted coffee", nil},
+	{client.CoffeeChaincode, "purge", "PurgeCoffee", []string{"<id>"},
+		"erase a deleted coffee", nil},
+	{client.CoffeeChaincode, "verify-indexes", "VerifyIndexes", nil,
+		"report inconsistent index entries", nil},
+	{client.CoffeeChaincode, "rebuild-indexes", "RebuildIndexes", nil,
+		"fix inconsistent index entries", nil},
+
+	{client.UserChaincode, "create", "CreateUser", []string{"[remainingCoffee]"},
+		"create an user", personalInfo},
+	{client.UserChaincode, "get", "GetUser", []string{"<id>"},
+		"show an user", nil},
+	{client.UserChaincode, "info", "GetUserInfo", []string{"<id>"},
+		"show an user's personal information", nil},
+	{client.UserChaincode, "drink", "DrinkCoffee", []string{"<id>", "[revision]"},
+		"drink one of the user's remaining coffees", nil},
+	{client.UserChaincode, "top-up", "TopUp", []string{"<id>", "<credits>", "[revision]"},
+		"add purchased coffees to an user", payment},
+	{client.UserChaincode, "set-payment-key", "SetPaymentKey", nil,
+		"set the secret key of the payments' IDs, once", paymentKey},
+	{client.UserChaincode, "list", "AllUser", nil,
+		"list all users", includeDeleted},
+	{client.UserChaincode, "endorse", "SetUserEndorsement", []string{"<id>", "<orgs>"},
+		"set the organizations which must endorse changes to an user", nil},
+	{client.UserChaincode, "delete", "DeleteUser", []string{"<id>", "[reason]"},
+		"delete an user", force},
+	{client.UserChaincode, "restore", "RestoreUser", []string{"<id>"},
+		"restore a deleted user", nil},
+	{client.UserChaincode, "purge", "PurgeUser", []string{"<id>"},
+		"erase a deleted user", nil},
+}, shared(client.CoffeeChaincode)...), shared(client.UserChaincode)...)
+
+// findCommand returns a subcommand by it's chaincode and name
+func findCommand(chaincode, name string) *command {
+	for _, c := range commands {
+		if c.chaincode == chaincode && c.name == name {
+			return c
+		}
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+// Command coffeectl invokes the functions of the coffee and user chaincodes
+// from the command line:
+//
+//	coffeectl coffee create mocha
+//	coffeectl -o json user list -deleted
+//
+// The only transport runs the chaincodes in-process, keeping their state in
+// a file, so it works offline
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cdtlab19/coffee-chaincode/chaincode"
+	"github.com/cdtlab19/coffee-chaincode/client"
+	"github.com/cdtlab19/coffee-chaincode/logging"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	gologging "github.com/op/go-logging"
+)
+
+// options are the global flags
+type options struct {
+	transport string
+	state     string
+	config    string
+	mspID     string
+	name      string
+	admin     bool
+	output    string
+	requestID string
+	verbose   bool
+}
+
+// transports create a Transport by name, returning it and a function which
+// must be called once the invocation is done
+var transports = map[string]func(opts *options, logger *logging.Logger) (client.Transport, func() error, error){
+	"mock": mockTransport,
+}
+
+// mockTransport runs the chaincodes in-process, loading and saving their
+// state to the state file
+func mockTransport(opts *options, logger *logging.Logger) (client.Transport, func() error, error) {
+	transport, err := client.NewMockTransport(logger)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := transport.Load(opts.state); err != nil {
+		return nil, nil, err
+	}
+
+	if opts.config != "" {
+		if err := transport.Init(opts.config); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	attrs := map[string]string{}
+	if opts.admin {
+		attrs[chaincode.AdminAttribute] = "true"
+	}
+	if err := transport.SetIdentity(opts.mspID, opts.name, attrs); err != nil {
+		return nil, nil, err
+	}
+
+	return transport, func() error { return transport.Save(opts.state) }, nil
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// usage prints the global flags and all subcommands
+func usage(fs *flag.FlagSet, w io.Writer) {
+	fmt.Fprintln(w, "Usage: coffeectl [flags] <coffee|user> <command> [flags] [arguments]")
+	fmt.Fprintln(w, "\nFlags:")
+	fs.SetOutput(w)
+	fs.PrintDefaults()
+
+	fmt.Fprintln(w, "\nCommands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-45s %s\n", c.usage(), c.description)
+	}
+}
+
+// run runs coffeectl, returning the exit code: 1 if the invocation failed
+// and 2 if it's usage was wrong
+func run(args []string, stdout, stderr io.Writer) int {
+	opts := &options{}
+
+	fs := flag.NewFlagSet("coffeectl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&opts.transport, "transport", "mock", "transport used to invoke the chaincodes")
+	fs.StringVar(&opts.state, "state", "coffeectl.json", "state file of the mock transport")
+	fs.StringVar(&opts.config, "config", "", "JSON configuration sent to Init")
+	fs.StringVar(&opts.mspID, "msp", "Org1MSP", "MSP ID of the caller")
+	fs.StringVar(&opts.name, "name", "admin", "common name of the caller")
+	fs.BoolVar(&opts.admin, "admin", false, "call as an administrator")
+	fs.StringVar(&opts.output, "o", "table", "output format, table or json")
+	fs.StringVar(&opts.requestID, "request-id", "", "client request ID, to safely retry invocations")
+	fs.BoolVar(&opts.verbose, "v", false, "log the chaincode debug messages")
+	fs.Usage = func() { usage(fs, stderr) }
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if fs.NArg() < 2 {
+		usage(fs, stderr)
+		return 2
+	}
+
+	cmd := findCommand(fs.Arg(0), fs.Arg(1))
+	if cmd == nil {
+		fmt.Fprintf(stderr, "unknown command '%s %s'\n", fs.Arg(0), fs.Arg(1))
+		return 2
+	}
+
+	printer := map[string]func(w io.Writer, payload []byte) error{
+		"table": printTable,
+		"json":  printJSON,
+	}[opts.output]
+	if printer == nil {
+		fmt.Fprintf(stderr, "unknown output format '%s'\n", opts.output)
+		return 2
+	}
+
+	function, cmdArgs, transient, ok := parseCommand(cmd, fs.Args()[2:], stderr)
+	if !ok {
+		return 2
+	}
+	if opts.requestID != "" {
+		transient[chaincode.RequestIDKey] = []byte(opts.requestID)
+	}
+
+	newTransport, ok := transports[opts.transport]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown transport '%s'\n", opts.transport)
+		return 2
+	}
+
+	logger := logging.New("coffeectl")
+	if opts.verbose {
+		logger.SetLevel(shim.LogDebug)
+	} else {
+		logger.SetLevel(shim.LogWarning)
+		gologging.SetLevel(gologging.CRITICAL, "mock")
+	}
+
+	transport, done, err := newTransport(opts, logger)
+	if err != nil {
+		fmt.Fprintln(stderr, err.Error())
+		return 1
+	}
+
+	payload, err := client.New(transport).Invoke(cmd.chaincode, function, cmdArgs, transient)
+	if err != nil {
+		fmt.Fprintln(stderr, err.Error())
+		return 1
+	}
+
+	if err := done(); err != nil {
+		fmt.Fprintln(stderr, err.Error())
+		return 1
+	}
+
+	if err := printer(stdout, payload); err != nil {
+		fmt.Fprintln(stderr, err.Error())
+		return 1
+	}
+	return 0
+}
+
+// parseCommand parses a command's flags and positional arguments, returning
+// the function and arguments which must be invoked
+func parseCommand(cmd *command, args []string, stderr io.Writer) (string, []string, map[string][]byte, bool) {
+	fs := flag.NewFlagSet(cmd.usage(), flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: coffeectl [flags] %s\n%s\n", cmd.usage(), cmd.description)
+		fs.PrintDefaults()
+	}
+
+	complete := func(args []string, transient map[string][]byte) ([]string, error) { return args, nil }
+	if cmd.flags != nil {
+		complete = cmd.flags(fs)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return "", nil, nil, false
+	}
+
+	args = fs.Args()
+	if len(args) < cmd.required() || (!cmd.variadic() && len(args) > len(cmd.args)) {
+		fs.Usage()
+		return "", nil, nil, false
+	}
+
+	function := cmd.function
+	if function == "" {
+		function, args = args[0], args[1:]
+	}
+
+	transient := map[string][]byte{}
+	args, err := complete(args, transient)
+	if err != nil {
+		fmt.Fprintln(stderr, err.Error())
+		return "", nil, nil, false
+	}
+	return function, args, transient, true
+}
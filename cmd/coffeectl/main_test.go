@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cdtlab19/coffee-chaincode/model"
+)
+
+var _ = Describe("coffeectl", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "coffeectl")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	coffeectl := func(args ...string) (int, string, string) {
+		var stdout, stderr bytes.Buffer
+		args = append([]string{"-state", filepath.Join(dir, "state.json")}, args...)
+		code := run(args, &stdout, &stderr)
+		return code, stdout.String(), stderr.String()
+	}
+
+	createCoffee := func(flavour string) *model.Coffee {
+		code, out, _ := coffeectl("-o", "json", "coffee", "create", flavour)
+		Expect(code).To(Equal(0))
+
+		var response struct {
+			Coffee *model.Coffee `json:"coffee"`
+		}
+		Expect(json.Unmarshal([]byte(out), &response)).To(Succeed())
+		return response.Coffee
+	}
+
+	It("Should keep the state between runs", func() {
+		coffee := createCoffee("mocha")
+
+		code, out, _ := coffeectl("coffee", "use", coffee.ID, "someone")
+		Expect(code).To(Equal(0))
+		Expect(out).To(Equal("OK\n"))
+
+		code, out, _ = coffeectl("coffee", "list")
+		Expect(code).To(Equal(0))
+		Expect(out).To(ContainSubstring("FLAVOUR"))
+		Expect(out).To(MatchRegexp(coffee.ID + `\s+mocha\s+someone\s+used\s+2`))
+	})
+
+	It("Should print JSON", func() {
+		coffee := createCoffee("latte")
+
+		code, out, _ := coffeectl("-o", "json", "coffee", "get", coffee.ID)
+		Expect(code).To(Equal(0))
+		Expect(out).To(MatchJSON(`{"coffee":` + string(coffee.JSON()) + `}`))
+	})
+
+	It("Should send flags as arguments and transient data", func() {
+		code, out, _ := coffeectl("-o", "json", "user", "create", "-name", "Someone", "3")
+		Expect(code).To(Equal(0))
+
+		var response struct {
+			User *model.User `json:"user"`
+		}
+		Expect(json.Unmarshal([]byte(out), &response)).To(Succeed())
+		Expect(response.User.RemainingCoffee).To(Equal(3))
+
+		code, out, _ = coffeectl("-admin", "-o", "json", "user", "info", response.User.ID)
+		Expect(code).To(Equal(0))
+		Expect(out).To(ContainSubstring("Someone"))
+
+		code, _, errOut := coffeectl("user", "delete", response.User.ID)
+		Expect(code).To(Equal(1))
+		Expect(errOut).To(ContainSubstring("409"))
+
+		code, _, _ = coffeectl("-admin", "user", "delete", "-force", response.User.ID)
+		Expect(code).To(Equal(0))
+
+		code, out, _ = coffeectl("user", "list", "-deleted")
+		Expect(code).To(Equal(0))
+		Expect(out).To(MatchRegexp(response.User.ID + `\s+0\s+true`))
+	})
+
+	It("Should retry creating users with the same request ID", func() {
+		created := func(out string) *model.User {
+			var response struct {
+				User *model.User `json:"user"`
+			}
+			Expect(json.Unmarshal([]byte(out), &response)).To(Succeed())
+			return response.User
+		}
+
+		code, out, _ := coffeectl("-o", "json", "-request-id", "req-1", "user", "create", "-name", "Someone", "3")
+		Expect(code).To(Equal(0))
+		first := created(out)
+
+		code, out, _ = coffeectl("-o", "json", "-request-id", "req-1", "user", "create", "-name", "Someone", "3")
+		Expect(code).To(Equal(0))
+		Expect(created(out)).To(Equal(first))
+
+		code, out, _ = coffeectl("-o", "json", "user", "create", "-name", "Someone", "-salt", "0123456789abcdef", "3")
+		Expect(code).To(Equal(0))
+
+		code, out, _ = coffeectl("-admin", "-o", "json", "user", "info", created(out).ID)
+		Expect(code).To(Equal(0))
+		Expect(out).To(ContainSubstring("0123456789abcdef"))
+	})
+
+	It("Should send the payment reference of top-ups as transient data", func() {
+		code, out, _ := coffeectl("-o", "json", "user", "create", "-name", "Someone", "3")
+		Expect(code).To(Equal(0))
+
+		var response struct {
+			User *model.User `json:"user"`
+		}
+		Expect(json.Unmarshal([]byte(out), &response)).To(Succeed())
+
+		code, _, _ = coffeectl("-admin", "user", "set-payment-key")
+		Expect(code).To(Equal(0))
+
+		code, out, _ = coffeectl("-admin", "user", "top-up", "-payment", "PAY-0001", response.User.ID, "5")
+		Expect(code).To(Equal(0))
+		Expect(out).To(MatchRegexp(response.User.ID + `\s+8\s+false`))
+
+		code, _, errOut := coffeectl("-admin", "user", "top-up", response.User.ID, "5")
+		Expect(code).To(Equal(1))
+		Expect(errOut).To(ContainSubstring("paymentReference: missing"))
+	})
+
+	It("Should invoke any function", func() {
+		createCoffee("mocha")
+
+		code, out, _ := coffeectl("coffee", "invoke", "CoffeeByFlavour", "mocha")
+		Expect(code).To(Equal(0))
+		Expect(out).To(ContainSubstring("mocha"))
+	})
+
+	It("Should report chaincode errors", func() {
+		code, _, errOut := coffeectl("coffee", "get", "missing")
+		Expect(code).To(Equal(1))
+		Expect(errOut).To(ContainSubstring("404"))
+
+		code, _, errOut = coffeectl("coffee", "verify-indexes")
+		Expect(code).To(Equal(1))
+		Expect(errOut).To(ContainSubstring("Permission denied"))
+	})
+
+	It("Should report usage errors", func() {
+		code, _, errOut := coffeectl("coffee", "brew")
+		Expect(code).To(Equal(2))
+		Expect(errOut).To(ContainSubstring("unknown command"))
+
+		code, _, errOut = coffeectl("coffee", "use", "only-id")
+		Expect(code).To(Equal(2))
+		Expect(errOut).To(ContainSubstring("coffee use <id> <user> [revision]"))
+
+		code, _, _ = coffeectl("-o", "yaml", "coffee", "list")
+		Expect(code).To(Equal(2))
+
+		code, _, _ = coffeectl("-transport", "grpc", "coffee", "list")
+		Expect(code).To(Equal(2))
+
+		code, _, errOut = coffeectl()
+		Expect(code).To(Equal(2))
+		Expect(errOut).To(ContainSubstring("Commands:"))
+	})
+})
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/cdtlab19/coffee-chaincode/client"
+	"github.com/cdtlab19/coffee-chaincode/model"
+)
+
+// printJSON prints a response payload as indented JSON
+func printJSON(w io.Writer, payload []byte) error {
+	if len(payload) == 0 {
+		return nil
+	}
+
+	var buffer bytes.Buffer
+	if err := json.Indent(&buffer, payload, "", "  "); err != nil {
+		// not JSON, such as snapshot pages
+		_, err = w.Write(payload)
+		return err
+	}
+
+	buffer.WriteByte('\n')
+	_, err := buffer.WriteTo(w)
+	return err
+}
+
+// response is a chaincode response with coffees or users
+type response struct {
+	Coffee  *model.Coffee   `json:"coffee"`
+	Coffees []*model.Coffee `json:"coffees"`
+	User    *model.User     `json:"user"`
+	Users   []*model.User   `json:"users"`
+}
+
+// printTable prints the coffees or users of a response payload as a table,
+// or any other payload as JSON
+func printTable(w io.Writer, payload []byte) error {
+	if len(payload) == 0 {
+		_, err := fmt.Fprintln(w, "OK")
+		return err
+	}
+
+	// enveloped responses are printed by their data
+	if envelope, err := client.Decode(payload, nil); err == nil {
+		if len(envelope.Data) == 0 {
+			_, err := fmt.Fprintln(w, "OK")
+			return err
+		}
+		payload = envelope.Data
+	}
+
+	var res response
+	if err := json.Unmarshal(payload, &res); err != nil {
+		return printJSON(w, payload)
+	}
+
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	switch {
+	case res.Coffee != nil || res.Coffees != nil:
+		if res.Coffee != nil {
+			res.Coffees = append(res.Coffees, res.Coffee)
+		}
+
+		fmt.Fprintln(table, "ID\tFLAVOUR\tOWNER\tSTATE\tREVISION")
+		for _, coffee := range res.Coffees {
+			fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%d\n",
+				coffee.ID, coffee.Flavour, orDash(coffee.Owner), coffee.State(), coffee.Revision)
+		}
+
+	case res.User != nil || res.Users != nil:
+		if res.User != nil {
+			res.Users = append(res.Users, res.User)
+		}
+
+		fmt.Fprintln(table, "ID\tREMAINING\tDELETED\tREVISION")
+		for _, user := range res.Users {
+			fmt.Fprintf(table, "%s\t%d\t%s\t%d\n",
+				user.ID, user.RemainingCoffee, strconv.FormatBool(user.IsDeleted()), user.Revision)
+		}
+
+	default:
+		return printJSON(w, payload)
+	}
+	return table.Flush()
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,54 @@
+// Command gateway serves the REST API of the coffee and user chaincodes,
+// running them in-process for local development
+package main
+
+import (
+	"flag"
+	"net/http"
+
+	"github.com/cdtlab19/coffee-chaincode/chaincode"
+	"github.com/cdtlab19/coffee-chaincode/client"
+	"github.com/cdtlab19/coffee-chaincode/gateway"
+	"github.com/cdtlab19/coffee-chaincode/logging"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	gologging "github.com/op/go-logging"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	config := flag.String("config", "", "JSON configuration sent to Init")
+	mspID := flag.String("msp", "Org1MSP", "MSP ID of the caller")
+	name := flag.String("name", "gateway", "common name of the caller")
+	admin := flag.Bool("admin", false, "call as an administrator")
+	flag.Parse()
+
+	logger := shim.NewLogger("gateway")
+	gologging.SetLevel(gologging.CRITICAL, "mock")
+
+	transport, err := client.NewMockTransport(logging.New("chaincode"))
+	if err != nil {
+		logger.Criticalf("Gateway Error: %s", err.Error())
+		return
+	}
+
+	if *config != "" {
+		if err := transport.Init(*config); err != nil {
+			logger.Criticalf("Gateway Error: %s", err.Error())
+			return
+		}
+	}
+
+	attrs := map[string]string{}
+	if *admin {
+		attrs[chaincode.AdminAttribute] = "true"
+	}
+	if err := transport.SetIdentity(*mspID, *name, attrs); err != nil {
+		logger.Criticalf("Gateway Error: %s", err.Error())
+		return
+	}
+
+	logger.Infof("Listening on %s", *addr)
+	if err := http.ListenAndServe(*addr, gateway.New(transport, logger)); err != nil {
+		logger.Criticalf("Gateway Error: %s", err.Error())
+	}
+}
@@ -2,14 +2,15 @@ package main
 
 import (
 	"github.com/cdtlab19/coffee-chaincode/chaincode"
+	"github.com/cdtlab19/coffee-chaincode/logging"
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 )
 
 func main() {
-	logger := shim.NewLogger("coffee")
+	logger := logging.New("coffee")
 	coffeeChaincode := chaincode.NewCoffeeChaincode(logger)
 
 	if err := shim.Start(coffeeChaincode); err != nil {
-		logger.Critical("Chaincode Error: %s", err.Error())
+		logger.Criticalf("Chaincode Error: %s", err.Error())
 	}
 }
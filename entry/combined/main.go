@@ -0,0 +1,16 @@
+package main
+
+import (
+	"github.com/cdtlab19/coffee-chaincode/chaincode"
+	"github.com/cdtlab19/coffee-chaincode/logging"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+func main() {
+	logger := logging.New("combined")
+	combinedChaincode := chaincode.NewCombinedChaincode(logger)
+
+	if err := shim.Start(combinedChaincode); err != nil {
+		logger.Criticalf("Chaincode Error: %s", err.Error())
+	}
+}
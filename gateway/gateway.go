@@ -0,0 +1,258 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cdtlab19/coffee-chaincode/chaincode"
+	"github.com/cdtlab19/coffee-chaincode/client"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// RequestIDHeader is the header of the optional client request ID, sent to
+// the chaincodes so retried requests aren't executed twice
+const RequestIDHeader = "Idempotency-Key"
+
+// Gateway is an http.Handler which invokes the chaincode function of each
+// route
+type Gateway struct {
+	client *client.Client
+	routes []Route
+	logger *shim.ChaincodeLogger
+}
+
+var _ http.Handler = &Gateway{}
+
+// New creates a Gateway for the default Routes, invoking the chaincodes
+// through a Transport
+func New(transport client.Transport, logger *shim.ChaincodeLogger) *Gateway {
+	return &Gateway{client.New(transport), Routes, logger}
+}
+
+// respondError responds an error as a JSON object. Chaincode errors keep
+// their status
+func respondError(w http.ResponseWriter, status int, err error) {
+	if e, ok := err.(*client.Error); ok {
+		status = http.StatusInternalServerError
+		if e.Code >= 400 && e.Code < 600 {
+			status = int(e.Code)
+		}
+		err = fmt.Errorf("%s", e.Message)
+	}
+
+	data, _ := json.Marshal(struct {
+		Error string `json:"error"`
+	}{err.Error()})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// ServeHTTP invokes the function of the route matching the request
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && r.URL.Path == "/openapi.json" {
+		g.serveOpenAPI(w)
+		return
+	}
+
+	allowed := []string{}
+	for i := range g.routes {
+		route := &g.routes[i]
+
+		params, ok := route.match(r.URL.Path)
+		if !ok {
+			continue
+		}
+		if route.Method != r.Method {
+			allowed = append(allowed, route.Method)
+			continue
+		}
+
+		g.serveRoute(w, r, route, params)
+		return
+	}
+
+	if len(allowed) > 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		respondError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	respondError(w, http.StatusNotFound, fmt.Errorf("%s not found", r.URL.Path))
+}
+
+func (g *Gateway) serveOpenAPI(w http.ResponseWriter) {
+	data, err := json.Marshal(OpenAPI(g.routes))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+func (g *Gateway) serveRoute(w http.ResponseWriter, r *http.Request, route *Route, params map[string]string) {
+	g.logger.Debugf("%s %s: invoking %s on %s", r.Method, r.URL.Path, route.Function, route.Chaincode)
+
+	args, transient, err := arguments(route, r, params)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if requestID := r.Header.Get(RequestIDHeader); requestID != "" {
+		transient[chaincode.RequestIDKey] = []byte(requestID)
+	}
+
+	if _, ok := transient[client.SaltKey]; route.Salted && !ok {
+		salt, err := client.NewSalt()
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err)
+			return
+		}
+		transient[client.SaltKey] = []byte(salt)
+	}
+
+	payload, err := g.client.Invoke(route.Chaincode, route.Function, args, transient)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	if len(payload) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(payload)
+}
+
+// arguments reads the function arguments and transient map of a request.
+// Missing optional arguments are sent as empty strings, unless they are the
+// last ones
+func arguments(route *Route, r *http.Request, path map[string]string) ([]string, map[string][]byte, error) {
+	body, err := readBody(route, r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	args, sent := []string{}, 0
+	transient := map[string][]byte{}
+
+	for _, param := range route.Params {
+		var value string
+		var ok bool
+
+		switch param.In {
+		case InPath:
+			value, ok = path[param.Name]
+		case InQuery:
+			values, found := r.URL.Query()[param.Name]
+			if found {
+				value, ok = values[0], true
+			}
+		case InBody, InTransient:
+			value, ok, err = bodyValue(body, param)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+
+		if !ok {
+			if param.Required {
+				return nil, nil, fmt.Errorf("missing %s parameter '%s'", param.In, param.Name)
+			}
+			if param.In != InTransient {
+				args = append(args, "")
+			}
+			continue
+		}
+
+		if err := validate(param, value); err != nil {
+			return nil, nil, err
+		}
+
+		if param.In == InTransient {
+			transient[param.Name] = []byte(value)
+			continue
+		}
+		args = append(args, value)
+		sent = len(args)
+	}
+
+	return args[:sent], transient, nil
+}
+
+// readBody decodes the JSON object of a request body, if the route has body
+// or transient parameters
+func readBody(route *Route, r *http.Request) (map[string]interface{}, error) {
+	body := map[string]interface{}{}
+
+	hasBody := false
+	for _, param := range route.Params {
+		hasBody = hasBody || param.In == InBody || param.In == InTransient
+	}
+	if !hasBody || r.Body == nil {
+		return body, nil
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&body); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("invalid JSON body: %s", err.Error())
+	}
+	return body, nil
+}
+
+// bodyValue returns a parameter of the body as a string, if it has the
+// parameter's type
+func bodyValue(body map[string]interface{}, param Param) (string, bool, error) {
+	value, ok := body[param.Name]
+	if !ok || value == nil {
+		return "", false, nil
+	}
+
+	switch v := value.(type) {
+	case string:
+		if param.Type == TypeString {
+			return v, true, nil
+		}
+	case json.Number:
+		if param.Type == TypeInteger {
+			return v.String(), true, nil
+		}
+	case bool:
+		if param.Type == TypeBoolean {
+			return strconv.FormatBool(v), true, nil
+		}
+	}
+	return "", false, fmt.Errorf("body parameter '%s' must be a %s", param.Name, param.Type)
+}
+
+// validate verifies if a value has the parameter's type
+func validate(param Param, value string) error {
+	var err error
+	switch param.Type {
+	case TypeInteger:
+		_, err = strconv.Atoi(value)
+	case TypeBoolean:
+		_, err = strconv.ParseBool(value)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s parameter '%s' must be a %s", param.In, param.Name, param.Type)
+	}
+	return nil
+}
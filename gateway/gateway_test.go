@@ -0,0 +1,184 @@
+package gateway_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cdtlab19/coffee-chaincode/chaincode"
+	"github.com/cdtlab19/coffee-chaincode/client"
+	. "github.com/cdtlab19/coffee-chaincode/gateway"
+	"github.com/cdtlab19/coffee-chaincode/logging"
+	"github.com/cdtlab19/coffee-chaincode/model"
+)
+
+var _ = Describe("Gateway", func() {
+	var transport *client.MockTransport
+	var gateway *Gateway
+
+	BeforeEach(func() {
+		logger := shim.NewLogger("gateway-test")
+
+		var err error
+		transport, err = client.NewMockTransport(logging.New("gateway-test"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(transport.SetIdentity("Org1MSP", "someone", nil)).To(Succeed())
+
+		gateway = New(transport, logger)
+	})
+
+	request := func(method, path, body string, headers ...string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		for i := 0; i < len(headers); i += 2 {
+			r.Header.Set(headers[i], headers[i+1])
+		}
+
+		w := httptest.NewRecorder()
+		gateway.ServeHTTP(w, r)
+		return w
+	}
+
+	createCoffee := func(flavour string) *model.Coffee {
+		w := request(http.MethodPost, "/coffees", `{"flavour":"`+flavour+`"}`)
+		Expect(w.Code).To(Equal(http.StatusCreated))
+
+		var response struct {
+			Coffee *model.Coffee `json:"coffee"`
+		}
+		Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+		return response.Coffee
+	}
+
+	createUser := func(body string) *model.User {
+		w := request(http.MethodPost, "/users", body)
+		Expect(w.Code).To(Equal(http.StatusCreated))
+
+		var response struct {
+			User *model.User `json:"user"`
+		}
+		Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+		return response.User
+	}
+
+	It("Should create, use and list coffees", func() {
+		coffee := createCoffee("mocha")
+
+		w := request(http.MethodPost, "/coffees/"+coffee.ID+"/use", `{"user":"u1","revision":1}`)
+		Expect(w.Code).To(Equal(http.StatusNoContent))
+
+		w = request(http.MethodGet, "/coffees", "")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Header().Get("Content-Type")).To(Equal("application/json"))
+		Expect(w.Body.String()).To(ContainSubstring(`"owner":"u1"`))
+
+		w = request(http.MethodGet, "/owners/u1/coffees", "")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.String()).To(ContainSubstring(coffee.ID))
+	})
+
+	It("Should keep the chaincode error statuses", func() {
+		coffee := createCoffee("mocha")
+
+		w := request(http.MethodGet, "/coffees/missing", "")
+		Expect(w.Code).To(Equal(http.StatusNotFound))
+		Expect(w.Body.String()).To(MatchJSON(`{"error":"coffee 'missing' not found"}`))
+
+		w = request(http.MethodPost, "/coffees/"+coffee.ID+"/use", `{"user":"u1","revision":7}`)
+		Expect(w.Code).To(Equal(http.StatusConflict))
+
+		w = request(http.MethodPost, "/coffees/"+coffee.ID+"/restore", "")
+		Expect(w.Code).To(Equal(http.StatusInternalServerError))
+		Expect(w.Body.String()).To(ContainSubstring("Permission denied"))
+	})
+
+	It("Should send transient parameters in the transient map", func() {
+		user := createUser(`{"remainingCoffee":2,"name":"Someone","email":"someone@example.com"}`)
+		Expect(user.RemainingCoffee).To(Equal(2))
+
+		Expect(transport.SetIdentity("Org1MSP", "admin", map[string]string{
+			chaincode.AdminAttribute: "true",
+		})).To(Succeed())
+
+		w := request(http.MethodGet, "/users/"+user.ID+"/info", "")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.String()).To(ContainSubstring(`"name":"Someone"`))
+
+		w = request(http.MethodGet, "/users/"+user.ID, "")
+		Expect(w.Body.String()).NotTo(ContainSubstring("Someone"))
+	})
+
+	It("Should send transient parameters in the transient map", func() {
+		Expect(transport.Init(`{"paymentProcessors":[{"mspId":"Org1MSP","name":"someone"}]}`)).To(Succeed())
+		Expect(transport.SetIdentity("Org1MSP", "admin", map[string]string{
+			chaincode.AdminAttribute: "true",
+		})).To(Succeed())
+		result, err := transport.Invoke(client.UserChaincode, [][]byte{[]byte("SetPaymentKey")},
+			map[string][]byte{"paymentKey": []byte("0123456789abcdef")})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(int(result.Status)).To(Equal(http.StatusOK), result.Message)
+		Expect(transport.SetIdentity("Org1MSP", "someone", nil)).To(Succeed())
+
+		user := createUser(`{"name":"Someone","remainingCoffee":3}`)
+
+		w := request(http.MethodPost, "/users/"+user.ID+"/top-up", `{"credits":5,"paymentReference":"PAY-0001"}`)
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.String()).To(ContainSubstring(`"remainingCoffee":8`))
+		Expect(w.Body.String()).NotTo(ContainSubstring("PAY-0001"))
+
+		w = request(http.MethodPost, "/users/"+user.ID+"/top-up", `{"credits":5,"paymentReference":"PAY-0001"}`)
+		Expect(w.Code).To(Equal(http.StatusConflict))
+	})
+
+	It("Should send missing optional arguments before sent ones as empty", func() {
+		user := createUser(`{"name":"Someone"}`)
+
+		w := request(http.MethodDelete, "/users/"+user.ID, "")
+		Expect(w.Code).To(Equal(http.StatusConflict))
+
+		Expect(transport.SetIdentity("Org1MSP", "admin", map[string]string{
+			chaincode.AdminAttribute: "true",
+		})).To(Succeed())
+
+		w = request(http.MethodDelete, "/users/"+user.ID+"?force=true", "")
+		Expect(w.Code).To(Equal(http.StatusNoContent))
+
+		w = request(http.MethodGet, "/users?includeDeleted=true", "")
+		Expect(w.Body.String()).To(ContainSubstring(`"deleted"`))
+	})
+
+	It("Should replay requests with the same request ID", func() {
+		first := request(http.MethodPost, "/coffees", `{"flavour":"mocha"}`, RequestIDHeader, "req-1")
+		retry := request(http.MethodPost, "/coffees", `{"flavour":"mocha"}`, RequestIDHeader, "req-1")
+		Expect(retry.Body.String()).To(Equal(first.Body.String()))
+	})
+
+	It("Should reject invalid requests", func() {
+		Expect(request(http.MethodPost, "/coffees", `{}`).Code).To(Equal(http.StatusBadRequest))
+		Expect(request(http.MethodPost, "/coffees", `not json`).Code).To(Equal(http.StatusBadRequest))
+		Expect(request(http.MethodPost, "/coffees", `{"flavour":3}`).Code).To(Equal(http.StatusBadRequest))
+		Expect(request(http.MethodGet, "/coffees?includeDeleted=maybe", "").Code).To(Equal(http.StatusBadRequest))
+		Expect(request(http.MethodPost, "/users/u1/drink", `{"revision":"one"}`).Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("Should respond unknown paths and methods", func() {
+		Expect(request(http.MethodGet, "/teas", "").Code).To(Equal(http.StatusNotFound))
+
+		w := request(http.MethodPut, "/coffees/c1", "")
+		Expect(w.Code).To(Equal(http.StatusMethodNotAllowed))
+		Expect(w.Header().Get("Allow")).To(Equal("GET, DELETE"))
+	})
+
+	It("Should serve the OpenAPI document", func() {
+		w := request(http.MethodGet, "/openapi.json", "")
+		Expect(w.Code).To(Equal(http.StatusOK))
+
+		var document map[string]interface{}
+		Expect(json.Unmarshal(w.Body.Bytes(), &document)).To(Succeed())
+		Expect(document["openapi"]).To(Equal(OpenAPIVersion))
+	})
+})
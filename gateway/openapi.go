@@ -0,0 +1,130 @@
+package gateway
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// OpenAPIVersion is the version of the OpenAPI specification of the
+// generated documents
+const OpenAPIVersion = "3.0.2"
+
+// object is a JSON object of an OpenAPI document
+type object map[string]interface{}
+
+// errorSchema is the schema of error responses
+var errorSchema = object{
+	"type":     "object",
+	"required": []string{"error"},
+	"properties": object{
+		"error": object{"type": "string"},
+	},
+}
+
+// OpenAPI generates the OpenAPI document of the routes
+func OpenAPI(routes []Route) map[string]interface{} {
+	paths := object{}
+	for _, route := range routes {
+		item, ok := paths[route.Path].(object)
+		if !ok {
+			item = object{}
+			paths[route.Path] = item
+		}
+		item[strings.ToLower(route.Method)] = operation(route)
+	}
+
+	return object{
+		"openapi": OpenAPIVersion,
+		"info": object{
+			"title":   "Coffee Pod Manager",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": object{
+			"schemas": object{"Error": errorSchema},
+		},
+	}
+}
+
+// operation describes the operation of a route
+func operation(route Route) object {
+	parameters := []object{}
+	properties := object{}
+	required := []string{}
+
+	for _, param := range route.Params {
+		schema := object{"type": param.Type}
+
+		switch param.In {
+		case InPath, InQuery:
+			parameters = append(parameters, object{
+				"name":        param.Name,
+				"in":          param.In,
+				"required":    param.Required,
+				"description": param.Description,
+				"schema":      schema,
+			})
+
+		case InBody, InTransient:
+			schema["description"] = param.Description
+			if param.In == InTransient {
+				schema["description"] = param.Description + ", kept in the private data collection"
+			}
+			properties[param.Name] = schema
+			if param.Required {
+				required = append(required, param.Name)
+			}
+		}
+	}
+
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	op := object{
+		"operationId": route.Function,
+		"summary":     route.Summary,
+		"tags":        []string{route.Chaincode},
+		"parameters": append(parameters, object{
+			"name":        RequestIDHeader,
+			"in":          "header",
+			"required":    false,
+			"description": "client request ID, to safely retry the request",
+			"schema":      object{"type": "string"},
+		}),
+		"responses": object{
+			strconv.Itoa(status): object{
+				"description": "the chaincode response",
+				"content": object{
+					"application/json": object{"schema": object{"type": "object"}},
+				},
+			},
+			"204": object{"description": "success without a response"},
+			"default": object{
+				"description": "an error, with the status returned by the chaincode",
+				"content": object{
+					"application/json": object{
+						"schema": object{"$ref": "#/components/schemas/Error"},
+					},
+				},
+			},
+		},
+	}
+
+	if len(properties) > 0 {
+		schema := object{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+
+		op["requestBody"] = object{
+			"required": len(required) > 0,
+			"content": object{
+				"application/json": object{"schema": schema},
+			},
+		}
+	}
+	return op
+}
@@ -0,0 +1,83 @@
+package gateway_test
+
+import (
+	"encoding/json"
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cdtlab19/coffee-chaincode/gateway"
+)
+
+var _ = Describe("OpenAPI", func() {
+	// document encodes and decodes the generated document, as clients read it
+	document := func(routes []Route) map[string]interface{} {
+		data, err := json.Marshal(OpenAPI(routes))
+		Expect(err).NotTo(HaveOccurred())
+
+		var document map[string]interface{}
+		Expect(json.Unmarshal(data, &document)).To(Succeed())
+		return document
+	}
+
+	It("Should describe every route", func() {
+		paths := document(Routes)["paths"].(map[string]interface{})
+
+		for _, route := range Routes {
+			Expect(paths).To(HaveKey(route.Path))
+			Expect(paths[route.Path]).To(HaveKey(map[string]string{
+				http.MethodGet:    "get",
+				http.MethodPost:   "post",
+				http.MethodDelete: "delete",
+			}[route.Method]))
+		}
+	})
+
+	It("Should describe parameters and request bodies", func() {
+		doc := document([]Route{{
+			Method:    http.MethodPost,
+			Path:      "/coffees/{id}/use",
+			Chaincode: "coffee",
+			Function:  "UseCoffee",
+			Params: []Param{
+				{Name: "id", In: InPath, Type: TypeString, Required: true},
+				{Name: "user", In: InBody, Type: TypeString, Required: true},
+				{Name: "revision", In: InBody, Type: TypeInteger},
+			},
+		}})
+
+		data, err := json.Marshal(doc["paths"].(map[string]interface{})["/coffees/{id}/use"])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(MatchJSON(`{
+			"post": {
+				"operationId": "UseCoffee",
+				"summary": "",
+				"tags": ["coffee"],
+				"parameters": [
+					{"name": "id", "in": "path", "required": true, "description": "", "schema": {"type": "string"}},
+					{"name": "Idempotency-Key", "in": "header", "required": false,
+						"description": "client request ID, to safely retry the request", "schema": {"type": "string"}}
+				],
+				"requestBody": {
+					"required": true,
+					"content": {"application/json": {"schema": {
+						"type": "object",
+						"required": ["user"],
+						"properties": {
+							"user": {"type": "string", "description": ""},
+							"revision": {"type": "integer", "description": ""}
+						}
+					}}}
+				},
+				"responses": {
+					"200": {"description": "the chaincode response",
+						"content": {"application/json": {"schema": {"type": "object"}}}},
+					"204": {"description": "success without a response"},
+					"default": {"description": "an error, with the status returned by the chaincode",
+						"content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}}
+				}
+			}
+		}`))
+	})
+})
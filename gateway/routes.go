@@ -0,0 +1,156 @@
+// Package gateway exposes the coffee and user chaincodes as a REST API, with
+// an OpenAPI document generated from the route definitions
+package gateway
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/cdtlab19/coffee-chaincode/client"
+)
+
+// Parameter locations
+const (
+	InPath      = "path"
+	InQuery     = "query"
+	InBody      = "body"
+	InTransient = "transient"
+)
+
+// Parameter types
+const (
+	TypeString  = "string"
+	TypeInteger = "integer"
+	TypeBoolean = "boolean"
+)
+
+// Param is a request parameter. Path, query and body parameters are sent as
+// the function arguments, in order, while transient parameters are sent in
+// the transient map
+type Param struct {
+	Name        string
+	In          string
+	Type        string
+	Required    bool
+	Description string
+}
+
+// Route maps an HTTP method and path to a chaincode function
+type Route struct {
+	Method string
+	// Path may have parameters between braces, such as "/coffees/{id}"
+	Path      string
+	Chaincode string
+	Function  string
+	Summary   string
+	Params    []Param
+	// Status is the status of successful responses, http.StatusOK if unset
+	Status int
+	// Salted routes send a random salt for the personal information in the
+	// transient map, unless the request has one
+	Salted bool
+}
+
+// segments splits the route path
+func (r *Route) segments() []string {
+	return strings.Split(strings.Trim(r.Path, "/"), "/")
+}
+
+// match verifies if a path matches the route, returning it's path parameters
+func (r *Route) match(path string) (map[string]string, bool) {
+	segments := r.segments()
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) != len(segments) {
+		return nil, false
+	}
+
+	params := map[string]string{}
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if parts[i] == "" {
+				return nil, false
+			}
+			params[strings.Trim(segment, "{}")] = parts[i]
+		} else if segment != parts[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+func path(name, description string) Param {
+	return Param{name, InPath, TypeString, true, description}
+}
+
+func revision() Param {
+	return Param{"revision", InBody, TypeInteger, false, "revision the client read, to detect concurrent changes"}
+}
+
+func includeDeleted() Param {
+	return Param{"includeDeleted", InQuery, TypeBoolean, false, "include deleted assets"}
+}
+
+func reason() Param {
+	return Param{"reason", InQuery, TypeString, false, "why the asset is deleted"}
+}
+
+// Routes are the routes of the gateway
+var Routes = []Route{
+	{Method: http.MethodGet, Path: "/coffees", Chaincode: client.CoffeeChaincode, Function: "AllCoffee",
+		Summary: "List all coffees", Params: []Param{includeDeleted()}},
+	{Method: http.MethodPost, Path: "/coffees", Chaincode: client.CoffeeChaincode, Function: "CreateCoffee",
+		Summary: "Create a coffee", Status: http.StatusCreated, Params: []Param{
+			{"flavour", InBody, TypeString, true, "coffee's flavour"},
+		}},
+	{Method: http.MethodGet, Path: "/coffees/{id}", Chaincode: client.CoffeeChaincode, Function: "GetCoffee",
+		Summary: "Get a coffee", Params: []Param{path("id", "coffee's ID")}},
+	{Method: http.MethodPost, Path: "/coffees/{id}/use", Chaincode: client.CoffeeChaincode, Function: "UseCoffee",
+		Summary: "Use a coffee", Params: []Param{
+			path("id", "coffee's ID"),
+			{"user", InBody, TypeString, true, "ID of the user who used the coffee"},
+			revision(),
+		}},
+	{Method: http.MethodDelete, Path: "/coffees/{id}", Chaincode: client.CoffeeChaincode, Function: "DeleteCoffee",
+		Summary: "Delete a coffee", Params: []Param{path("id", "coffee's ID"), reason()}},
+	{Method: http.MethodPost, Path: "/coffees/{id}/restore", Chaincode: client.CoffeeChaincode, Function: "RestoreCoffee",
+		Summary: "Restore a deleted coffee", Params: []Param{path("id", "coffee's ID")}},
+	{Method: http.MethodGet, Path: "/owners/{owner}/coffees", Chaincode: client.CoffeeChaincode, Function: "CoffeeByOwner",
+		Summary: "List the coffees used by an user", Params: []Param{path("owner", "user's ID")}},
+	{Method: http.MethodGet, Path: "/flavours/{flavour}/coffees", Chaincode: client.CoffeeChaincode, Function: "CoffeeByFlavour",
+		Summary: "List the coffees of a flavour", Params: []Param{path("flavour", "coffee's flavour")}},
+	{Method: http.MethodGet, Path: "/states/{state}/coffees", Chaincode: client.CoffeeChaincode, Function: "CoffeeByState",
+		Summary: "List the available, used or deleted coffees", Params: []Param{path("state", "coffee's state")}},
+
+	{Method: http.MethodGet, Path: "/users", Chaincode: client.UserChaincode, Function: "AllUser",
+		Summary: "List all users", Params: []Param{includeDeleted()}},
+	{Method: http.MethodPost, Path: "/users", Chaincode: client.UserChaincode, Function: "CreateUser",
+		Summary: "Create an user", Status: http.StatusCreated, Salted: true, Params: []Param{
+			{"remainingCoffee", InBody, TypeInteger, false, "remaining coffees, or the configured default"},
+			{"name", InTransient, TypeString, true, "user's name"},
+			{"email", InTransient, TypeString, false, "user's e-mail"},
+			{"badge", InTransient, TypeString, false, "user's badge"},
+			{"salt", InTransient, TypeString, false,
+				"random salt of the personal information, generated if not sent"},
+		}},
+	{Method: http.MethodGet, Path: "/users/{id}", Chaincode: client.UserChaincode, Function: "GetUser",
+		Summary: "Get an user", Params: []Param{path("id", "user's ID")}},
+	{Method: http.MethodGet, Path: "/users/{id}/info", Chaincode: client.UserChaincode, Function: "GetUserInfo",
+		Summary: "Get an user's personal information", Params: []Param{path("id", "user's ID")}},
+	{Method: http.MethodPost, Path: "/users/{id}/drink", Chaincode: client.UserChaincode, Function: "DrinkCoffee",
+		Summary: "Drink one of the user's remaining coffees", Params: []Param{path("id", "user's ID"), revision()}},
+	{Method: http.MethodPost, Path: "/users/{id}/top-up", Chaincode: client.UserChaincode, Function: "TopUp",
+		Summary: "Add purchased coffees to an user", Params: []Param{
+			path("id", "user's ID"),
+			{"credits", InBody, TypeInteger, true, "purchased coffees"},
+			revision(),
+			{"paymentReference", InTransient, TypeString, true, "reference of the payment"},
+		}},
+	{Method: http.MethodDelete, Path: "/users/{id}", Chaincode: client.UserChaincode, Function: "DeleteUser",
+		Summary: "Delete an user", Params: []Param{
+			path("id", "user's ID"),
+			reason(),
+			{"force", InQuery, TypeBoolean, false, "close users with remaining or owned coffees"},
+		}},
+	{Method: http.MethodPost, Path: "/users/{id}/restore", Chaincode: client.UserChaincode, Function: "RestoreUser",
+		Summary: "Restore a deleted user", Params: []Param{path("id", "user's ID")}},
+}
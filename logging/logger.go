@@ -0,0 +1,287 @@
+// Package logging writes structured chaincode logs. Each line of a
+// transaction carries it's ID, channel, function and caller's MSP, and is
+// written as text or as a JSON object
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/core/chaincode/shim/ext/cid"
+	gologging "github.com/op/go-logging"
+)
+
+// Formats of the log lines
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Environment variables setting the default level and format of loggers
+const (
+	LevelEnv  = "COFFEE_LOG_LEVEL"
+	FormatEnv = "COFFEE_LOG_FORMAT"
+)
+
+// noOverride is the override of a logger whose level isn't overridden
+const noOverride = -1
+
+// Logger writes the lines of a chaincode. It's level is shared by all
+// transactions, and may be overridden by the chaincode configuration
+type Logger struct {
+	name     string
+	format   string
+	level    int32
+	override int32
+
+	mu  sync.Mutex
+	out io.Writer
+	now func() time.Time
+}
+
+// Option configures a Logger
+type Option func(l *Logger)
+
+// WithOutput writes the lines to `out` instead of the standard error
+func WithOutput(out io.Writer) Option {
+	return func(l *Logger) {
+		l.out = out
+	}
+}
+
+// WithFormat sets the format of the lines, FormatText or FormatJSON
+func WithFormat(format string) Option {
+	return func(l *Logger) {
+		l.format = format
+	}
+}
+
+// WithLevel sets the default level of the logger
+func WithLevel(level shim.LoggingLevel) Option {
+	return func(l *Logger) {
+		l.level = int32(level)
+	}
+}
+
+// New creates a Logger. The default level and format are read from the
+// environment, in LevelEnv and FormatEnv, and are INFO and text if unset
+func New(name string, options ...Option) *Logger {
+	l := &Logger{
+		name:     name,
+		format:   FormatText,
+		level:    int32(shim.LogInfo),
+		override: noOverride,
+		out:      os.Stderr,
+		now:      time.Now,
+	}
+
+	if name := os.Getenv(LevelEnv); name != "" {
+		if level, err := shim.LogLevel(name); err == nil {
+			l.level = int32(level)
+		}
+	}
+	if format := strings.ToLower(os.Getenv(FormatEnv)); format == FormatJSON {
+		l.format = format
+	}
+
+	for _, option := range options {
+		option(l)
+	}
+	return l
+}
+
+// SetLevel sets the default level of the logger
+func (l *Logger) SetLevel(level shim.LoggingLevel) {
+	atomic.StoreInt32(&l.level, int32(level))
+}
+
+// OverrideLevel overrides the default level by it's name, such as "DEBUG".
+// An empty name restores the default level
+func (l *Logger) OverrideLevel(name string) error {
+	if name == "" {
+		atomic.StoreInt32(&l.override, noOverride)
+		return nil
+	}
+
+	level, err := shim.LogLevel(name)
+	if err != nil {
+		return err
+	}
+	atomic.StoreInt32(&l.override, int32(level))
+	return nil
+}
+
+// Level returns the current level of the logger
+func (l *Logger) Level() shim.LoggingLevel {
+	if override := atomic.LoadInt32(&l.override); override != noOverride {
+		return shim.LoggingLevel(override)
+	}
+	return shim.LoggingLevel(atomic.LoadInt32(&l.level))
+}
+
+// IsEnabledFor verifies if lines of a level are written
+func (l *Logger) IsEnabledFor(level shim.LoggingLevel) bool {
+	// go-logging levels are ordered from CRITICAL to DEBUG
+	return level <= l.Level()
+}
+
+// For returns the logger of a transaction
+func (l *Logger) For(stub shim.ChaincodeStubInterface) *TxLogger {
+	return &TxLogger{logger: l, stub: stub}
+}
+
+// field is a key and value written with a line
+type field struct {
+	key   string
+	value interface{}
+}
+
+// write writes a line if it's level is enabled. The fields are only
+// computed if it is
+func (l *Logger) write(level shim.LoggingLevel, fields func() []field, message string) {
+	if !l.IsEnabledFor(level) {
+		return
+	}
+
+	var line []byte
+	if l.format == FormatJSON {
+		line = l.jsonLine(level, fields(), message)
+	} else {
+		line = l.textLine(level, fields(), message)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out.Write(append(line, '\n'))
+}
+
+func (l *Logger) textLine(level shim.LoggingLevel, fields []field, message string) []byte {
+	line := fmt.Sprintf("%s %s [%s] %s", l.now().UTC().Format(time.RFC3339Nano),
+		gologging.Level(level).String(), l.name, message)
+
+	for _, f := range fields {
+		value := fmt.Sprint(f.value)
+		if value == "" || strings.ContainsAny(value, " \"=") {
+			value = fmt.Sprintf("%q", value)
+		}
+		line += fmt.Sprintf(" %s=%s", f.key, value)
+	}
+	return []byte(line)
+}
+
+func (l *Logger) jsonLine(level shim.LoggingLevel, fields []field, message string) []byte {
+	object := map[string]interface{}{
+		"time":    l.now().UTC().Format(time.RFC3339Nano),
+		"level":   gologging.Level(level).String(),
+		"logger":  l.name,
+		"message": message,
+	}
+	for _, f := range fields {
+		object[f.key] = f.value
+	}
+
+	line, err := json.Marshal(object)
+	if err != nil {
+		line, _ = json.Marshal(map[string]string{"level": "ERROR", "message": err.Error()})
+	}
+	return line
+}
+
+func noFields() []field {
+	return nil
+}
+
+// Debug writes a DEBUG line
+func (l *Logger) Debug(args ...interface{}) {
+	l.write(shim.LogDebug, noFields, fmt.Sprint(args...))
+}
+
+// Debugf writes a formatted DEBUG line
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.write(shim.LogDebug, noFields, fmt.Sprintf(format, args...))
+}
+
+// Infof writes a formatted INFO line
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.write(shim.LogInfo, noFields, fmt.Sprintf(format, args...))
+}
+
+// Warningf writes a formatted WARNING line
+func (l *Logger) Warningf(format string, args ...interface{}) {
+	l.write(shim.LogWarning, noFields, fmt.Sprintf(format, args...))
+}
+
+// Errorf writes a formatted ERROR line
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.write(shim.LogError, noFields, fmt.Sprintf(format, args...))
+}
+
+// Criticalf writes a formatted CRITICAL line
+func (l *Logger) Criticalf(format string, args ...interface{}) {
+	l.write(shim.LogCritical, noFields, fmt.Sprintf(format, args...))
+}
+
+// TxLogger writes the lines of a transaction, with it's ID, channel,
+// function and caller's MSP, and any fields added with With
+type TxLogger struct {
+	logger *Logger
+	stub   shim.ChaincodeStubInterface
+	extra  []field
+}
+
+// With returns a logger which also writes a field with each line
+func (l *TxLogger) With(key string, value interface{}) *TxLogger {
+	extra := append([]field{}, l.extra...)
+	return &TxLogger{l.logger, l.stub, append(extra, field{key, value})}
+}
+
+func (l *TxLogger) fields() []field {
+	function, _ := l.stub.GetFunctionAndParameters()
+	mspID, _ := cid.GetMSPID(l.stub)
+
+	fields := []field{
+		{"txId", l.stub.GetTxID()},
+		{"channel", l.stub.GetChannelID()},
+		{"function", function},
+		{"mspId", mspID},
+	}
+
+	return append(fields, l.extra...)
+}
+
+// Debug writes a DEBUG line
+func (l *TxLogger) Debug(args ...interface{}) {
+	l.logger.write(shim.LogDebug, l.fields, fmt.Sprint(args...))
+}
+
+// Debugf writes a formatted DEBUG line
+func (l *TxLogger) Debugf(format string, args ...interface{}) {
+	l.logger.write(shim.LogDebug, l.fields, fmt.Sprintf(format, args...))
+}
+
+// Infof writes a formatted INFO line
+func (l *TxLogger) Infof(format string, args ...interface{}) {
+	l.logger.write(shim.LogInfo, l.fields, fmt.Sprintf(format, args...))
+}
+
+// Noticef writes a formatted NOTICE line
+func (l *TxLogger) Noticef(format string, args ...interface{}) {
+	l.logger.write(shim.LogNotice, l.fields, fmt.Sprintf(format, args...))
+}
+
+// Warningf writes a formatted WARNING line
+func (l *TxLogger) Warningf(format string, args ...interface{}) {
+	l.logger.write(shim.LogWarning, l.fields, fmt.Sprintf(format, args...))
+}
+
+// Errorf writes a formatted ERROR line
+func (l *TxLogger) Errorf(format string, args ...interface{}) {
+	l.logger.write(shim.LogError, l.fields, fmt.Sprintf(format, args...))
+}
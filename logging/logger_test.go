@@ -0,0 +1,110 @@
+package logging_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cdtlab19/coffee-chaincode/logging"
+	"github.com/cdtlab19/coffee-chaincode/mockstub"
+)
+
+// loggingChaincode runs a function with the transaction logger of each
+// invocation
+type loggingChaincode struct {
+	logger *Logger
+	log    func(logger *TxLogger)
+}
+
+func (cc *loggingChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	return shim.Success(nil)
+}
+
+func (cc *loggingChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	cc.log(cc.logger.For(stub))
+	return shim.Success(nil)
+}
+
+var _ = Describe("Logger", func() {
+	var out *bytes.Buffer
+
+	BeforeEach(func() {
+		out = &bytes.Buffer{}
+	})
+
+	invoke := func(logger *Logger, log func(logger *TxLogger)) {
+		mock := mockstub.NewStub("logging-test", &loggingChaincode{logger, log})
+		mock.ChannelID = "channel"
+		Expect(mock.SetCreator("Org1MSP", "someone", nil)).To(Succeed())
+		mock.MockInvoke("tx1", [][]byte{[]byte("UseCoffee"), []byte("0000")})
+	}
+
+	It("Should write the transaction fields as text", func() {
+		logger := New("coffee", WithOutput(out))
+		invoke(logger, func(logger *TxLogger) {
+			logger.With("status", 200).Infof("used %s", "0000")
+		})
+
+		Expect(out.String()).To(HaveSuffix(
+			" INFO [coffee] used 0000 txId=tx1 channel=channel function=UseCoffee mspId=Org1MSP status=200\n"))
+	})
+
+	It("Should write the transaction fields as JSON", func() {
+		logger := New("coffee", WithOutput(out), WithFormat(FormatJSON))
+		invoke(logger, func(logger *TxLogger) {
+			logger.With("status", 200).Infof("used %s", "0000")
+		})
+
+		line := map[string]interface{}{}
+		Expect(json.Unmarshal(out.Bytes(), &line)).To(Succeed())
+		Expect(line).To(HaveKeyWithValue("level", "INFO"))
+		Expect(line).To(HaveKeyWithValue("logger", "coffee"))
+		Expect(line).To(HaveKeyWithValue("message", "used 0000"))
+		Expect(line).To(HaveKeyWithValue("txId", "tx1"))
+		Expect(line).To(HaveKeyWithValue("channel", "channel"))
+		Expect(line).To(HaveKeyWithValue("function", "UseCoffee"))
+		Expect(line).To(HaveKeyWithValue("mspId", "Org1MSP"))
+		Expect(line).To(HaveKeyWithValue("status", 200.0))
+		Expect(line).To(HaveKey("time"))
+	})
+
+	It("Should only write lines of enabled levels", func() {
+		logger := New("coffee", WithOutput(out), WithLevel(shim.LogWarning))
+		invoke(logger, func(logger *TxLogger) { logger.Infof("hidden") })
+		Expect(out.String()).To(BeEmpty())
+
+		invoke(logger, func(logger *TxLogger) { logger.Warningf("shown") })
+		Expect(out.String()).To(ContainSubstring("WARNING [coffee] shown"))
+	})
+
+	It("Should override the default level", func() {
+		logger := New("coffee", WithOutput(out), WithLevel(shim.LogWarning))
+
+		Expect(logger.OverrideLevel("debug")).To(Succeed())
+		Expect(logger.Level()).To(Equal(shim.LogDebug))
+
+		Expect(logger.OverrideLevel("verbose")).NotTo(Succeed())
+		Expect(logger.Level()).To(Equal(shim.LogDebug))
+
+		Expect(logger.OverrideLevel("")).To(Succeed())
+		Expect(logger.Level()).To(Equal(shim.LogWarning))
+	})
+
+	It("Should read the default level and format from the environment", func() {
+		defer os.Unsetenv(LevelEnv)
+		defer os.Unsetenv(FormatEnv)
+		os.Setenv(LevelEnv, "DEBUG")
+		os.Setenv(FormatEnv, "json")
+
+		logger := New("coffee", WithOutput(out))
+		Expect(logger.Level()).To(Equal(shim.LogDebug))
+
+		logger.Debugf("started")
+		Expect(json.Valid(out.Bytes())).To(BeTrue())
+	})
+})
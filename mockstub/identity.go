@@ -0,0 +1,72 @@
+package mockstub
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/chaincode/shim/ext/attrmgr"
+	"github.com/hyperledger/fabric/protos/msp"
+)
+
+// NewIdentity creates a serialized identity of the organization `mspID`,
+// holding a self-signed certificate for `commonName` with the given Fabric CA
+// attributes, as returned by ChaincodeStubInterface.GetCreator
+func NewIdentity(mspID, commonName string, attrs map[string]string) ([]byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	attributes, err := json.Marshal(&attrmgr.Attributes{Attrs: attrs})
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{
+			CommonName:   commonName,
+			Organization: []string{mspID},
+		},
+		NotBefore: time.Now().Add(-time.Hour),
+		NotAfter:  time.Now().Add(time.Hour),
+		ExtraExtensions: []pkix.Extension{
+			{Id: attrmgr.AttrOID, Value: attributes},
+		},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return proto.Marshal(&msp.SerializedIdentity{
+		Mspid:   mspID,
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	})
+}
+
+// SetCreator sets the identity which will be returned by GetCreator in the
+// next invocations
+func (s *Stub) SetCreator(mspID, commonName string, attrs map[string]string) error {
+	creator, err := NewIdentity(mspID, commonName, attrs)
+	if err != nil {
+		return err
+	}
+
+	s.Creator = creator
+	return nil
+}
+
+// GetCreator returns the identity of the current invocation's creator
+func (s *Stub) GetCreator() ([]byte, error) {
+	return s.Creator, nil
+}
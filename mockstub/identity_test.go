@@ -0,0 +1,28 @@
+package mockstub_test
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim/ext/cid"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cdtlab19/coffee-chaincode/mockstub"
+)
+
+var _ = Describe("Identity", func() {
+	It("Should set a creator readable by the client identity package", func() {
+		stub := NewStub("echo", echoChaincode{})
+		Expect(stub.SetCreator("Org1MSP", "someone", map[string]string{
+			"role": "barista",
+		})).To(Succeed())
+
+		mspID, err := cid.GetMSPID(stub)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mspID).To(Equal("Org1MSP"))
+
+		cert, err := cid.GetX509Certificate(stub)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cert.Subject.CommonName).To(Equal("someone"))
+
+		Expect(cid.AssertAttributeValue(stub, "role", "barista")).To(Succeed())
+	})
+})
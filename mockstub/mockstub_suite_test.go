@@ -0,0 +1,13 @@
+package mockstub_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestMockstub(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Mockstub Suite")
+}
@@ -0,0 +1,393 @@
+// Package mockstub extends shim.MockStub with the features needed for testing
+// the application's chaincodes, such as transient data and private data
+// collections, which are not implemented by the default Hyperledger Fabric
+// mock
+package mockstub
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/ledger/queryresult"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// Stub is a shim.MockStub which also supports transient data, creator
+// identities and private data deletion and queries. As Fabric's transaction
+// simulator, it fails writes after paginated or private data queries, and
+// these queries after writes, in the same transaction
+type Stub struct {
+	*shim.MockStub
+
+	// Transient is the transient map sent in the next invocations
+	Transient map[string][]byte
+
+	// Creator is the serialized identity of the next invocations' creator
+	Creator []byte
+
+	cc         shim.Chaincode
+	args       [][]byte
+	peers      map[string]*Stub
+	simulation *simulation
+	proposal   *pb.SignedProposal
+}
+
+// simulation tracks the queries and writes of a transaction
+type simulation struct {
+	txID      string
+	written   bool
+	paginated bool
+	private   bool
+}
+
+// write records a write, failing after paginated or private data queries
+func (s *simulation) write() error {
+	if s.paginated {
+		return fmt.Errorf("txid [%s]: unsupported transaction. Paginated queries are supported only "+
+			"in a read-only transaction", s.txID)
+	}
+	if s.private {
+		return fmt.Errorf("txid [%s]: unsupported transaction. Queries on pvt data is supported only "+
+			"in a read-only transaction", s.txID)
+	}
+	s.written = true
+	return nil
+}
+
+// paginatedQuery records a paginated query, failing after writes
+func (s *simulation) paginatedQuery() error {
+	if s.written {
+		return fmt.Errorf("txid [%s]: Paginated queries are supported only in a read-only transaction", s.txID)
+	}
+	s.paginated = true
+	return nil
+}
+
+// privateQuery records a private data query, failing after writes
+func (s *simulation) privateQuery() error {
+	if s.written {
+		return fmt.Errorf("txid [%s]: Queries on pvt data is supported only in a read-only transaction", s.txID)
+	}
+	s.private = true
+	return nil
+}
+
+var _ shim.ChaincodeStubInterface = &Stub{}
+
+// NewStub creates a new Stub for a chaincode
+func NewStub(name string, cc shim.Chaincode) *Stub {
+	return &Stub{
+		MockStub:   shim.NewMockStub(name, cc),
+		Transient:  map[string][]byte{},
+		cc:         cc,
+		peers:      map[string]*Stub{},
+		simulation: &simulation{},
+	}
+}
+
+// MockPeerChaincode registers another chaincode which may be called with
+// InvokeChaincode
+func (s *Stub) MockPeerChaincode(name string, other *Stub) {
+	s.peers[name] = other
+}
+
+// InvokeChaincode invokes a chaincode registered with MockPeerChaincode in
+// the same transaction, with the same creator, transient map and signed
+// proposal, as Fabric does for chaincodes in the same channel. Both
+// chaincodes share the same simulation, so the callee can't write after the
+// caller's queries
+func (s *Stub) InvokeChaincode(name string, args [][]byte, channel string) pb.Response {
+	if channel != "" {
+		name = name + "/" + channel
+	}
+
+	other, ok := s.peers[name]
+	if !ok {
+		return shim.Error(fmt.Sprintf("chaincode '%s' not found", name))
+	}
+
+	creator, transient := other.Creator, other.Transient
+	other.Creator, other.Transient = s.Creator, s.Transient
+	defer func() { other.Creator, other.Transient = creator, transient }()
+
+	other.args = args
+	other.MockTransactionStart(s.TxID)
+	defer other.MockTransactionEnd(s.TxID)
+	other.simulation = s.simulation
+	other.proposal = s.proposal
+
+	return other.cc.Invoke(other)
+}
+
+// MockTransactionStart starts a transaction, with a new simulation
+func (s *Stub) MockTransactionStart(txid string) {
+	s.MockStub.MockTransactionStart(txid)
+	s.simulation = &simulation{txID: txid}
+}
+
+// MockTransactionEnd ends a transaction and it's simulation
+func (s *Stub) MockTransactionEnd(uuid string) {
+	s.MockStub.MockTransactionEnd(uuid)
+	s.simulation = &simulation{}
+	s.proposal = nil
+}
+
+// MockInit initialises the chaincode, also starting and ending a transaction
+func (s *Stub) MockInit(uuid string, args [][]byte) pb.Response {
+	s.args = args
+	s.MockTransactionStart(uuid)
+	defer s.MockTransactionEnd(uuid)
+
+	if err := s.propose(); err != nil {
+		return shim.Error(err.Error())
+	}
+	return s.cc.Init(s)
+}
+
+// MockInvoke invokes the chaincode, also starting and ending a transaction
+func (s *Stub) MockInvoke(uuid string, args [][]byte) pb.Response {
+	s.args = args
+	s.MockTransactionStart(uuid)
+	defer s.MockTransactionEnd(uuid)
+
+	if err := s.propose(); err != nil {
+		return shim.Error(err.Error())
+	}
+	return s.cc.Invoke(s)
+}
+
+// propose sets the signed proposal of the current transaction, sent by the
+// creator with the arguments and transient map of the invocation
+func (s *Stub) propose() error {
+	signatureHeader, err := proto.Marshal(&common.SignatureHeader{Creator: s.Creator})
+	if err != nil {
+		return err
+	}
+
+	header, err := proto.Marshal(&common.Header{SignatureHeader: signatureHeader})
+	if err != nil {
+		return err
+	}
+
+	input, err := proto.Marshal(&pb.ChaincodeInvocationSpec{
+		ChaincodeSpec: &pb.ChaincodeSpec{
+			ChaincodeId: &pb.ChaincodeID{Name: s.Name},
+			Input:       &pb.ChaincodeInput{Args: s.args},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	payload, err := proto.Marshal(&pb.ChaincodeProposalPayload{Input: input, TransientMap: s.Transient})
+	if err != nil {
+		return err
+	}
+
+	proposal, err := proto.Marshal(&pb.Proposal{Header: header, Payload: payload})
+	if err != nil {
+		return err
+	}
+
+	s.proposal = &pb.SignedProposal{ProposalBytes: proposal}
+	return nil
+}
+
+// GetSignedProposal returns the signed proposal of the current invocation,
+// which is the caller's one for invocations by other chaincodes
+func (s *Stub) GetSignedProposal() (*pb.SignedProposal, error) {
+	if s.proposal != nil {
+		return s.proposal, nil
+	}
+	return s.MockStub.GetSignedProposal()
+}
+
+// MockInvokeWithTransient invokes the chaincode sending the transient map
+// only for this invocation
+func (s *Stub) MockInvokeWithTransient(uuid string, args [][]byte, transient map[string][]byte) pb.Response {
+	previous := s.Transient
+	s.Transient = transient
+	defer func() { s.Transient = previous }()
+
+	return s.MockInvoke(uuid, args)
+}
+
+// GetArgs returns the arguments of the current invocation
+func (s *Stub) GetArgs() [][]byte {
+	return s.args
+}
+
+// GetStringArgs returns the arguments of the current invocation as strings
+func (s *Stub) GetStringArgs() []string {
+	args := make([]string, len(s.args))
+	for i, arg := range s.args {
+		args[i] = string(arg)
+	}
+	return args
+}
+
+// GetFunctionAndParameters returns the invoked function and it's parameters
+func (s *Stub) GetFunctionAndParameters() (function string, params []string) {
+	args := s.GetStringArgs()
+	if len(args) > 0 {
+		function = args[0]
+		params = args[1:]
+	}
+	return
+}
+
+// GetTransient returns the transient map of the current invocation
+func (s *Stub) GetTransient() (map[string][]byte, error) {
+	return s.Transient, nil
+}
+
+// PutState writes a key, unless the transaction has queries which don't
+// allow writes
+func (s *Stub) PutState(key string, value []byte) error {
+	if err := s.simulation.write(); err != nil {
+		return err
+	}
+	return s.MockStub.PutState(key, value)
+}
+
+// DelState deletes a key, unless the transaction has queries which don't
+// allow writes
+func (s *Stub) DelState(key string) error {
+	if err := s.simulation.write(); err != nil {
+		return err
+	}
+	return s.MockStub.DelState(key)
+}
+
+// SetStateValidationParameter sets the endorsement policy of a key, unless
+// the transaction has queries which don't allow writes
+func (s *Stub) SetStateValidationParameter(key string, ep []byte) error {
+	if err := s.simulation.write(); err != nil {
+		return err
+	}
+	return s.MockStub.SetStateValidationParameter(key, ep)
+}
+
+// PutPrivateData writes a key of a private data collection, unless the
+// transaction has queries which don't allow writes
+func (s *Stub) PutPrivateData(collection string, key string, value []byte) error {
+	if err := s.simulation.write(); err != nil {
+		return err
+	}
+	return s.MockStub.PutPrivateData(collection, key, value)
+}
+
+// SetPrivateDataValidationParameter sets the endorsement policy of a key of
+// a private data collection, unless the transaction has queries which don't
+// allow writes
+func (s *Stub) SetPrivateDataValidationParameter(collection, key string, ep []byte) error {
+	if err := s.simulation.write(); err != nil {
+		return err
+	}
+	return s.MockStub.SetPrivateDataValidationParameter(collection, key, ep)
+}
+
+// DelPrivateData deletes a key from a private data collection
+func (s *Stub) DelPrivateData(collection string, key string) error {
+	if err := s.simulation.write(); err != nil {
+		return err
+	}
+	if m, ok := s.PvtState[collection]; ok {
+		delete(m, key)
+	}
+	return nil
+}
+
+// GetPrivateDataByPartialCompositeKey queries a private data collection by a
+// partial composite key
+func (s *Stub) GetPrivateDataByPartialCompositeKey(collection, objectType string, attributes []string) (shim.StateQueryIteratorInterface, error) {
+	if err := s.simulation.privateQuery(); err != nil {
+		return nil, err
+	}
+
+	prefix, err := s.CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []string{}
+	for key := range s.PvtState[collection] {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	results := make([]*queryresult.KV, len(keys))
+	for i, key := range keys {
+		results[i] = &queryresult.KV{Key: key, Value: s.PvtState[collection][key]}
+	}
+
+	return &iterator{results: results}, nil
+}
+
+// GetStateByPartialCompositeKeyWithPagination queries the state by a partial
+// composite key a page at a time. As in Fabric, the bookmark is the key the
+// page starts at, and the returned bookmark is the key of the next page, or
+// empty after the last page
+func (s *Stub) GetStateByPartialCompositeKeyWithPagination(objectType string, attributes []string,
+	pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	if err := s.simulation.paginatedQuery(); err != nil {
+		return nil, nil, err
+	}
+
+	prefix, err := s.CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keys := []string{}
+	for key := range s.State {
+		if strings.HasPrefix(key, prefix) && key >= bookmark {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	metadata := &pb.QueryResponseMetadata{}
+	if pageSize > 0 && len(keys) > int(pageSize) {
+		metadata.Bookmark = keys[pageSize]
+		keys = keys[:pageSize]
+	}
+	metadata.FetchedRecordsCount = int32(len(keys))
+
+	results := make([]*queryresult.KV, len(keys))
+	for i, key := range keys {
+		results[i] = &queryresult.KV{Key: key, Value: s.State[key]}
+	}
+
+	return &iterator{results: results}, metadata, nil
+}
+
+// iterator is a in-memory shim.StateQueryIteratorInterface
+type iterator struct {
+	results []*queryresult.KV
+}
+
+func (i *iterator) HasNext() bool {
+	return len(i.results) > 0
+}
+
+func (i *iterator) Next() (*queryresult.KV, error) {
+	if !i.HasNext() {
+		return nil, errors.New("iterator has no next element")
+	}
+
+	next := i.results[0]
+	i.results = i.results[1:]
+	return next, nil
+}
+
+func (i *iterator) Close() error {
+	return nil
+}
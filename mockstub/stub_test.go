@@ -0,0 +1,219 @@
+package mockstub_test
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cdtlab19/coffee-chaincode/mockstub"
+)
+
+// echoChaincode responds with the transient value of the key sent as it's
+// first parameter
+type echoChaincode struct{}
+
+func (echoChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	return shim.Success(nil)
+}
+
+func (echoChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	_, params := stub.GetFunctionAndParameters()
+	transient, _ := stub.GetTransient()
+	return shim.Success(transient[params[0]])
+}
+
+// putChaincode writes the key sent as it's first parameter
+type putChaincode struct{}
+
+func (putChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	return shim.Success(nil)
+}
+
+func (putChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	_, params := stub.GetFunctionAndParameters()
+	if err := stub.PutState(params[0], []byte("value")); err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(nil)
+}
+
+var _ = Describe("Stub", func() {
+	var stub *Stub
+
+	BeforeEach(func() {
+		stub = NewStub("echo", echoChaincode{})
+	})
+
+	It("Should send the transient map to the chaincode", func() {
+		result := stub.MockInvokeWithTransient("0000", [][]byte{
+			[]byte("Echo"),
+			[]byte("key"),
+		}, map[string][]byte{"key": []byte("value")})
+
+		Expect(int(result.Status)).To(Equal(shim.OK))
+		Expect(result.Payload).To(Equal([]byte("value")))
+
+		result = stub.MockInvoke("0001", [][]byte{
+			[]byte("Echo"),
+			[]byte("key"),
+		})
+		Expect(result.Payload).To(BeEmpty())
+	})
+
+	It("Should delete private data", func() {
+		stub.MockTransactionStart("0000")
+		defer stub.MockTransactionEnd("0000")
+
+		Expect(stub.PutPrivateData("collection", "key", []byte("value"))).To(Succeed())
+		Expect(stub.DelPrivateData("collection", "key")).To(Succeed())
+
+		value, err := stub.GetPrivateData("collection", "key")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(value).To(BeNil())
+	})
+
+	It("Should query private data by partial composite key", func() {
+		key1, _ := stub.CreateCompositeKey("type", []string{"1"})
+		key2, _ := stub.CreateCompositeKey("type", []string{"2"})
+		other, _ := stub.CreateCompositeKey("other", []string{"1"})
+
+		stub.MockTransactionStart("0000")
+		Expect(stub.PutPrivateData("collection", key2, []byte("2"))).To(Succeed())
+		Expect(stub.PutPrivateData("collection", key1, []byte("1"))).To(Succeed())
+		Expect(stub.PutPrivateData("collection", other, []byte("other"))).To(Succeed())
+		stub.MockTransactionEnd("0000")
+
+		iterator, err := stub.GetPrivateDataByPartialCompositeKey("collection", "type", []string{})
+		Expect(err).NotTo(HaveOccurred())
+		defer iterator.Close()
+
+		values := []string{}
+		for iterator.HasNext() {
+			kv, err := iterator.Next()
+			Expect(err).NotTo(HaveOccurred())
+			values = append(values, string(kv.Value))
+		}
+		Expect(values).To(Equal([]string{"1", "2"}))
+	})
+
+	It("Should query the state by partial composite key a page at a time", func() {
+		stub.MockTransactionStart("0000")
+		for _, id := range []string{"3", "1", "2"} {
+			key, _ := stub.CreateCompositeKey("type", []string{id})
+			Expect(stub.PutState(key, []byte(id))).To(Succeed())
+		}
+		other, _ := stub.CreateCompositeKey("other", []string{"1"})
+		Expect(stub.PutState(other, []byte("other"))).To(Succeed())
+		stub.MockTransactionEnd("0000")
+
+		values, bookmark := []string{}, ""
+		for pages := 0; pages == 0 || bookmark != ""; pages++ {
+			Expect(pages).To(BeNumerically("<", 3))
+
+			iterator, metadata, err := stub.GetStateByPartialCompositeKeyWithPagination("type", []string{}, 2, bookmark)
+			Expect(err).NotTo(HaveOccurred())
+			for iterator.HasNext() {
+				kv, err := iterator.Next()
+				Expect(err).NotTo(HaveOccurred())
+				values = append(values, string(kv.Value))
+			}
+			iterator.Close()
+			bookmark = metadata.Bookmark
+		}
+		Expect(values).To(Equal([]string{"1", "2", "3"}))
+	})
+})
+
+var _ = Describe("InvokeChaincode", func() {
+	It("Should invoke peer chaincodes with the caller's transient map", func() {
+		caller := NewStub("caller", echoChaincode{})
+		callee := NewStub("echo", echoChaincode{})
+		caller.MockPeerChaincode("echo", callee)
+
+		caller.MockTransactionStart("0000")
+		defer caller.MockTransactionEnd("0000")
+		caller.Transient = map[string][]byte{"key": []byte("value")}
+
+		result := caller.InvokeChaincode("echo", [][]byte{
+			[]byte("Echo"),
+			[]byte("key"),
+		}, "")
+		Expect(int(result.Status)).To(Equal(shim.OK))
+		Expect(result.Payload).To(Equal([]byte("value")))
+		Expect(callee.Transient).To(BeEmpty())
+	})
+
+	It("Should fail for unknown chaincodes", func() {
+		result := NewStub("caller", echoChaincode{}).InvokeChaincode("other", nil, "")
+		Expect(int(result.Status)).To(Equal(shim.ERROR))
+	})
+})
+
+var _ = Describe("Simulation", func() {
+	var stub *Stub
+
+	BeforeEach(func() {
+		stub = NewStub("put", putChaincode{})
+	})
+
+	It("Should fail writes after paginated queries", func() {
+		stub.MockTransactionStart("0000")
+		_, _, err := stub.GetStateByPartialCompositeKeyWithPagination("type", []string{}, 10, "")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(stub.PutState("key", []byte("value"))).To(
+			MatchError(ContainSubstring("Paginated queries are supported only in a read-only transaction")))
+		Expect(stub.DelState("key")).NotTo(Succeed())
+		Expect(stub.SetStateValidationParameter("key", []byte("ep"))).NotTo(Succeed())
+		stub.MockTransactionEnd("0000")
+
+		stub.MockTransactionStart("0001")
+		defer stub.MockTransactionEnd("0001")
+		Expect(stub.PutState("key", []byte("value"))).To(Succeed())
+	})
+
+	It("Should fail writes after private data queries", func() {
+		stub.MockTransactionStart("0000")
+		defer stub.MockTransactionEnd("0000")
+
+		_, err := stub.GetPrivateDataByPartialCompositeKey("collection", "type", []string{})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(stub.PutPrivateData("collection", "key", []byte("value"))).To(
+			MatchError(ContainSubstring("Queries on pvt data is supported only in a read-only transaction")))
+		Expect(stub.DelPrivateData("collection", "key")).NotTo(Succeed())
+		Expect(stub.PutState("key", []byte("value"))).NotTo(Succeed())
+	})
+
+	It("Should fail paginated and private data queries after writes", func() {
+		stub.MockTransactionStart("0000")
+		defer stub.MockTransactionEnd("0000")
+
+		Expect(stub.PutState("key", []byte("value"))).To(Succeed())
+
+		_, _, err := stub.GetStateByPartialCompositeKeyWithPagination("type", []string{}, 10, "")
+		Expect(err).To(HaveOccurred())
+		_, err = stub.GetPrivateDataByPartialCompositeKey("collection", "type", []string{})
+		Expect(err).To(HaveOccurred())
+
+		iterator, err := stub.GetStateByPartialCompositeKey("type", []string{})
+		Expect(err).NotTo(HaveOccurred())
+		iterator.Close()
+	})
+
+	It("Should share the simulation with invoked chaincodes", func() {
+		caller := NewStub("caller", putChaincode{})
+		caller.MockPeerChaincode("put", stub)
+
+		caller.MockTransactionStart("0000")
+		defer caller.MockTransactionEnd("0000")
+
+		_, _, err := caller.GetStateByPartialCompositeKeyWithPagination("type", []string{}, 10, "")
+		Expect(err).NotTo(HaveOccurred())
+
+		result := caller.InvokeChaincode("put", [][]byte{[]byte("Put"), []byte("key")}, "")
+		Expect(int(result.Status)).To(Equal(shim.ERROR))
+		Expect(result.Message).To(ContainSubstring("Paginated queries"))
+	})
+})
@@ -3,27 +3,40 @@ package model
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
 )
 
 // CoffeeDocType is the docType used in model
 const CoffeeDocType = "coffee"
 
+// CoffeeSchemaVersion is the current version of the coffee document
+const CoffeeSchemaVersion = 1
+
+// States of a coffee, as returned by Coffee.State
+const (
+	CoffeeAvailable = "available"
+	CoffeeUsed      = "used"
+	CoffeeDeleted   = "deleted"
+)
+
 // Coffee defines a basic model for coffee
 type Coffee struct {
-	DocType string `json:"docType"`
-	ID      string `json:"id"`
-	Flavour string `json:"flavour"`
-	Owner   string `json:"owner"`
+	DocType       string `json:"docType"`
+	SchemaVersion int    `json:"schemaVersion"`
+	ID            string `json:"id"`
+	Flavour       string `json:"flavour"`
+	Owner         string `json:"owner"`
+	Revisioned
+	Deletion
 }
 
 // NewCoffee creates a new Coffee
 func NewCoffee(id string, flavour string) *Coffee {
 	return &Coffee{
-		DocType: CoffeeDocType,
-		ID:      id,
-		Flavour: flavour,
-		Owner:   "",
+		DocType:       CoffeeDocType,
+		SchemaVersion: CoffeeSchemaVersion,
+		ID:            id,
+		Flavour:       flavour,
+		Owner:         "",
 	}
 }
 
@@ -42,15 +55,39 @@ func (c *Coffee) SetOwner(owner string) error {
 	return nil
 }
 
-// Valid verifies if a Coffee is valid
+// State returns if a coffee is available, was used by it's owner or was
+// deleted
+func (c *Coffee) State() string {
+	switch {
+	case c.IsDeleted():
+		return CoffeeDeleted
+	case c.HasOwner():
+		return CoffeeUsed
+	default:
+		return CoffeeAvailable
+	}
+}
+
+// Valid verifies if a Coffee is valid, returning a *ValidationError with
+// all invalid fields
 func (c *Coffee) Valid() error {
+	e := &ValidationError{DocType: CoffeeDocType}
 	if c.DocType != CoffeeDocType {
-		return fmt.Errorf("coffee docType not set to '%s'", CoffeeDocType)
+		e.add("docType", "not set to '%s'", CoffeeDocType)
 	}
-	if c.ID == "" {
-		return fmt.Errorf("missing coffee ID")
+	if c.SchemaVersion != CoffeeSchemaVersion {
+		e.add("schemaVersion", "not set to %d", CoffeeSchemaVersion)
 	}
-	return nil
+	e.validID("id", c.ID)
+	if !flavourPattern.MatchString(c.Flavour) {
+		e.add("flavour", "must have up to 32 letters, spaces or '-'")
+	}
+	if c.HasOwner() {
+		e.validID("owner", c.Owner)
+	}
+	c.Revisioned.valid(e)
+	c.Deletion.valid(e)
+	return e.err()
 }
 
 // JSON encodes a coffe model as a JSON object
@@ -2,6 +2,7 @@ package model_test
 
 import (
 	"encoding/json"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -36,12 +37,31 @@ var _ = Describe("Coffee", func() {
 		Expect(coffee.Owner).To(Equal("test"))
 	})
 
+	It("should report it's state", func() {
+		coffee := NewCoffee("0000", "cappuccino")
+		Expect(coffee.State()).To(Equal(CoffeeAvailable))
+
+		Expect(coffee.SetOwner("owner")).To(Succeed())
+		Expect(coffee.State()).To(Equal(CoffeeUsed))
+
+		Expect(coffee.Delete(NewTombstone(time.Now(), "Org1MSP/admin", ""))).To(Succeed())
+		Expect(coffee.State()).To(Equal(CoffeeDeleted))
+	})
+
 	It("should have a valid ID", func() {
 		coffee := NewCoffee("", "cappuccino")
 		err := coffee.Valid()
 		Expect(err).To(HaveOccurred())
 	})
 
+	It("should have the current schema version", func() {
+		coffee := NewCoffee("id", "cappuccino")
+		Expect(coffee.SchemaVersion).To(Equal(CoffeeSchemaVersion))
+
+		coffee.SchemaVersion = 0
+		Expect(coffee.Valid()).To(HaveOccurred())
+	})
+
 	It("should have a valid DocType", func() {
 		coffee := NewCoffee("id", "cappuccino")
 		coffee.DocType = ""
@@ -54,8 +74,9 @@ var _ = Describe("Coffee", func() {
 		coffee := NewCoffee("id", "chocolate")
 		jsonObject := coffee.JSON()
 
-		var raw map[string]string
+		var raw map[string]interface{}
 		Expect(json.Unmarshal(jsonObject, &raw)).NotTo(HaveOccurred())
+		Expect(raw).To(HaveKeyWithValue("schemaVersion", float64(CoffeeSchemaVersion)))
 		Expect(raw).To(HaveKeyWithValue("id", coffee.ID))
 		Expect(raw).To(HaveKeyWithValue("flavour", coffee.Flavour))
 		Expect(raw).To(HaveKeyWithValue("docType", coffee.DocType))
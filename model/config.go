@@ -0,0 +1,158 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ConfigDocType is the DocType used in model
+const ConfigDocType = "config"
+
+// ConfigVersion is the current version of the configuration format
+const ConfigVersion = 5
+
+// Features which may be toggled in the configuration
+const (
+	// FeatureBatch enables the Batch function
+	FeatureBatch = "batch"
+)
+
+// logLevels are the levels of the chaincode logs
+var logLevels = map[string]bool{
+	"CRITICAL": true,
+	"ERROR":    true,
+	"WARNING":  true,
+	"NOTICE":   true,
+	"INFO":     true,
+	"DEBUG":    true,
+}
+
+// Admin identifies an administrator by it's organization and certificate
+// common name
+type Admin struct {
+	MSPID string `json:"mspId"`
+	Name  string `json:"name"`
+}
+
+// Config defines the chaincode configuration, set when the chaincode is
+// instantiated or upgraded
+type Config struct {
+	DocType        string  `json:"docType"`
+	Version        int     `json:"version"`
+	DefaultCredits int     `json:"defaultCredits"`
+	MaxCredits     int     `json:"maxCredits"`
+	Admins         []Admin `json:"admins"`
+	// PaymentProcessors are the identities which may record payments, besides
+	// the administrators
+	PaymentProcessors  []Admin         `json:"paymentProcessors"`
+	Features           map[string]bool `json:"features"`
+	RetentionDays      int             `json:"retentionDays"`
+	RequestExpiryHours int             `json:"requestExpiryHours"`
+	// LogLevel overrides the level of the chaincode logs, such as "DEBUG",
+	// if set
+	LogLevel string `json:"logLevel,omitempty"`
+	Revisioned
+}
+
+// NewConfig creates a configuration with the default values
+func NewConfig() *Config {
+	return &Config{
+		DocType:            ConfigDocType,
+		Version:            ConfigVersion,
+		DefaultCredits:     10,
+		MaxCredits:         100,
+		Admins:             []Admin{},
+		PaymentProcessors:  []Admin{},
+		Features:           map[string]bool{FeatureBatch: true},
+		RetentionDays:      30,
+		RequestExpiryHours: 24,
+	}
+}
+
+// IsAdmin verifies if an identity is one of the configured administrators
+func (c *Config) IsAdmin(mspID, name string) bool {
+	for _, admin := range c.Admins {
+		if admin.MSPID == mspID && admin.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPaymentProcessor verifies if an identity is one of the configured payment
+// processors
+func (c *Config) IsPaymentProcessor(mspID, name string) bool {
+	for _, processor := range c.PaymentProcessors {
+		if processor.MSPID == mspID && processor.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Enabled verifies if a feature is enabled
+func (c *Config) Enabled(feature string) bool {
+	return c.Features[feature]
+}
+
+// Retention returns how long deleted assets must be kept before they may be
+// purged
+func (c *Config) Retention() time.Duration {
+	return time.Duration(c.RetentionDays) * 24 * time.Hour
+}
+
+// RequestExpiry returns how long client request IDs are remembered
+func (c *Config) RequestExpiry() time.Duration {
+	return time.Duration(c.RequestExpiryHours) * time.Hour
+}
+
+// Valid verifies if a Config is valid
+func (c *Config) Valid() error {
+	if c.DocType != ConfigDocType {
+		return fmt.Errorf("config docType not set to '%s'", ConfigDocType)
+	}
+	if c.Version != ConfigVersion {
+		return fmt.Errorf("unsupported config version %d", c.Version)
+	}
+	if c.DefaultCredits < 0 {
+		return errors.New("default credits can't be negative")
+	}
+	if c.MaxCredits < c.DefaultCredits {
+		return errors.New("max credits can't be lower than the default credits")
+	}
+	if c.MaxCredits > MaxRemainingCoffee {
+		return fmt.Errorf("max credits can't be greater than %d", MaxRemainingCoffee)
+	}
+	if c.Revision < 0 {
+		return errors.New("revision can't be negative")
+	}
+	if c.RetentionDays < 0 {
+		return errors.New("retention days can't be negative")
+	}
+	if c.RequestExpiryHours < 1 {
+		return errors.New("request expiry must be at least one hour")
+	}
+	if c.LogLevel != "" && !logLevels[strings.ToUpper(c.LogLevel)] {
+		return fmt.Errorf("invalid log level '%s'", c.LogLevel)
+	}
+	for _, admin := range c.Admins {
+		if admin.MSPID == "" || admin.Name == "" {
+			return errors.New("admins must have both mspId and name")
+		}
+	}
+	for _, processor := range c.PaymentProcessors {
+		if processor.MSPID == "" || processor.Name == "" {
+			return errors.New("payment processors must have both mspId and name")
+		}
+	}
+	return nil
+}
+
+// JSON encodes a config model as a JSON object
+func (c *Config) JSON() []byte {
+	v, _ := json.Marshal(c)
+	return v
+}
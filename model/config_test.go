@@ -0,0 +1,89 @@
+package model_test
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cdtlab19/coffee-chaincode/model"
+)
+
+var _ = Describe("Config", func() {
+	It("Should create a valid default config", func() {
+		config := NewConfig()
+		Expect(config.DocType).To(Equal(ConfigDocType))
+		Expect(config.Version).To(Equal(ConfigVersion))
+		Expect(config.DefaultCredits).To(Equal(10))
+		Expect(config.Valid()).NotTo(HaveOccurred())
+	})
+
+	It("Should not allow negative default credits", func() {
+		config := NewConfig()
+		config.DefaultCredits = -1
+		Expect(config.Valid()).To(HaveOccurred())
+	})
+
+	It("Should not allow max credits lower than the default credits", func() {
+		config := NewConfig()
+		config.MaxCredits = config.DefaultCredits - 1
+		Expect(config.Valid()).To(HaveOccurred())
+	})
+
+	It("Should not allow a negative retention", func() {
+		config := NewConfig()
+		config.RetentionDays = -1
+		Expect(config.Valid()).To(HaveOccurred())
+	})
+
+	It("Should require a request expiry of at least one hour", func() {
+		config := NewConfig()
+		config.RequestExpiryHours = 0
+		Expect(config.Valid()).To(HaveOccurred())
+	})
+
+	It("Should not allow incomplete admins", func() {
+		config := NewConfig()
+		config.Admins = []Admin{{MSPID: "Org1MSP"}}
+		Expect(config.Valid()).To(HaveOccurred())
+	})
+
+	It("Should only accept known log levels", func() {
+		config := NewConfig()
+		config.LogLevel = "debug"
+		Expect(config.Valid()).To(Succeed())
+
+		config.LogLevel = "verbose"
+		Expect(config.Valid()).To(HaveOccurred())
+	})
+
+	It("Should only accept the current version", func() {
+		config := NewConfig()
+		config.Version = ConfigVersion + 1
+		Expect(config.Valid()).To(HaveOccurred())
+	})
+
+	It("Should verify admins", func() {
+		config := NewConfig()
+		config.Admins = []Admin{{MSPID: "Org1MSP", Name: "admin"}}
+
+		Expect(config.IsAdmin("Org1MSP", "admin")).To(BeTrue())
+		Expect(config.IsAdmin("Org2MSP", "admin")).To(BeFalse())
+		Expect(config.IsAdmin("Org1MSP", "someone")).To(BeFalse())
+	})
+
+	It("Should verify features", func() {
+		config := NewConfig()
+		config.Features["feature"] = true
+
+		Expect(config.Enabled("feature")).To(BeTrue())
+		Expect(config.Enabled("other")).To(BeFalse())
+		Expect(config.Enabled(FeatureBatch)).To(BeTrue())
+	})
+
+	It("Should be encodable", func() {
+		var config Config
+		Expect(json.Unmarshal(NewConfig().JSON(), &config)).NotTo(HaveOccurred())
+		Expect(config).To(Equal(*NewConfig()))
+	})
+})
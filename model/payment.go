@@ -0,0 +1,123 @@
+package model
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// PaymentDocType is the DocType used in model
+const PaymentDocType = "payment"
+
+// PaymentKeyDocType is the DocType used in model
+const PaymentKeyDocType = "paymentKey"
+
+// MinPaymentKeySize is the minimum size of a payment key, in bytes
+const MinPaymentKeySize = 16
+
+// Payment records a purchase of credits. It's stored in a private data
+// collection, so the payment reference isn't recorded in the public state,
+// and identified by the reference's HMAC, so each reference is used once
+type Payment struct {
+	DocType   string    `json:"docType"`
+	ID        string    `json:"id"`
+	UserID    string    `json:"userId"`
+	Credits   int       `json:"credits"`
+	Reference string    `json:"reference"`
+	PaidAt    time.Time `json:"paidAt"`
+}
+
+// NewPayment records the purchase of `credits` by an user, identified by the
+// payment key
+func NewPayment(key *PaymentKey, reference, userID string, credits int, paidAt time.Time) *Payment {
+	return &Payment{
+		DocType:   PaymentDocType,
+		ID:        key.PaymentID(reference),
+		UserID:    userID,
+		Credits:   credits,
+		Reference: reference,
+		PaidAt:    paidAt.UTC(),
+	}
+}
+
+// LegacyPaymentID returns the ID of a payment recorded before payments were
+// identified by the payment key, the SHA-256 hash of the reference as an
+// hexadecimal string
+func LegacyPaymentID(reference string) string {
+	sum := sha256.Sum256([]byte(reference))
+	return hex.EncodeToString(sum[:])
+}
+
+// Valid verifies if a Payment is valid, returning a *ValidationError with all
+// invalid fields
+func (p *Payment) Valid() error {
+	e := &ValidationError{DocType: PaymentDocType}
+	if p.DocType != PaymentDocType {
+		e.add("docType", "not set to '%s'", PaymentDocType)
+	}
+	e.validID("id", p.ID)
+	e.validID("userId", p.UserID)
+	if p.Credits < 1 {
+		e.add("credits", "must be positive")
+	} else if p.Credits > MaxRemainingCoffee {
+		e.add("credits", "can't be greater than %d", MaxRemainingCoffee)
+	}
+	if p.Reference == "" {
+		e.add("reference", "missing")
+	} else if !referencePattern.MatchString(p.Reference) {
+		e.add("reference", "must have up to 128 letters, digits, '-', '_', '.', ':' or '/'")
+	}
+	if p.PaidAt.IsZero() {
+		e.add("paidAt", "missing")
+	}
+	return e.err()
+}
+
+// JSON encodes a payment model as a JSON object
+func (p *Payment) JSON() []byte {
+	v, _ := json.Marshal(p)
+	return v
+}
+
+// PaymentKey is the secret key of the payments' IDs. The hashes of private
+// data keys are recorded in the public state, so payments are identified by
+// an HMAC of their reference, which can't be guessed without the key. It's
+// stored in the same private data collection as the payments
+type PaymentKey struct {
+	DocType string `json:"docType"`
+	Key     []byte `json:"key"`
+}
+
+// NewPaymentKey creates a payment key
+func NewPaymentKey(key []byte) *PaymentKey {
+	return &PaymentKey{DocType: PaymentKeyDocType, Key: key}
+}
+
+// PaymentID returns the ID of the payment with a reference, the HMAC-SHA256
+// of the reference as an hexadecimal string
+func (k *PaymentKey) PaymentID(reference string) string {
+	mac := hmac.New(sha256.New, k.Key)
+	mac.Write([]byte(reference))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Valid verifies if a PaymentKey is valid, returning a *ValidationError with
+// all invalid fields
+func (k *PaymentKey) Valid() error {
+	e := &ValidationError{DocType: PaymentKeyDocType}
+	if k.DocType != PaymentKeyDocType {
+		e.add("docType", "not set to '%s'", PaymentKeyDocType)
+	}
+	if len(k.Key) < MinPaymentKeySize {
+		e.add("key", "must have at least %d bytes", MinPaymentKeySize)
+	}
+	return e.err()
+}
+
+// JSON encodes a payment key model as a JSON object
+func (k *PaymentKey) JSON() []byte {
+	v, _ := json.Marshal(k)
+	return v
+}
@@ -0,0 +1,59 @@
+package model_test
+
+import (
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cdtlab19/coffee-chaincode/model"
+)
+
+var _ = Describe("Payment", func() {
+	paidAt := time.Date(2019, 5, 1, 12, 0, 0, 0, time.UTC)
+	key := NewPaymentKey([]byte("0123456789abcdef"))
+
+	It("Should create a valid payment identified by it's reference", func() {
+		payment := NewPayment(key, "PAY-0001", "user1", 10, paidAt)
+		Expect(payment.DocType).To(Equal(PaymentDocType))
+		Expect(payment.ID).To(Equal(key.PaymentID("PAY-0001")))
+		Expect(payment.ID).NotTo(ContainSubstring("PAY-0001"))
+		Expect(payment.Valid()).NotTo(HaveOccurred())
+	})
+
+	It("Should identify payments by the reference's HMAC", func() {
+		other := NewPaymentKey([]byte("fedcba9876543210"))
+
+		Expect(key.PaymentID("PAY-0001")).To(HaveLen(64))
+		Expect(key.PaymentID("PAY-0001")).NotTo(Equal(key.PaymentID("PAY-0002")))
+		Expect(key.PaymentID("PAY-0001")).NotTo(Equal(other.PaymentID("PAY-0001")))
+		Expect(key.PaymentID("PAY-0001")).NotTo(Equal(LegacyPaymentID("PAY-0001")))
+	})
+
+	It("Should not allow short payment keys", func() {
+		Expect(key.Valid()).To(Succeed())
+		Expect(NewPaymentKey([]byte("short")).Valid()).To(MatchError(ContainSubstring("key: must have at least 16 bytes")))
+	})
+
+	It("Should not allow invalid payments", func() {
+		payment := NewPayment(key, "", "", 0, time.Time{})
+
+		err := payment.Valid()
+		Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+		Expect(err.(*ValidationError).Fields).To(ConsistOf(
+			FieldError{Field: "userId", Message: "missing"},
+			FieldError{Field: "credits", Message: "must be positive"},
+			FieldError{Field: "reference", Message: "missing"},
+			FieldError{Field: "paidAt", Message: "missing"},
+		))
+	})
+
+	It("Should not allow invalid references", func() {
+		for _, reference := range []string{"has space", strings.Repeat("a", 129)} {
+			err := NewPayment(key, reference, "user1", 1, paidAt).Valid()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("reference"))
+		}
+	})
+})
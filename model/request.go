@@ -0,0 +1,114 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// RequestDocType is the DocType used in model
+const RequestDocType = "request"
+
+// Request records an invocation processed with a client request ID and it's
+// response, which is returned again if the same creator retries it
+type Request struct {
+	DocType string `json:"docType"`
+	ID      string `json:"id"`
+	// Creator identifies the creator of the request, whose request IDs are
+	// apart from other creators' ones
+	Creator  string `json:"creator"`
+	Function string `json:"function"`
+	ArgsHash string `json:"argsHash"`
+	// TransientHash is the hash of the transient inputs of the function, which
+	// aren't recorded
+	TransientHash string    `json:"transientHash"`
+	CreatedAt     time.Time `json:"createdAt"`
+	Status        int32     `json:"status"`
+	Message       string    `json:"message,omitempty"`
+	Payload       []byte    `json:"payload,omitempty"`
+}
+
+// NewRequest records the response of an invocation, with the transient
+// inputs declared by the function
+func NewRequest(creator, id, function string, args []string, transient map[string][]byte,
+	createdAt time.Time, response pb.Response) *Request {
+	return &Request{
+		DocType:       RequestDocType,
+		ID:            id,
+		Creator:       creator,
+		Function:      function,
+		ArgsHash:      hash(args),
+		TransientHash: hash(transient),
+		CreatedAt:     createdAt.UTC(),
+		Status:        response.Status,
+		Message:       response.Message,
+		Payload:       response.Payload,
+	}
+}
+
+// RequestKey returns the key of a request ID sent by a creator
+func RequestKey(creator, id string) string {
+	return hash([]string{creator, id})
+}
+
+// hash returns the SHA-256 hash of the JSON encoding of invocation inputs,
+// as an hexadecimal string. Maps are encoded with sorted keys
+func hash(v interface{}) string {
+	data, _ := json.Marshal(v)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Key returns the key of the request, scoped by it's creator
+func (r *Request) Key() string {
+	return RequestKey(r.Creator, r.ID)
+}
+
+// Matches verifies if an invocation is a retry of the recorded request
+func (r *Request) Matches(function string, args []string, transient map[string][]byte) bool {
+	return r.Function == function && r.ArgsHash == hash(args) && r.TransientHash == hash(transient)
+}
+
+// Expired verifies if a request was recorded longer than `expiry` before
+// `now`
+func (r *Request) Expired(now time.Time, expiry time.Duration) bool {
+	return !now.Before(r.CreatedAt.Add(expiry))
+}
+
+// Response returns the recorded response
+func (r *Request) Response() pb.Response {
+	return pb.Response{
+		Status:  r.Status,
+		Message: r.Message,
+		Payload: r.Payload,
+	}
+}
+
+// Valid verifies if a Request is valid, returning a *ValidationError with all
+// invalid fields
+func (r *Request) Valid() error {
+	e := &ValidationError{DocType: RequestDocType}
+	if r.DocType != RequestDocType {
+		e.add("docType", "not set to '%s'", RequestDocType)
+	}
+	e.validID("id", r.ID)
+	if r.Creator == "" {
+		e.add("creator", "missing")
+	}
+	if r.Function == "" {
+		e.add("function", "missing")
+	}
+	if r.CreatedAt.IsZero() {
+		e.add("createdAt", "missing")
+	}
+	return e.err()
+}
+
+// JSON encodes a request model as a JSON object
+func (r *Request) JSON() []byte {
+	v, _ := json.Marshal(r)
+	return v
+}
@@ -0,0 +1,65 @@
+package model_test
+
+import (
+	"time"
+
+	pb "github.com/hyperledger/fabric/protos/peer"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cdtlab19/coffee-chaincode/model"
+)
+
+var _ = Describe("Request", func() {
+	createdAt := time.Date(2019, 5, 1, 12, 0, 0, 0, time.UTC)
+	response := pb.Response{Status: 200, Payload: []byte(`{"ok":true}`)}
+
+	It("Should create a valid request", func() {
+		request := NewRequest("Org1MSP/someone", "req-1", "CreateCoffee", []string{"cappuccino"}, nil,
+			createdAt, response)
+		Expect(request.DocType).To(Equal(RequestDocType))
+		Expect(request.Valid()).NotTo(HaveOccurred())
+		Expect(request.Response()).To(Equal(response))
+	})
+
+	It("Should not allow requests without creator, ID or function", func() {
+		request := NewRequest("", "", "", nil, nil, createdAt, response)
+
+		err := request.Valid()
+		Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+		Expect(err.(*ValidationError).Fields).To(ConsistOf(
+			FieldError{Field: "id", Message: "missing"},
+			FieldError{Field: "creator", Message: "missing"},
+			FieldError{Field: "function", Message: "missing"},
+		))
+	})
+
+	It("Should match only the same function, arguments and transient inputs", func() {
+		transient := map[string][]byte{"paymentReference": []byte("PAY-1")}
+		request := NewRequest("Org1MSP/someone", "req-1", "UseCoffee", []string{"c1", "u1"}, transient,
+			createdAt, response)
+
+		Expect(request.Matches("UseCoffee", []string{"c1", "u1"}, transient)).To(BeTrue())
+		Expect(request.Matches("UseCoffee", []string{"c1", "u2"}, transient)).To(BeFalse())
+		Expect(request.Matches("UseCoffee", []string{"c1u1"}, transient)).To(BeFalse())
+		Expect(request.Matches("DeleteCoffee", []string{"c1", "u1"}, transient)).To(BeFalse())
+		Expect(request.Matches("UseCoffee", []string{"c1", "u1"}, map[string][]byte{
+			"paymentReference": []byte("PAY-2"),
+		})).To(BeFalse())
+		Expect(request.Matches("UseCoffee", []string{"c1", "u1"}, nil)).To(BeFalse())
+	})
+
+	It("Should scope keys by creator", func() {
+		request := NewRequest("Org1MSP/someone", "req-1", "CreateCoffee", nil, nil, createdAt, response)
+
+		Expect(request.Key()).To(Equal(RequestKey("Org1MSP/someone", "req-1")))
+		Expect(request.Key()).NotTo(Equal(RequestKey("Org2MSP/someone", "req-1")))
+	})
+
+	It("Should expire after the expiry", func() {
+		request := NewRequest("Org1MSP/someone", "req-1", "CreateCoffee", nil, nil, createdAt, response)
+
+		Expect(request.Expired(createdAt.Add(time.Hour-time.Second), time.Hour)).To(BeFalse())
+		Expect(request.Expired(createdAt.Add(time.Hour), time.Hour)).To(BeTrue())
+	})
+})
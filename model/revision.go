@@ -0,0 +1,24 @@
+package model
+
+// Revisioned is embedded in assets whose writes are counted, so clients may
+// detect changes made since they read an asset
+type Revisioned struct {
+	Revision int `json:"revision"`
+}
+
+// GetRevision returns the asset's revision
+func (r *Revisioned) GetRevision() int {
+	return r.Revision
+}
+
+// SetRevision sets the asset's revision
+func (r *Revisioned) SetRevision(revision int) {
+	r.Revision = revision
+}
+
+// valid verifies if a revision isn't negative
+func (r *Revisioned) valid(e *ValidationError) {
+	if r.Revision < 0 {
+		e.add("revision", "can't be negative")
+	}
+}
@@ -0,0 +1,85 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Tombstone records who deleted an asset, when and why, and what was done
+// to other assets because of it. Deleted assets are kept in the ledger for
+// audits until they are purged
+type Tombstone struct {
+	DeletedAt time.Time `json:"deletedAt"`
+	DeletedBy string    `json:"deletedBy"`
+	Reason    string    `json:"reason,omitempty"`
+	Actions   []string  `json:"actions,omitempty"`
+}
+
+// NewTombstone creates a tombstone for an asset deleted at `deletedAt`
+func NewTombstone(deletedAt time.Time, deletedBy, reason string) *Tombstone {
+	return &Tombstone{
+		DeletedAt: deletedAt.UTC(),
+		DeletedBy: deletedBy,
+		Reason:    reason,
+	}
+}
+
+// Record appends an action done because of the deletion
+func (t *Tombstone) Record(format string, args ...interface{}) {
+	t.Actions = append(t.Actions, fmt.Sprintf(format, args...))
+}
+
+// Deletion is embedded in assets which are soft deleted by a tombstone
+type Deletion struct {
+	Deleted *Tombstone `json:"deleted,omitempty"`
+}
+
+// IsDeleted verifies if an asset was deleted
+func (d *Deletion) IsDeleted() bool {
+	return d.Deleted != nil
+}
+
+// Tombstone returns the asset's tombstone, or nil if it's not deleted
+func (d *Deletion) Tombstone() *Tombstone {
+	return d.Deleted
+}
+
+// Delete marks an asset as deleted
+func (d *Deletion) Delete(tombstone *Tombstone) error {
+	if d.IsDeleted() {
+		return errors.New("already deleted")
+	}
+	if tombstone == nil {
+		return errors.New("missing tombstone")
+	}
+
+	d.Deleted = tombstone
+	return nil
+}
+
+// Restore removes an asset's tombstone
+func (d *Deletion) Restore() error {
+	if !d.IsDeleted() {
+		return errors.New("not deleted")
+	}
+
+	d.Deleted = nil
+	return nil
+}
+
+// valid verifies if a tombstone is complete
+func (d *Deletion) valid(e *ValidationError) {
+	if !d.IsDeleted() {
+		return
+	}
+	if d.Deleted.DeletedAt.IsZero() {
+		e.add("deleted.deletedAt", "missing")
+	}
+	if d.Deleted.DeletedBy == "" {
+		e.add("deleted.deletedBy", "missing")
+	}
+	if len([]rune(d.Deleted.Reason)) > 256 {
+		e.add("deleted.reason", "must have up to 256 characters")
+	}
+}
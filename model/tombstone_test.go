@@ -0,0 +1,59 @@
+package model_test
+
+import (
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cdtlab19/coffee-chaincode/model"
+)
+
+var _ = Describe("Tombstone", func() {
+	deletedAt := time.Date(2019, 4, 1, 12, 0, 0, 0, time.UTC)
+
+	It("Should delete and restore assets", func() {
+		coffee := NewCoffee("0000", "cappuccino")
+		Expect(coffee.IsDeleted()).To(BeFalse())
+
+		Expect(coffee.Delete(NewTombstone(deletedAt, "Org1MSP/admin", "expired"))).To(Succeed())
+		Expect(coffee.IsDeleted()).To(BeTrue())
+		Expect(coffee.Valid()).To(Succeed())
+
+		Expect(coffee.Restore()).To(Succeed())
+		Expect(coffee.IsDeleted()).To(BeFalse())
+	})
+
+	It("Should not delete twice nor restore assets which weren't deleted", func() {
+		user := NewUser("0000", 3)
+		Expect(user.Restore()).NotTo(Succeed())
+
+		Expect(user.Delete(NewTombstone(deletedAt, "Org1MSP/admin", ""))).To(Succeed())
+		Expect(user.Delete(NewTombstone(deletedAt, "Org1MSP/admin", ""))).NotTo(Succeed())
+	})
+
+	It("Should record the actions done because of the deletion", func() {
+		tombstone := NewTombstone(deletedAt, "Org1MSP/admin", "")
+		tombstone.Record("forfeited %d remaining coffees", 3)
+		Expect(tombstone.Actions).To(Equal([]string{"forfeited 3 remaining coffees"}))
+	})
+
+	It("Should omit the tombstone of assets which weren't deleted", func() {
+		var doc map[string]interface{}
+		Expect(json.Unmarshal(NewCoffee("0000", "cappuccino").JSON(), &doc)).To(Succeed())
+		Expect(doc).NotTo(HaveKey("deleted"))
+	})
+
+	It("Should require who deleted an asset and when", func() {
+		user := NewUser("0000", 3)
+		user.Deleted = &Tombstone{}
+
+		err := user.Valid()
+		Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+		Expect(err.(*ValidationError).Fields).To(ConsistOf(
+			FieldError{Field: "deleted.deletedAt", Message: "missing"},
+			FieldError{Field: "deleted.deletedBy", Message: "missing"},
+		))
+	})
+})
@@ -9,20 +9,29 @@ import (
 // UserDocType is the DocType use in model
 const UserDocType = "user"
 
-// User defines a basic model for an user
+// UserSchemaVersion is the current version of the user document. Version 0
+// stored the user's name in the public state
+const UserSchemaVersion = 1
+
+// User defines a basic model for an user. Personal information is kept in
+// a private data collection as an UserInfo, and only it's hash is stored in
+// the public state
 type User struct {
 	DocType         string `json:"docType"`
+	SchemaVersion   int    `json:"schemaVersion"`
 	ID              string `json:"id"`
-	Name            string `json:"name"`
 	RemainingCoffee int    `json:"remainingCoffee"`
+	InfoHash        string `json:"infoHash,omitempty"`
+	Revisioned
+	Deletion
 }
 
 // NewUser creates an user with a exact amount of remaining coffees
-func NewUser(id, name string, remainingCoffee int) *User {
+func NewUser(id string, remainingCoffee int) *User {
 	return &User{
 		DocType:         UserDocType,
+		SchemaVersion:   UserSchemaVersion,
 		ID:              id,
-		Name:            name,
 		RemainingCoffee: remainingCoffee,
 	}
 }
@@ -38,22 +47,45 @@ func (u *User) DrinkCoffee() error {
 	return nil
 }
 
-// Valid verifies if an User is valid
-func (u *User) Valid() error {
-	if u.DocType != UserDocType {
-		return fmt.Errorf("user docType not set to '%s'", UserDocType)
+// TopUp adds purchased credits to the user's remaining coffees, which can't
+// exceed `max`
+func (u *User) TopUp(credits, max int) error {
+	if credits < 1 {
+		return errors.New("credits must be positive")
 	}
-	if u.ID == "" {
-		return fmt.Errorf("missing user ID")
+
+	if u.RemainingCoffee+credits > max {
+		return fmt.Errorf("user can't have more than %d remaining coffees", max)
 	}
 
-	if u.Name == "" {
-		return fmt.Errorf("missing user name")
+	u.RemainingCoffee = u.RemainingCoffee + credits
+	return nil
+}
+
+// SetInfo links an user to it's private information by storing it's hash
+func (u *User) SetInfo(info *UserInfo) {
+	u.InfoHash = info.Hash()
+}
+
+// Valid verifies if an User is valid, returning a *ValidationError with all
+// invalid fields
+func (u *User) Valid() error {
+	e := &ValidationError{DocType: UserDocType}
+	if u.DocType != UserDocType {
+		e.add("docType", "not set to '%s'", UserDocType)
+	}
+	if u.SchemaVersion != UserSchemaVersion {
+		e.add("schemaVersion", "not set to %d", UserSchemaVersion)
 	}
+	e.validID("id", u.ID)
 	if u.RemainingCoffee < 0 {
-		return errors.New("user has negative number of remaining coffees")
+		e.add("remainingCoffee", "can't be negative")
+	} else if u.RemainingCoffee > MaxRemainingCoffee {
+		e.add("remainingCoffee", "can't be greater than %d", MaxRemainingCoffee)
 	}
-	return nil
+	u.Revisioned.valid(e)
+	u.Deletion.valid(e)
+	return e.err()
 }
 
 // JSON encodes an user model as a JSON object
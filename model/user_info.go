@@ -0,0 +1,89 @@
+package model
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+)
+
+// UserInfoDocType is the DocType used in model
+const UserInfoDocType = "userInfo"
+
+// UserInfoSchemaVersion is the current version of the user info document
+const UserInfoSchemaVersion = 1
+
+// MinSaltLength is the minimum length of the salt of an user info
+const MinSaltLength = 16
+
+// UserInfo defines the personal information of an user, which must be
+// stored in a private data collection
+type UserInfo struct {
+	DocType       string `json:"docType"`
+	SchemaVersion int    `json:"schemaVersion"`
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	Email         string `json:"email,omitempty"`
+	Badge         string `json:"badge,omitempty"`
+	// Salt is a random value sent by the client, which keys the hash kept in
+	// the public state. Information migrated from the public state, which was
+	// already public, has no salt
+	Salt string `json:"salt,omitempty"`
+}
+
+// NewUserInfo creates the personal information of an user
+func NewUserInfo(id, name, email, badge string) *UserInfo {
+	return &UserInfo{
+		DocType:       UserInfoDocType,
+		SchemaVersion: UserInfoSchemaVersion,
+		ID:            id,
+		Name:          name,
+		Email:         email,
+		Badge:         badge,
+	}
+}
+
+// Valid verifies if an UserInfo is valid, returning a *ValidationError with
+// all invalid fields
+func (u *UserInfo) Valid() error {
+	e := &ValidationError{DocType: UserInfoDocType}
+	if u.DocType != UserInfoDocType {
+		e.add("docType", "not set to '%s'", UserInfoDocType)
+	}
+	if u.SchemaVersion != UserInfoSchemaVersion {
+		e.add("schemaVersion", "not set to %d", UserInfoSchemaVersion)
+	}
+	e.validID("id", u.ID)
+	if name := strings.TrimSpace(u.Name); name == "" {
+		e.add("name", "missing")
+	} else if utf8.RuneCountInString(u.Name) > 64 {
+		e.add("name", "must have up to 64 characters")
+	}
+	if u.Email != "" && !emailPattern.MatchString(u.Email) {
+		e.add("email", "invalid email address")
+	}
+	if u.Badge != "" && !badgePattern.MatchString(u.Badge) {
+		e.add("badge", "must have up to 16 letters, digits or '-'")
+	}
+	if u.Salt != "" && len(u.Salt) < MinSaltLength {
+		e.add("salt", "must have at least %d characters", MinSaltLength)
+	}
+	return e.err()
+}
+
+// Hash returns the hex encoded HMAC-SHA256 of the user info JSON, keyed by
+// it's salt. Personal information has little entropy, so an unsalted hash
+// could be matched by hashing guessed names and e-mails
+func (u *UserInfo) Hash() string {
+	mac := hmac.New(sha256.New, []byte(u.Salt))
+	mac.Write(u.JSON())
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// JSON encodes an user info model as a JSON object
+func (u *UserInfo) JSON() []byte {
+	v, _ := json.Marshal(u)
+	return v
+}
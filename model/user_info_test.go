@@ -0,0 +1,71 @@
+package model_test
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cdtlab19/coffee-chaincode/model"
+)
+
+var _ = Describe("UserInfo", func() {
+	It("Should create a valid user info", func() {
+		info := NewUserInfo("id", "someone", "someone@example.com", "42")
+		Expect(info.DocType).To(Equal(UserInfoDocType))
+		Expect(info.ID).To(Equal("id"))
+		Expect(info.Name).To(Equal("someone"))
+		Expect(info.Email).To(Equal("someone@example.com"))
+		Expect(info.Badge).To(Equal("42"))
+
+		Expect(info.Valid()).NotTo(HaveOccurred())
+	})
+
+	It("Should have a valid ID", func() {
+		info := NewUserInfo("", "someone", "", "")
+		Expect(info.Valid()).To(HaveOccurred())
+	})
+
+	It("Should have a valid name", func() {
+		info := NewUserInfo("id", "", "", "")
+		Expect(info.Valid()).To(HaveOccurred())
+	})
+
+	It("Should have a valid docType", func() {
+		info := NewUserInfo("id", "someone", "", "")
+		info.DocType = ""
+		Expect(info.Valid()).To(HaveOccurred())
+	})
+
+	It("Should have a stable hash keyed by it's salt", func() {
+		salted := func(name, salt string) *UserInfo {
+			info := NewUserInfo("id", name, "", "")
+			info.Salt = salt
+			return info
+		}
+
+		info := salted("someone", "0123456789abcdef")
+		Expect(info.Hash()).To(Equal(salted("someone", "0123456789abcdef").Hash()))
+		Expect(info.Hash()).NotTo(Equal(salted("anyone", "0123456789abcdef").Hash()))
+		Expect(info.Hash()).NotTo(Equal(salted("someone", "fedcba9876543210").Hash()))
+	})
+
+	It("Should not allow short salts", func() {
+		info := NewUserInfo("id", "someone", "", "")
+		info.Salt = "short"
+
+		err := info.Valid()
+		Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+		Expect(err.(*ValidationError).Fields).To(ConsistOf(
+			FieldError{Field: "salt", Message: "must have at least 16 characters"},
+		))
+	})
+
+	It("Should be encodable", func() {
+		var info UserInfo
+		Expect(json.Unmarshal(NewUserInfo("id", "someone", "", "42").JSON(), &info)).NotTo(HaveOccurred())
+		Expect(info.ID).To(Equal("id"))
+		Expect(info.Name).To(Equal("someone"))
+		Expect(info.Badge).To(Equal("42"))
+	})
+})
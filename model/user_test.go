@@ -12,10 +12,9 @@ import (
 
 var _ = Describe("User", func() {
 	It("Should create a valid user", func() {
-		user := NewUser("id", "someone", 3)
+		user := NewUser("id", 3)
 		Expect(user.DocType).To(Equal(UserDocType))
 		Expect(user.ID).To(Equal("id"))
-		Expect(user.Name).To(Equal("someone"))
 		Expect(user.RemainingCoffee).To(Equal(3))
 
 		err := user.Valid()
@@ -24,44 +23,64 @@ var _ = Describe("User", func() {
 	})
 
 	It("Should have a valid ID", func() {
-		user := NewUser("", "someone", 3)
+		user := NewUser("", 3)
 		err := user.Valid()
 		Expect(err).To(HaveOccurred())
 	})
 
-	It("Should have a valid name", func() {
-		user := NewUser("id", "", 3)
+	It("Should have a valid docType", func() {
+		user := NewUser("id", 3)
+		user.DocType = ""
 		err := user.Valid()
 		Expect(err).To(HaveOccurred())
 	})
 
-	It("Should have a valid name", func() {
-		user := NewUser("id", "someone", 3)
-		user.DocType = ""
-		err := user.Valid()
-		Expect(err).To(HaveOccurred())
+	It("Should have the current schema version", func() {
+		user := NewUser("id", 3)
+		Expect(user.SchemaVersion).To(Equal(UserSchemaVersion))
+
+		user.SchemaVersion = 0
+		Expect(user.Valid()).To(HaveOccurred())
+	})
+
+	It("Should store it's info hash", func() {
+		user := NewUser("id", 3)
+		info := NewUserInfo("id", "someone", "someone@example.com", "42")
+
+		user.SetInfo(info)
+		Expect(user.InfoHash).To(Equal(info.Hash()))
 	})
 
 	It("Should drink a coffee", func() {
-		user := NewUser("id", "someone", 3)
+		user := NewUser("id", 3)
 		err := user.DrinkCoffee()
 		Expect(err).NotTo(HaveOccurred())
 	})
 
 	It("Should drink only if there's a coffee available", func() {
-		user := NewUser("id", "someone", 0)
+		user := NewUser("id", 0)
 
 		err := user.DrinkCoffee()
 		Expect(err).To(HaveOccurred())
 	})
 
+	It("Should top up credits up to the maximum", func() {
+		user := NewUser("id", 8)
+
+		Expect(user.TopUp(2, 10)).To(Succeed())
+		Expect(user.RemainingCoffee).To(Equal(10))
+
+		Expect(user.TopUp(1, 10)).NotTo(Succeed())
+		Expect(user.TopUp(0, 20)).NotTo(Succeed())
+		Expect(user.RemainingCoffee).To(Equal(10))
+	})
+
 	It("Should be encodable", func() {
-		jsonUser := NewUser("id", "someone", 3).JSON()
+		jsonUser := NewUser("id", 3).JSON()
 
 		var user model.User
 		Expect(json.Unmarshal(jsonUser, &user)).NotTo(HaveOccurred())
 		Expect(user.ID).To(Equal("id"))
-		Expect(user.Name).To(Equal("someone"))
 		Expect(user.RemainingCoffee).To(Equal(3))
 	})
 })
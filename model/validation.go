@@ -0,0 +1,63 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MaxRemainingCoffee is the maximum number of remaining coffees an user may
+// ever have, regardless of the chaincode configuration
+const MaxRemainingCoffee = 1000
+
+var (
+	idPattern      = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+	flavourPattern = regexp.MustCompile(`^[\p{L}][\p{L} -]{0,31}$`)
+	emailPattern   = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	badgePattern   = regexp.MustCompile(`^[A-Za-z0-9-]{1,16}$`)
+	// referencePattern matches payment references
+	referencePattern = regexp.MustCompile(`^[A-Za-z0-9_.:/-]{1,128}$`)
+)
+
+// FieldError describes why a field is invalid
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError aggregates the errors of all invalid fields of a document
+type ValidationError struct {
+	DocType string       `json:"docType"`
+	Fields  []FieldError `json:"fields"`
+}
+
+// Error lists all invalid fields
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = fmt.Sprintf("%s: %s", field.Field, field.Message)
+	}
+	return fmt.Sprintf("invalid %s: %s", e.DocType, strings.Join(messages, "; "))
+}
+
+// add appends an invalid field
+func (e *ValidationError) add(field, format string, args ...interface{}) {
+	e.Fields = append(e.Fields, FieldError{field, fmt.Sprintf(format, args...)})
+}
+
+// err returns the ValidationError if any field is invalid, or nil
+func (e *ValidationError) err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+// validID verifies if an ID has only letters, digits, '-' and '_'
+func (e *ValidationError) validID(field, id string) {
+	if id == "" {
+		e.add(field, "missing")
+	} else if !idPattern.MatchString(id) {
+		e.add(field, "must have up to 64 letters, digits, '-' or '_'")
+	}
+}
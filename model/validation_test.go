@@ -0,0 +1,99 @@
+package model_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cdtlab19/coffee-chaincode/model"
+)
+
+var _ = Describe("Validation", func() {
+	It("Should report all invalid fields", func() {
+		coffee := NewCoffee("invalid id", "")
+		coffee.DocType = ""
+
+		err := coffee.Valid()
+		Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+
+		fields := err.(*ValidationError).Fields
+		Expect(fields).To(HaveLen(3))
+		Expect(fields[0].Field).To(Equal("docType"))
+		Expect(fields[1].Field).To(Equal("id"))
+		Expect(fields[2].Field).To(Equal("flavour"))
+
+		Expect(err.Error()).To(HavePrefix("invalid coffee: "))
+		Expect(err.Error()).To(ContainSubstring("id: "))
+		Expect(err.Error()).To(ContainSubstring("flavour: "))
+	})
+
+	DescribeTable("Coffee",
+		func(coffee *Coffee, field string) {
+			err := coffee.Valid()
+			if field == "" {
+				Expect(err).NotTo(HaveOccurred())
+				return
+			}
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.(*ValidationError).Fields[0].Field).To(Equal(field))
+		},
+		Entry("valid", NewCoffee("tx-0000_1", "Café com leite"), ""),
+		Entry("ID with invalid characters", NewCoffee("id/1", "cappuccino"), "id"),
+		Entry("ID too long", NewCoffee(strings.Repeat("a", 65), "cappuccino"), "id"),
+		Entry("missing flavour", NewCoffee("id", ""), "flavour"),
+		Entry("flavour with digits", NewCoffee("id", "cappuccino2"), "flavour"),
+		Entry("flavour too long", NewCoffee("id", strings.Repeat("a", 33)), "flavour"),
+		Entry("invalid owner", &Coffee{
+			DocType:       CoffeeDocType,
+			SchemaVersion: CoffeeSchemaVersion,
+			ID:            "id",
+			Flavour:       "cappuccino",
+			Owner:         "some owner",
+		}, "owner"),
+		Entry("negative revision", &Coffee{
+			DocType:       CoffeeDocType,
+			SchemaVersion: CoffeeSchemaVersion,
+			ID:            "id",
+			Flavour:       "cappuccino",
+			Revisioned:    Revisioned{Revision: -1},
+		}, "revision"),
+	)
+
+	DescribeTable("User",
+		func(user *User, field string) {
+			err := user.Valid()
+			if field == "" {
+				Expect(err).NotTo(HaveOccurred())
+				return
+			}
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.(*ValidationError).Fields[0].Field).To(Equal(field))
+		},
+		Entry("valid", NewUser("id", MaxRemainingCoffee), ""),
+		Entry("invalid ID", NewUser("some id", 3), "id"),
+		Entry("negative credits", NewUser("id", -1), "remainingCoffee"),
+		Entry("too many credits", NewUser("id", MaxRemainingCoffee+1), "remainingCoffee"),
+	)
+
+	DescribeTable("UserInfo",
+		func(info *UserInfo, field string) {
+			err := info.Valid()
+			if field == "" {
+				Expect(err).NotTo(HaveOccurred())
+				return
+			}
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.(*ValidationError).Fields[0].Field).To(Equal(field))
+		},
+		Entry("valid", NewUserInfo("id", "Someone", "someone@example.com", "A-42"), ""),
+		Entry("blank name", NewUserInfo("id", "   ", "", ""), "name"),
+		Entry("name too long", NewUserInfo("id", strings.Repeat("a", 65), "", ""), "name"),
+		Entry("invalid email", NewUserInfo("id", "Someone", "someone", ""), "email"),
+		Entry("invalid badge", NewUserInfo("id", "Someone", "", "#42"), "badge"),
+	)
+})
@@ -1,81 +1,191 @@
 package store
 
 import (
-	"encoding/json"
+	"time"
 
 	"github.com/cdtlab19/coffee-chaincode/model"
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 )
 
-// CoffeeStore abstracts coffee CRUD methods
-type CoffeeStore struct {
-	stub   shim.ChaincodeStubInterface
-	logger *shim.ChaincodeLogger
+// Names of the coffee secondary indexes
+const (
+	CoffeeOwnerIndex   = "owner~id"
+	CoffeeFlavourIndex = "flavour~id"
+	CoffeeStateIndex   = "state~id"
+)
+
+// coffeeDefinition describes how coffee assets are stored
+var coffeeDefinition = Definition{
+	DocType: model.CoffeeDocType,
+	Key: func(asset Asset) []string {
+		return []string{asset.(*model.Coffee).ID}
+	},
+	Decode: func(data []byte) (Asset, error) {
+		return decodeCoffee(data)
+	},
+	Indexes: []Index{
+		{CoffeeOwnerIndex, func(asset Asset) []string {
+			if coffee := asset.(*model.Coffee); coffee.HasOwner() {
+				return []string{coffee.Owner}
+			}
+			return nil
+		}},
+		{CoffeeFlavourIndex, func(asset Asset) []string {
+			return []string{asset.(*model.Coffee).Flavour}
+		}},
+		{CoffeeStateIndex, func(asset Asset) []string {
+			return []string{asset.(*model.Coffee).State()}
+		}},
+	},
 }
 
-// newCoffeeKey returns the composite key for a coffee instance
-func (c *CoffeeStore) newCoffeeKey(id string) (key string) {
-	key, _ = c.stub.CreateCompositeKey(model.CoffeeDocType, []string{id})
-	return
+// CoffeeStore abstracts coffee CRUD methods
+type CoffeeStore struct {
+	repository *Repository
+	logger     Logger
 }
 
 // NewCoffeeStore creates a new coffee Store
-func NewCoffeeStore(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger) *CoffeeStore {
-	return &CoffeeStore{stub, logger}
+func NewCoffeeStore(stub shim.ChaincodeStubInterface, logger Logger) *CoffeeStore {
+	return &CoffeeStore{NewRepository(stub, logger, coffeeDefinition), logger}
 }
 
-// AllCoffee returns all existing coffee
-func (c *CoffeeStore) AllCoffee() ([]*model.Coffee, error) {
+// AllCoffee returns all existing coffee. Deleted coffees are only returned
+// if `includeDeleted` is set
+func (c *CoffeeStore) AllCoffee(includeDeleted bool) ([]*model.Coffee, error) {
 	c.logger.Debug("Entered AllCoffee")
 
-	iterator, err := c.stub.GetStateByPartialCompositeKey(model.CoffeeDocType, []string{})
+	coffees := []*model.Coffee{}
+	err := c.repository.Iterate(func(asset Asset) error {
+		coffee := asset.(*model.Coffee)
+		if coffee.IsDeleted() && !includeDeleted {
+			return nil
+		}
+		c.logger.Debugf("AllCoffee: element with ID '%s' found", coffee.ID)
+		coffees = append(coffees, coffee)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer iterator.Close()
 
-	c.logger.Debug("AllCoffee: starting iterator")
-	coffees := []*model.Coffee{}
-	for iterator.HasNext() {
-		k, err := iterator.Next()
-		if err != nil {
-			return nil, err
-		}
+	c.logger.Debug("Exiting AllCoffee")
+	return coffees, nil
+}
 
-		coffee := &model.Coffee{}
-		if err := json.Unmarshal(k.GetValue(), &coffee); err != nil {
-			return nil, err
-		}
+// query returns the coffees found in an index, skipping deleted ones unless
+// `includeDeleted` is set
+func (c *CoffeeStore) query(index string, includeDeleted bool, values ...string) ([]*model.Coffee, error) {
+	assets, err := c.repository.Query(index, values...)
+	if err != nil {
+		return nil, err
+	}
 
-		c.logger.Debugf("AllCoffee: element with ID '%s' found", coffee.ID)
+	coffees := []*model.Coffee{}
+	for _, asset := range assets {
+		coffee := asset.(*model.Coffee)
+		if coffee.IsDeleted() && !includeDeleted {
+			continue
+		}
 		coffees = append(coffees, coffee)
 	}
-
-	c.logger.Debug("Exiting AllCoffee")
 	return coffees, nil
 }
 
-// GetCoffee returns a coffee by it's id
-func (c *CoffeeStore) GetCoffee(coffeeID string) (coffee *model.Coffee, err error) {
-	c.logger.Debug("GetCoffee: searching for coffee %s", coffeeID)
+// CoffeeByOwner returns the existing coffees owned by an user
+func (c *CoffeeStore) CoffeeByOwner(owner string) ([]*model.Coffee, error) {
+	return c.query(CoffeeOwnerIndex, false, owner)
+}
 
-	data, err := c.stub.GetState(c.newCoffeeKey(coffeeID))
+// CoffeeByFlavour returns the existing coffees of a flavour
+func (c *CoffeeStore) CoffeeByFlavour(flavour string) ([]*model.Coffee, error) {
+	return c.query(CoffeeFlavourIndex, false, flavour)
+}
+
+// CoffeeByState returns the coffees in a state, including deleted coffees
+// when the state is model.CoffeeDeleted
+func (c *CoffeeStore) CoffeeByState(state string) ([]*model.Coffee, error) {
+	return c.query(CoffeeStateIndex, true, state)
+}
+
+// VerifyCoffeeIndexes verifies the coffee indexes, rebuilding missing and
+// stale entries if `repair` is set
+func (c *CoffeeStore) VerifyCoffeeIndexes(repair bool) (*IndexReport, error) {
+	return c.repository.VerifyIndexes(repair)
+}
+
+// GetCoffee returns a coffee by it's id
+func (c *CoffeeStore) GetCoffee(coffeeID string) (*model.Coffee, error) {
+	asset, err := c.repository.Get(coffeeID)
 	if err != nil {
 		return nil, err
 	}
+	return asset.(*model.Coffee), nil
+}
+
+// CreateCoffee validates and stores a new coffee asset, failing if it
+// already exists
+func (c *CoffeeStore) CreateCoffee(coffee *model.Coffee) error {
+	return c.repository.Create(coffee)
+}
 
-	err = json.Unmarshal(data, &coffee)
-	return
+// UpdateCoffee validates and stores an existing coffee asset, failing if it
+// doesn't exist
+func (c *CoffeeStore) UpdateCoffee(coffee *model.Coffee) error {
+	return c.repository.Update(coffee)
 }
 
-// SetCoffee sets a coffee asset by it's id
+// SetCoffee validates and sets a coffee asset by it's id, whether it exists
+// or not
 func (c *CoffeeStore) SetCoffee(coffee *model.Coffee) error {
-	c.logger.Debug("SetCoffee: setting coffee %s", coffee.ID)
-	return c.stub.PutState(c.newCoffeeKey(coffee.ID), coffee.JSON())
+	return c.repository.Put(coffee)
+}
+
+// DeleteCoffee marks an existing coffee asset as deleted with a tombstone
+func (c *CoffeeStore) DeleteCoffee(coffeeID string, tombstone *model.Tombstone) error {
+	return c.repository.SoftDelete(tombstone, coffeeID)
+}
+
+// RestoreCoffee removes the tombstone of a deleted coffee asset
+func (c *CoffeeStore) RestoreCoffee(coffeeID string) error {
+	return c.repository.Restore(coffeeID)
 }
 
-// DeleteCoffee deletes a coffee asset by it's id
-func (c *CoffeeStore) DeleteCoffee(coffeeID string) error {
-	c.logger.Debug("DeleteCoffee: deleting coffee %s", coffeeID)
-	return c.stub.DelState(c.newCoffeeKey(coffeeID))
+// PurgeCoffee erases a coffee asset deleted for longer than `retention`
+func (c *CoffeeStore) PurgeCoffee(coffeeID string, retention time.Duration, now time.Time) error {
+	return c.repository.Purge(retention, now, coffeeID)
+}
+
+// PendingCoffeeMigrations lists a page of coffee assets stored with older
+// schema versions, starting at `bookmark`
+func (c *CoffeeStore) PendingCoffeeMigrations(pageSize int, bookmark string) (*ScanResult, error) {
+	c.logger.Debugf("PendingCoffeeMigrations: scanning %d coffees at '%s'", pageSize, bookmark)
+
+	return c.repository.Scan(pageSize, bookmark, func(id string, data []byte) (bool, error) {
+		return outdated(data, model.CoffeeSchemaVersion)
+	})
+}
+
+// MigrateCoffee rewrites the listed coffee assets which are stored with older
+// schema versions. Coffees which would be invalid once upgraded are skipped
+// and listed as invalid
+func (c *CoffeeStore) MigrateCoffee(ids []string) (*RewriteResult, error) {
+	c.logger.Debugf("MigrateCoffee: migrating coffees %v", ids)
+
+	return c.repository.Rewrite(ids, func(id string, data []byte) (bool, error) {
+		if old, err := outdated(data, model.CoffeeSchemaVersion); err != nil || !old {
+			return false, err
+		}
+
+		coffee, err := decodeCoffee(data)
+		if err != nil {
+			return false, err
+		}
+
+		if err := c.repository.validate(coffee); err != nil {
+			return false, &invalidError{err}
+		}
+
+		return true, c.SetCoffee(coffee)
+	})
 }
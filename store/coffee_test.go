@@ -0,0 +1,53 @@
+package store_test
+
+import (
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+
+	"github.com/cdtlab19/coffee-chaincode/model"
+	. "github.com/cdtlab19/coffee-chaincode/store"
+)
+
+type coffeeAdapter struct{ *CoffeeStore }
+
+func (a coffeeAdapter) Get(id string) (interface{}, error) { return a.GetCoffee(id) }
+func (a coffeeAdapter) Create(asset interface{}) error     { return a.CreateCoffee(asset.(*model.Coffee)) }
+func (a coffeeAdapter) Update(asset interface{}) error     { return a.UpdateCoffee(asset.(*model.Coffee)) }
+func (a coffeeAdapter) Put(asset interface{}) error        { return a.SetCoffee(asset.(*model.Coffee)) }
+func (a coffeeAdapter) Delete(id string) error             { return a.DeleteCoffee(id, testTombstone()) }
+func (a coffeeAdapter) Restore(id string) error            { return a.RestoreCoffee(id) }
+
+func (a coffeeAdapter) Purge(id string, retention time.Duration, now time.Time) error {
+	return a.PurgeCoffee(id, retention, now)
+}
+
+func (a coffeeAdapter) List() ([]interface{}, error) {
+	return a.list(false)
+}
+
+func (a coffeeAdapter) ListAll() ([]interface{}, error) {
+	return a.list(true)
+}
+
+func (a coffeeAdapter) list(includeDeleted bool) ([]interface{}, error) {
+	coffees, err := a.AllCoffee(includeDeleted)
+	assets := []interface{}{}
+	for _, coffee := range coffees {
+		assets = append(assets, coffee)
+	}
+	return assets, err
+}
+
+var _ = describeConformance("CoffeeStore", conformance{
+	New: func(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger) storeAdapter {
+		return coffeeAdapter{NewCoffeeStore(stub, logger)}
+	},
+	Valid: func(id string) interface{} {
+		return model.NewCoffee(id, "cappuccino")
+	},
+	Invalid: func(id string) interface{} {
+		return model.NewCoffee(id, "")
+	},
+	Deletable: true,
+})
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"encoding/json"
+
+	"github.com/cdtlab19/coffee-chaincode/model"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// configDefinition describes how the chaincode configuration is stored. It
+// has a single instance, so it's key has no attributes
+var configDefinition = Definition{
+	DocType: model.ConfigDocType,
+	Key: func(asset Asset) []string {
+		return []string{}
+	},
+	Decode: func(data []byte) (Asset, error) {
+		config := &model.Config{}
+		if err := json.Unmarshal(data, config); err != nil {
+			return nil, err
+		}
+		return migrateConfig(config), nil
+	},
+}
+
+// ConfigStore abstracts the chaincode configuration persistence
+type ConfigStore struct {
+	repository *Repository
+	logger     Logger
+}
+
+// NewConfigStore creates a new config Store
+func NewConfigStore(stub shim.ChaincodeStubInterface, logger Logger) *ConfigStore {
+	return &ConfigStore{NewRepository(stub, logger, configDefinition), logger}
+}
+
+// HasConfig verifies if a configuration was already stored
+func (c *ConfigStore) HasConfig() (bool, error) {
+	return c.repository.Exists()
+}
+
+// GetConfig returns the stored configuration, migrated to the current
+// version, or the default configuration if none was stored yet
+func (c *ConfigStore) GetConfig() (*model.Config, error) {
+	c.logger.Debug("GetConfig: retrieving config")
+
+	exists, err := c.repository.Exists()
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return model.NewConfig(), nil
+	}
+
+	asset, err := c.repository.Get()
+	if err != nil {
+		return nil, err
+	}
+	return asset.(*model.Config), nil
+}
+
+// SetConfig validates and stores the configuration
+func (c *ConfigStore) SetConfig(config *model.Config) error {
+	return c.repository.Put(config)
+}
+
+// migrateConfig upgrades a configuration stored by an older version to the
+// current format, filling new fields with their default values
+func migrateConfig(config *model.Config) *model.Config {
+	defaults := model.NewConfig()
+
+	// version 0 had no version, quota nor feature fields
+	if config.Version == 0 {
+		config.DocType = model.ConfigDocType
+		if config.MaxCredits == 0 {
+			config.MaxCredits = defaults.MaxCredits
+		}
+		if config.Features == nil {
+			config.Features = defaults.Features
+		}
+		if config.Admins == nil {
+			config.Admins = defaults.Admins
+		}
+		config.Version = 1
+	}
+
+	// version 1 had no retention of deleted assets
+	if config.Version == 1 {
+		config.RetentionDays = defaults.RetentionDays
+		config.Version = 2
+	}
+
+	// version 2 didn't remember client request IDs
+	if config.Version == 2 {
+		config.RequestExpiryHours = defaults.RequestExpiryHours
+		config.Version = 3
+	}
+
+	// version 3 always enabled batches
+	if config.Version == 3 {
+		if config.Features == nil {
+			config.Features = map[string]bool{}
+		}
+		if _, ok := config.Features[model.FeatureBatch]; !ok {
+			config.Features[model.FeatureBatch] = true
+		}
+		config.Version = 4
+	}
+
+	// version 4 had no payment processors
+	if config.Version == 4 {
+		if config.PaymentProcessors == nil {
+			config.PaymentProcessors = defaults.PaymentProcessors
+		}
+		config.Version = 5
+	}
+
+	return config
+}
@@ -0,0 +1,253 @@
+package store_test
+
+import (
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cdtlab19/coffee-chaincode/mockstub"
+	"github.com/cdtlab19/coffee-chaincode/model"
+	. "github.com/cdtlab19/coffee-chaincode/store"
+)
+
+// deletedAt is the time assets are deleted at by the conformance suite
+var deletedAt = time.Date(2019, 4, 1, 12, 0, 0, 0, time.UTC)
+
+// testTombstone is the tombstone of assets deleted by the conformance suite
+func testTombstone() *model.Tombstone {
+	return model.NewTombstone(deletedAt, "Org1MSP/admin", "test")
+}
+
+// conformance adapts an asset store to the operations verified by the
+// conformance suite
+type conformance struct {
+	// New creates a store over a stub
+	New func(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger) storeAdapter
+	// Valid and Invalid create assets with the given ID
+	Valid   func(id string) interface{}
+	Invalid func(id string) interface{}
+	// Deletable is set if the store soft deletes assets, implementing
+	// deletableAdapter
+	Deletable bool
+}
+
+// storeAdapter exposes an asset store through a common interface
+type storeAdapter interface {
+	Get(id string) (interface{}, error)
+	Create(asset interface{}) error
+	Update(asset interface{}) error
+	Put(asset interface{}) error
+	Delete(id string) error
+	List() ([]interface{}, error)
+}
+
+// deletableAdapter exposes the operations of stores which soft delete assets
+type deletableAdapter interface {
+	storeAdapter
+	ListAll() ([]interface{}, error)
+	Restore(id string) error
+	Purge(id string, retention time.Duration, now time.Time) error
+}
+
+// describeConformance verifies the behaviour every asset store must have
+func describeConformance(name string, c conformance) bool {
+	return Describe(name+" conformance", func() {
+		var mock *mockstub.Stub
+		var st storeAdapter
+
+		// at returns a valid asset at a revision, if it's Revisioned
+		at := func(id string, revision int) interface{} {
+			asset := c.Valid(id)
+			if revisioned, ok := asset.(Revisioned); ok {
+				revisioned.SetRevision(revision)
+			}
+			return asset
+		}
+
+		BeforeEach(func() {
+			mock = mockstub.NewStub("store", nil)
+			st = c.New(mock, shim.NewLogger("store-test"))
+			mock.MockTransactionStart("0000")
+		})
+
+		AfterEach(func() {
+			mock.MockTransactionEnd("0000")
+		})
+
+		It("Should store and retrieve assets", func() {
+			Expect(st.Put(c.Valid("0000"))).To(Succeed())
+
+			asset, err := st.Get("0000")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(asset).To(Equal(at("0000", 1)))
+		})
+
+		It("Should return an error for missing assets", func() {
+			_, err := st.Get("0000")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Should not store invalid assets", func() {
+			Expect(st.Put(c.Invalid("0000"))).NotTo(Succeed())
+
+			_, err := st.Get("0000")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Should create new assets only", func() {
+			Expect(st.Create(c.Valid("0000"))).To(Succeed())
+
+			err := st.Create(c.Valid("0000"))
+			Expect(IsAlreadyExists(err)).To(BeTrue(), "%v", err)
+		})
+
+		It("Should update existing assets only", func() {
+			err := st.Update(c.Valid("0000"))
+			Expect(IsNotFound(err)).To(BeTrue(), "%v", err)
+
+			_, err = st.Get("0000")
+			Expect(IsNotFound(err)).To(BeTrue(), "%v", err)
+
+			Expect(st.Put(c.Valid("0000"))).To(Succeed())
+			Expect(st.Update(at("0000", 1))).To(Succeed())
+		})
+
+		It("Should count the revisions of assets", func() {
+			if _, ok := c.Valid("0000").(Revisioned); !ok {
+				Skip("assets aren't revisioned")
+			}
+
+			Expect(st.Create(c.Valid("0000"))).To(Succeed())
+			Expect(st.Update(at("0000", 1))).To(Succeed())
+
+			asset, err := st.Get("0000")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(asset).To(Equal(at("0000", 2)))
+		})
+
+		It("Should not update assets changed since the expected revision", func() {
+			if _, ok := c.Valid("0000").(Revisioned); !ok {
+				Skip("assets aren't revisioned")
+			}
+
+			Expect(st.Create(c.Valid("0000"))).To(Succeed())
+			Expect(st.Update(at("0000", 1))).To(Succeed())
+
+			err := st.Update(at("0000", 1))
+			Expect(IsConflict(err)).To(BeTrue(), "%v", err)
+			Expect(err).To(MatchError(ContainSubstring("expected revision 1, but it's at revision 2")))
+		})
+
+		It("Should not delete missing assets", func() {
+			err := st.Delete("0000")
+			Expect(IsNotFound(err)).To(BeTrue(), "%v", err)
+		})
+
+		It("Should delete assets", func() {
+			Expect(st.Put(c.Valid("0000"))).To(Succeed())
+			Expect(st.Delete("0000")).To(Succeed())
+
+			_, err := st.Get("0000")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Should list all assets in key order", func() {
+			Expect(st.Put(c.Valid("0002"))).To(Succeed())
+			Expect(st.Put(c.Valid("0000"))).To(Succeed())
+			Expect(st.Put(c.Valid("0001"))).To(Succeed())
+			mock.MockTransactionStart("0001")
+
+			assets, err := st.List()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(assets).To(Equal([]interface{}{
+				at("0000", 1),
+				at("0001", 1),
+				at("0002", 1),
+			}))
+		})
+
+		It("Should list no assets if empty", func() {
+			assets, err := st.List()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(assets).To(BeEmpty())
+		})
+
+		if !c.Deletable {
+			return
+		}
+
+		Context("soft deletion", func() {
+			var deletable deletableAdapter
+
+			BeforeEach(func() {
+				deletable = st.(deletableAdapter)
+				Expect(st.Put(c.Valid("0000"))).To(Succeed())
+				Expect(st.Put(c.Valid("0001"))).To(Succeed())
+				Expect(st.Delete("0000")).To(Succeed())
+			})
+
+			It("Should hide deleted assets", func() {
+				_, err := st.Get("0000")
+				Expect(IsNotFound(err)).To(BeTrue(), "%v", err)
+
+				assets, err := st.List()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(assets).To(Equal([]interface{}{at("0001", 1)}))
+			})
+
+			It("Should list deleted assets with their tombstones", func() {
+				assets, err := deletable.ListAll()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(assets).To(HaveLen(2))
+				Expect(assets[0].(Deletable).Tombstone()).To(Equal(testTombstone()))
+				Expect(assets[1].(Deletable).IsDeleted()).To(BeFalse())
+			})
+
+			It("Should not update, delete nor recreate deleted assets", func() {
+				err := st.Update(c.Valid("0000"))
+				Expect(IsNotFound(err)).To(BeTrue(), "%v", err)
+
+				err = st.Delete("0000")
+				Expect(IsNotFound(err)).To(BeTrue(), "%v", err)
+
+				err = st.Create(c.Valid("0000"))
+				Expect(IsAlreadyExists(err)).To(BeTrue(), "%v", err)
+			})
+
+			It("Should restore deleted assets", func() {
+				Expect(deletable.Restore("0000")).To(Succeed())
+
+				asset, err := st.Get("0000")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(asset).To(Equal(at("0000", 3)))
+			})
+
+			It("Should only restore deleted assets", func() {
+				Expect(deletable.Restore("0001")).NotTo(Succeed())
+
+				err := deletable.Restore("0002")
+				Expect(IsNotFound(err)).To(BeTrue(), "%v", err)
+			})
+
+			It("Should purge assets after the retention period", func() {
+				retention := 24 * time.Hour
+				Expect(deletable.Purge("0000", retention, deletedAt.Add(time.Hour))).
+					To(MatchError(ContainSubstring("can't be purged before")))
+
+				Expect(deletable.Purge("0000", retention, deletedAt.Add(retention))).To(Succeed())
+
+				assets, err := deletable.ListAll()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(assets).To(Equal([]interface{}{at("0001", 1)}))
+				Expect(st.Create(c.Valid("0000"))).To(Succeed())
+			})
+
+			It("Should only purge deleted assets", func() {
+				Expect(deletable.Purge("0001", 0, deletedAt)).
+					To(MatchError(ContainSubstring("must be deleted")))
+			})
+		})
+	})
+}
@@ -0,0 +1,73 @@
+package store
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// NotFoundError is returned when an asset doesn't exist
+type NotFoundError struct {
+	DocType string
+	ID      string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s '%s' not found", e.DocType, e.ID)
+}
+
+// Status returns the response status of the error
+func (e *NotFoundError) Status() int32 {
+	return http.StatusNotFound
+}
+
+// AlreadyExistsError is returned when creating an asset which already exists
+type AlreadyExistsError struct {
+	DocType string
+	ID      string
+}
+
+func (e *AlreadyExistsError) Error() string {
+	return fmt.Sprintf("%s '%s' already exists", e.DocType, e.ID)
+}
+
+// Status returns the response status of the error
+func (e *AlreadyExistsError) Status() int32 {
+	return http.StatusConflict
+}
+
+// ConflictError is returned when updating an asset which was changed since
+// the revision expected by the client
+type ConflictError struct {
+	DocType  string
+	ID       string
+	Expected int
+	Actual   int
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s '%s' was changed: expected revision %d, but it's at revision %d",
+		e.DocType, e.ID, e.Expected, e.Actual)
+}
+
+// Status returns the response status of the error
+func (e *ConflictError) Status() int32 {
+	return http.StatusConflict
+}
+
+// IsNotFound verifies if an error is a NotFoundError
+func IsNotFound(err error) bool {
+	_, ok := err.(*NotFoundError)
+	return ok
+}
+
+// IsAlreadyExists verifies if an error is an AlreadyExistsError
+func IsAlreadyExists(err error) bool {
+	_, ok := err.(*AlreadyExistsError)
+	return ok
+}
+
+// IsConflict verifies if an error is a ConflictError
+func IsConflict(err error) bool {
+	_, ok := err.(*ConflictError)
+	return ok
+}
@@ -0,0 +1,238 @@
+package fake
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cdtlab19/coffee-chaincode/model"
+	"github.com/cdtlab19/coffee-chaincode/store"
+)
+
+// CoffeeStore is an in-memory store.CoffeeRepository
+type CoffeeStore struct {
+	Failures
+	Coffees map[string]*model.Coffee
+}
+
+var _ store.CoffeeRepository = &CoffeeStore{}
+
+// NewCoffeeStore creates an empty CoffeeStore
+func NewCoffeeStore() *CoffeeStore {
+	return &CoffeeStore{
+		Failures: Failures{},
+		Coffees:  map[string]*model.Coffee{},
+	}
+}
+
+// AllCoffee returns all coffees ordered by ID, skipping deleted ones unless
+// `includeDeleted` is set
+func (c *CoffeeStore) AllCoffee(includeDeleted bool) ([]*model.Coffee, error) {
+	if err := c.failure("AllCoffee"); err != nil {
+		return nil, err
+	}
+
+	return c.filter(func(coffee *model.Coffee) bool {
+		return includeDeleted || !coffee.IsDeleted()
+	}), nil
+}
+
+// filter returns copies of the coffees accepted by `fn`, ordered by ID
+func (c *CoffeeStore) filter(fn func(coffee *model.Coffee) bool) []*model.Coffee {
+	ids := []string{}
+	for id := range c.Coffees {
+		ids = append(ids, id)
+	}
+
+	coffees := []*model.Coffee{}
+	for _, id := range sortedKeys(ids) {
+		if coffee := c.Coffees[id]; fn(coffee) {
+			coffees = append(coffees, cloneCoffee(coffee))
+		}
+	}
+	return coffees
+}
+
+// CoffeeByOwner returns the existing coffees owned by an user
+func (c *CoffeeStore) CoffeeByOwner(owner string) ([]*model.Coffee, error) {
+	if err := c.failure("CoffeeByOwner"); err != nil {
+		return nil, err
+	}
+
+	return c.filter(func(coffee *model.Coffee) bool {
+		return !coffee.IsDeleted() && coffee.HasOwner() && coffee.Owner == owner
+	}), nil
+}
+
+// CoffeeByFlavour returns the existing coffees of a flavour
+func (c *CoffeeStore) CoffeeByFlavour(flavour string) ([]*model.Coffee, error) {
+	if err := c.failure("CoffeeByFlavour"); err != nil {
+		return nil, err
+	}
+
+	return c.filter(func(coffee *model.Coffee) bool {
+		return !coffee.IsDeleted() && coffee.Flavour == flavour
+	}), nil
+}
+
+// CoffeeByState returns the coffees in a state
+func (c *CoffeeStore) CoffeeByState(state string) ([]*model.Coffee, error) {
+	if err := c.failure("CoffeeByState"); err != nil {
+		return nil, err
+	}
+
+	return c.filter(func(coffee *model.Coffee) bool {
+		return coffee.State() == state
+	}), nil
+}
+
+// GetCoffee returns a copy of a coffee by it's ID
+func (c *CoffeeStore) GetCoffee(coffeeID string) (*model.Coffee, error) {
+	if err := c.failure("GetCoffee"); err != nil {
+		return nil, err
+	}
+
+	coffee, ok := c.Coffees[coffeeID]
+	if !ok || coffee.IsDeleted() {
+		return nil, notFound(model.CoffeeDocType, coffeeID)
+	}
+
+	return cloneCoffee(coffee), nil
+}
+
+// CreateCoffee validates and stores a copy of a new coffee
+func (c *CoffeeStore) CreateCoffee(coffee *model.Coffee) error {
+	if err := c.failure("CreateCoffee"); err != nil {
+		return err
+	}
+
+	if _, ok := c.Coffees[coffee.ID]; ok {
+		return alreadyExists(model.CoffeeDocType, coffee.ID)
+	}
+	return c.SetCoffee(coffee)
+}
+
+// UpdateCoffee validates and stores a copy of an existing coffee
+func (c *CoffeeStore) UpdateCoffee(coffee *model.Coffee) error {
+	if err := c.failure("UpdateCoffee"); err != nil {
+		return err
+	}
+
+	stored, ok := c.Coffees[coffee.ID]
+	if !ok || stored.IsDeleted() {
+		return notFound(model.CoffeeDocType, coffee.ID)
+	}
+	if coffee.Revision != stored.Revision {
+		return conflict(model.CoffeeDocType, coffee.ID, coffee.Revision, stored.Revision)
+	}
+	return c.SetCoffee(coffee)
+}
+
+// SetCoffee validates and stores a copy of a coffee
+func (c *CoffeeStore) SetCoffee(coffee *model.Coffee) error {
+	if err := c.failure("SetCoffee"); err != nil {
+		return err
+	}
+
+	if err := coffee.Valid(); err != nil {
+		return err
+	}
+
+	coffee.Revision = 1
+	if stored, ok := c.Coffees[coffee.ID]; ok {
+		coffee.Revision = stored.Revision + 1
+	}
+
+	c.Coffees[coffee.ID] = cloneCoffee(coffee)
+	return nil
+}
+
+// DeleteCoffee marks a coffee as deleted with a tombstone
+func (c *CoffeeStore) DeleteCoffee(coffeeID string, tombstone *model.Tombstone) error {
+	if err := c.failure("DeleteCoffee"); err != nil {
+		return err
+	}
+
+	coffee, ok := c.Coffees[coffeeID]
+	if !ok || coffee.IsDeleted() {
+		return notFound(model.CoffeeDocType, coffeeID)
+	}
+
+	coffee = cloneCoffee(coffee)
+	if err := coffee.Delete(tombstone); err != nil {
+		return err
+	}
+	return c.SetCoffee(coffee)
+}
+
+// RestoreCoffee removes the tombstone of a deleted coffee
+func (c *CoffeeStore) RestoreCoffee(coffeeID string) error {
+	if err := c.failure("RestoreCoffee"); err != nil {
+		return err
+	}
+
+	coffee, ok := c.Coffees[coffeeID]
+	if !ok {
+		return notFound(model.CoffeeDocType, coffeeID)
+	}
+
+	coffee = cloneCoffee(coffee)
+	if err := coffee.Restore(); err != nil {
+		return fmt.Errorf("%s '%s' %s", model.CoffeeDocType, coffeeID, err.Error())
+	}
+	return c.SetCoffee(coffee)
+}
+
+// PurgeCoffee removes a coffee deleted for longer than `retention`
+func (c *CoffeeStore) PurgeCoffee(coffeeID string, retention time.Duration, now time.Time) error {
+	if err := c.failure("PurgeCoffee"); err != nil {
+		return err
+	}
+
+	coffee, ok := c.Coffees[coffeeID]
+	if !ok {
+		return notFound(model.CoffeeDocType, coffeeID)
+	}
+
+	if err := purgeable(model.CoffeeDocType, coffeeID, coffee.Tombstone(), retention, now); err != nil {
+		return err
+	}
+
+	delete(c.Coffees, coffeeID)
+	return nil
+}
+
+// PendingCoffeeMigrations lists nothing, since in-memory coffees are always
+// up to date
+func (c *CoffeeStore) PendingCoffeeMigrations(pageSize int, bookmark string) (*store.ScanResult, error) {
+	if err := c.failure("PendingCoffeeMigrations"); err != nil {
+		return nil, err
+	}
+
+	return &store.ScanResult{Scanned: len(c.Coffees), IDs: []string{}, Done: true}, nil
+}
+
+// MigrateCoffee does nothing, since in-memory coffees are always up to date
+func (c *CoffeeStore) MigrateCoffee(ids []string) (*store.RewriteResult, error) {
+	if err := c.failure("MigrateCoffee"); err != nil {
+		return nil, err
+	}
+
+	return &store.RewriteResult{Unchanged: len(ids)}, nil
+}
+
+// VerifyCoffeeIndexes reports consistent indexes, since in-memory coffees
+// aren't indexed
+func (c *CoffeeStore) VerifyCoffeeIndexes(repair bool) (*store.IndexReport, error) {
+	if err := c.failure("VerifyCoffeeIndexes"); err != nil {
+		return nil, err
+	}
+
+	return &store.IndexReport{Checked: len(c.Coffees), Missing: []string{}, Stale: []string{}}, nil
+}
+
+// cloneCoffee copies a coffee and it's tombstone
+func cloneCoffee(coffee *model.Coffee) *model.Coffee {
+	clone := *coffee
+	clone.Deleted = cloneTombstone(coffee.Deleted)
+	return &clone
+}
@@ -0,0 +1,74 @@
+// Package fake provides in-memory implementations of the store repositories,
+// with failure injection, for unit testing the chaincodes without a ledger
+package fake
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/cdtlab19/coffee-chaincode/model"
+	"github.com/cdtlab19/coffee-chaincode/store"
+)
+
+// Failures holds the errors injected in repository methods, by method name
+type Failures map[string]error
+
+// Fail makes every call to `method` return `err`. A nil `err` removes the
+// injected failure
+func (f Failures) Fail(method string, err error) {
+	if err == nil {
+		delete(f, method)
+		return
+	}
+	f[method] = err
+}
+
+// failure returns the error injected in `method`, if any
+func (f Failures) failure(method string) error {
+	return f[method]
+}
+
+// notFound returns the error of a missing asset
+func notFound(docType, id string) error {
+	return &store.NotFoundError{DocType: docType, ID: id}
+}
+
+// alreadyExists returns the error of an asset created twice
+func alreadyExists(docType, id string) error {
+	return &store.AlreadyExistsError{DocType: docType, ID: id}
+}
+
+// conflict returns the error of an asset updated at a stale revision
+func conflict(docType, id string, expected, actual int) error {
+	return &store.ConflictError{DocType: docType, ID: id, Expected: expected, Actual: actual}
+}
+
+// purgeable verifies if an asset with `tombstone` may be purged, like
+// store.Repository.Purge
+func purgeable(docType, id string, tombstone *model.Tombstone, retention time.Duration, now time.Time) error {
+	if tombstone == nil {
+		return fmt.Errorf("%s '%s' must be deleted before it's purged", docType, id)
+	}
+
+	purgeAt := tombstone.DeletedAt.Add(retention)
+	if now.Before(purgeAt) {
+		return fmt.Errorf("%s '%s' can't be purged before %s", docType, id, purgeAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// cloneTombstone copies a tombstone, if set
+func cloneTombstone(tombstone *model.Tombstone) *model.Tombstone {
+	if tombstone == nil {
+		return nil
+	}
+	clone := *tombstone
+	return &clone
+}
+
+// sortedKeys returns a map's keys in ascending order
+func sortedKeys(keys []string) []string {
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,83 @@
+package fake_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cdtlab19/coffee-chaincode/model"
+	"github.com/cdtlab19/coffee-chaincode/store"
+	. "github.com/cdtlab19/coffee-chaincode/store/fake"
+)
+
+var _ = Describe("Fake", func() {
+	It("Should inject and remove failures", func() {
+		st := NewCoffeeStore()
+
+		st.Fail("GetCoffee", errors.New("failure"))
+		_, err := st.GetCoffee("0000")
+		Expect(err).To(MatchError("failure"))
+
+		st.Fail("GetCoffee", nil)
+		_, err = st.GetCoffee("0000")
+		Expect(err).To(MatchError(ContainSubstring("not found")))
+	})
+
+	It("Should store copies of the assets", func() {
+		st := NewUserStore()
+		user := model.NewUser("0000", 3)
+		Expect(st.SetUser(user)).To(Succeed())
+
+		user.RemainingCoffee = 0
+		stored, err := st.GetUser("0000")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stored.RemainingCoffee).To(Equal(3))
+
+		stored.RemainingCoffee = 1
+		Expect(st.Users["0000"].RemainingCoffee).To(Equal(3))
+	})
+
+	It("Should validate assets like the ledger stores", func() {
+		Expect(NewCoffeeStore().SetCoffee(model.NewCoffee("0000", ""))).NotTo(Succeed())
+		Expect(NewUserStore().SetUserInfo(model.NewUserInfo("0000", "", "", ""))).NotTo(Succeed())
+		Expect(NewUserStore().SetPaymentKey(model.NewPaymentKey([]byte("short")))).NotTo(Succeed())
+
+		key := model.NewPaymentKey([]byte("0123456789abcdef"))
+		Expect(NewUserStore().CreatePayment(model.NewPayment(key, "", "0000", 1, time.Now()))).NotTo(Succeed())
+	})
+
+	It("Should list assets ordered by ID", func() {
+		st := NewCoffeeStore()
+		Expect(st.SetCoffee(model.NewCoffee("0001", "chocolate"))).To(Succeed())
+		Expect(st.SetCoffee(model.NewCoffee("0000", "cappuccino"))).To(Succeed())
+
+		coffees, err := st.AllCoffee(false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(coffees).To(HaveLen(2))
+		Expect(coffees[0].ID).To(Equal("0000"))
+		Expect(coffees[1].ID).To(Equal("0001"))
+	})
+
+	It("Should report missing and duplicated assets like the ledger stores", func() {
+		st := NewUserStore()
+		Expect(store.IsNotFound(st.UpdateUser(model.NewUser("0000", 3)))).To(BeTrue())
+		Expect(store.IsNotFound(st.DeleteUser("0000", model.NewTombstone(time.Now(), "Org1MSP/admin", "")))).To(BeTrue())
+
+		Expect(st.CreateUser(model.NewUser("0000", 3))).To(Succeed())
+		Expect(store.IsAlreadyExists(st.CreateUser(model.NewUser("0000", 3)))).To(BeTrue())
+	})
+
+	It("Should count revisions like the ledger stores", func() {
+		st := NewCoffeeStore()
+		coffee := model.NewCoffee("0000", "cappuccino")
+		Expect(st.CreateCoffee(coffee)).To(Succeed())
+		Expect(coffee.Revision).To(Equal(1))
+
+		stale := *coffee
+		Expect(st.UpdateCoffee(coffee)).To(Succeed())
+		Expect(coffee.Revision).To(Equal(2))
+		Expect(store.IsConflict(st.UpdateCoffee(&stale))).To(BeTrue())
+	})
+})
@@ -0,0 +1,328 @@
+package fake
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cdtlab19/coffee-chaincode/model"
+	"github.com/cdtlab19/coffee-chaincode/store"
+)
+
+// UserStore is an in-memory store.UserRepository
+type UserStore struct {
+	Failures
+	Users        map[string]*model.User
+	Infos        map[string]*model.UserInfo
+	Payments     map[string]*model.Payment
+	PaymentKey   *model.PaymentKey
+	Endorsements map[string][]string
+}
+
+var _ store.UserRepository = &UserStore{}
+
+// NewUserStore creates an empty UserStore
+func NewUserStore() *UserStore {
+	return &UserStore{
+		Failures:     Failures{},
+		Users:        map[string]*model.User{},
+		Infos:        map[string]*model.UserInfo{},
+		Payments:     map[string]*model.Payment{},
+		Endorsements: map[string][]string{},
+	}
+}
+
+// AllUser returns all users ordered by ID, skipping deleted ones unless
+// `includeDeleted` is set
+func (u *UserStore) AllUser(includeDeleted bool) ([]*model.User, error) {
+	if err := u.failure("AllUser"); err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for id := range u.Users {
+		ids = append(ids, id)
+	}
+
+	users := []*model.User{}
+	for _, id := range sortedKeys(ids) {
+		user := cloneUser(u.Users[id])
+		if user.IsDeleted() && !includeDeleted {
+			continue
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+// GetUser returns a copy of an user by it's ID
+func (u *UserStore) GetUser(userID string) (*model.User, error) {
+	if err := u.failure("GetUser"); err != nil {
+		return nil, err
+	}
+
+	user, ok := u.Users[userID]
+	if !ok || user.IsDeleted() {
+		return nil, notFound(model.UserDocType, userID)
+	}
+
+	return cloneUser(user), nil
+}
+
+// CreateUser validates and stores a copy of a new user
+func (u *UserStore) CreateUser(user *model.User) error {
+	if err := u.failure("CreateUser"); err != nil {
+		return err
+	}
+
+	if _, ok := u.Users[user.ID]; ok {
+		return alreadyExists(model.UserDocType, user.ID)
+	}
+	return u.SetUser(user)
+}
+
+// UpdateUser validates and stores a copy of an existing user
+func (u *UserStore) UpdateUser(user *model.User) error {
+	if err := u.failure("UpdateUser"); err != nil {
+		return err
+	}
+
+	stored, ok := u.Users[user.ID]
+	if !ok || stored.IsDeleted() {
+		return notFound(model.UserDocType, user.ID)
+	}
+	if user.Revision != stored.Revision {
+		return conflict(model.UserDocType, user.ID, user.Revision, stored.Revision)
+	}
+	return u.SetUser(user)
+}
+
+// SetUser validates and stores a copy of an user
+func (u *UserStore) SetUser(user *model.User) error {
+	if err := u.failure("SetUser"); err != nil {
+		return err
+	}
+
+	if err := user.Valid(); err != nil {
+		return err
+	}
+
+	user.Revision = 1
+	if stored, ok := u.Users[user.ID]; ok {
+		user.Revision = stored.Revision + 1
+	}
+
+	u.Users[user.ID] = cloneUser(user)
+	return nil
+}
+
+// DeleteUser marks an user as deleted with a tombstone, keeping it's private
+// information
+func (u *UserStore) DeleteUser(userID string, tombstone *model.Tombstone) error {
+	if err := u.failure("DeleteUser"); err != nil {
+		return err
+	}
+
+	user, ok := u.Users[userID]
+	if !ok || user.IsDeleted() {
+		return notFound(model.UserDocType, userID)
+	}
+
+	user = cloneUser(user)
+	if err := user.Delete(tombstone); err != nil {
+		return err
+	}
+	return u.SetUser(user)
+}
+
+// RestoreUser removes the tombstone of a deleted user
+func (u *UserStore) RestoreUser(userID string) error {
+	if err := u.failure("RestoreUser"); err != nil {
+		return err
+	}
+
+	user, ok := u.Users[userID]
+	if !ok {
+		return notFound(model.UserDocType, userID)
+	}
+
+	user = cloneUser(user)
+	if err := user.Restore(); err != nil {
+		return fmt.Errorf("%s '%s' %s", model.UserDocType, userID, err.Error())
+	}
+	return u.SetUser(user)
+}
+
+// PurgeUser removes an user deleted for longer than `retention` and it's
+// private information
+func (u *UserStore) PurgeUser(userID string, retention time.Duration, now time.Time) error {
+	if err := u.failure("PurgeUser"); err != nil {
+		return err
+	}
+
+	user, ok := u.Users[userID]
+	if !ok {
+		return notFound(model.UserDocType, userID)
+	}
+
+	if err := purgeable(model.UserDocType, userID, user.Tombstone(), retention, now); err != nil {
+		return err
+	}
+
+	delete(u.Infos, userID)
+	delete(u.Users, userID)
+	return nil
+}
+
+// GetUserInfo returns a copy of an user's private information by it's ID
+func (u *UserStore) GetUserInfo(userID string) (*model.UserInfo, error) {
+	if err := u.failure("GetUserInfo"); err != nil {
+		return nil, err
+	}
+
+	info, ok := u.Infos[userID]
+	if !ok {
+		return nil, notFound(model.UserInfoDocType, userID)
+	}
+
+	clone := *info
+	return &clone, nil
+}
+
+// SetUserInfo validates and stores a copy of an user's private information
+func (u *UserStore) SetUserInfo(info *model.UserInfo) error {
+	if err := u.failure("SetUserInfo"); err != nil {
+		return err
+	}
+
+	if err := info.Valid(); err != nil {
+		return err
+	}
+
+	clone := *info
+	u.Infos[info.ID] = &clone
+	return nil
+}
+
+// GetPaymentKey returns a copy of the secret key of the payments' IDs
+func (u *UserStore) GetPaymentKey() (*model.PaymentKey, error) {
+	if err := u.failure("GetPaymentKey"); err != nil {
+		return nil, err
+	}
+
+	if u.PaymentKey == nil {
+		return nil, notFound(model.PaymentKeyDocType, "")
+	}
+
+	clone := *u.PaymentKey
+	return &clone, nil
+}
+
+// SetPaymentKey validates and stores a copy of the secret key of the
+// payments' IDs, failing if it was already set
+func (u *UserStore) SetPaymentKey(key *model.PaymentKey) error {
+	if err := u.failure("SetPaymentKey"); err != nil {
+		return err
+	}
+
+	if err := key.Valid(); err != nil {
+		return err
+	}
+
+	if u.PaymentKey != nil {
+		return alreadyExists(model.PaymentKeyDocType, "")
+	}
+
+	clone := *key
+	u.PaymentKey = &clone
+	return nil
+}
+
+// GetPayment returns a copy of a purchase of credits by it's payment
+// reference, including payments recorded before the payment key was set
+func (u *UserStore) GetPayment(reference string) (*model.Payment, error) {
+	if err := u.failure("GetPayment"); err != nil {
+		return nil, err
+	}
+
+	if u.PaymentKey == nil {
+		return nil, notFound(model.PaymentKeyDocType, "")
+	}
+
+	id := u.PaymentKey.PaymentID(reference)
+	payment, ok := u.Payments[id]
+	if !ok {
+		if payment, ok = u.Payments[model.LegacyPaymentID(reference)]; !ok {
+			return nil, notFound(model.PaymentDocType, id)
+		}
+	}
+
+	clone := *payment
+	return &clone, nil
+}
+
+// CreatePayment validates and stores a copy of a purchase of credits, failing
+// if it's reference was already used, even before the payment key was set
+func (u *UserStore) CreatePayment(payment *model.Payment) error {
+	if err := u.failure("CreatePayment"); err != nil {
+		return err
+	}
+
+	if err := payment.Valid(); err != nil {
+		return err
+	}
+
+	for _, id := range []string{payment.ID, model.LegacyPaymentID(payment.Reference)} {
+		if _, ok := u.Payments[id]; ok {
+			return alreadyExists(model.PaymentDocType, id)
+		}
+	}
+
+	clone := *payment
+	u.Payments[payment.ID] = &clone
+	return nil
+}
+
+// SetUserEndorsement stores the organizations which must endorse an user
+func (u *UserStore) SetUserEndorsement(userID string, orgs ...string) error {
+	if err := u.failure("SetUserEndorsement"); err != nil {
+		return err
+	}
+
+	u.Endorsements[userID] = append([]string{}, orgs...)
+	return nil
+}
+
+// GetUserEndorsement returns the organizations which must endorse an user
+func (u *UserStore) GetUserEndorsement(userID string) ([]string, error) {
+	if err := u.failure("GetUserEndorsement"); err != nil {
+		return nil, err
+	}
+
+	return u.Endorsements[userID], nil
+}
+
+// PendingUserMigrations lists nothing, since in-memory users are always up
+// to date
+func (u *UserStore) PendingUserMigrations(pageSize int, bookmark string) (*store.ScanResult, error) {
+	if err := u.failure("PendingUserMigrations"); err != nil {
+		return nil, err
+	}
+
+	return &store.ScanResult{Scanned: len(u.Users), IDs: []string{}, Done: true}, nil
+}
+
+// MigrateUser does nothing, since in-memory users are always up to date
+func (u *UserStore) MigrateUser(ids []string) (*store.RewriteResult, error) {
+	if err := u.failure("MigrateUser"); err != nil {
+		return nil, err
+	}
+
+	return &store.RewriteResult{Unchanged: len(ids)}, nil
+}
+
+// cloneUser copies an user and it's tombstone
+func cloneUser(user *model.User) *model.User {
+	clone := *user
+	clone.Deleted = cloneTombstone(user.Deleted)
+	return &clone
+}
@@ -0,0 +1,274 @@
+package store
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Index is a secondary index of an asset type. Each indexed asset has an
+// entry keyed by the index name, the indexed values and the asset's key
+// attributes, whose value is the asset's key
+type Index struct {
+	// Name is the object type of the index entries, such as "owner~id"
+	Name string
+	// Values returns the indexed values of an asset, or nil if the asset
+	// isn't indexed
+	Values func(asset Asset) []string
+}
+
+// IndexReport is the result of verifying or rebuilding the indexes
+type IndexReport struct {
+	// Checked is the number of assets checked
+	Checked int `json:"checked"`
+	// Missing are the entries which should exist, but don't
+	Missing []string `json:"missing"`
+	// Stale are the entries which exist, but shouldn't
+	Stale []string `json:"stale"`
+	// Repaired is true if the missing and stale entries were fixed
+	Repaired bool `json:"repaired"`
+}
+
+// Consistent verifies if no entries are missing or stale
+func (r *IndexReport) Consistent() bool {
+	return len(r.Missing) == 0 && len(r.Stale) == 0
+}
+
+// indexEntries returns the keys of the index entries of an asset
+func (r *Repository) indexEntries(asset Asset) (map[string]bool, error) {
+	entries := map[string]bool{}
+	if asset == nil {
+		return entries, nil
+	}
+
+	for _, index := range r.def.Indexes {
+		values := index.Values(asset)
+		if values == nil {
+			continue
+		}
+
+		key, err := r.stub.CreateCompositeKey(index.Name, append(values, r.def.Key(asset)...))
+		if err != nil {
+			return nil, err
+		}
+		entries[key] = true
+	}
+	return entries, nil
+}
+
+// reindex updates the index entries of an asset which changed from `old` to
+// `new`. Either may be nil when the asset is created or erased
+func (r *Repository) reindex(old, new Asset) error {
+	oldEntries, err := r.indexEntries(old)
+	if err != nil {
+		return err
+	}
+
+	newEntries, err := r.indexEntries(new)
+	if err != nil {
+		return err
+	}
+
+	for key := range oldEntries {
+		if !newEntries[key] {
+			if err := r.delState(key); err != nil {
+				return err
+			}
+		}
+	}
+
+	if new == nil {
+		return nil
+	}
+
+	assetKey := []byte(r.Key(r.def.Key(new)...))
+	for key := range newEntries {
+		if !oldEntries[key] {
+			if err := r.putState(key, assetKey); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writes returns the assets written by the current transaction, by key
+func (r *Repository) writes() map[string][]byte {
+	if txID := r.stub.GetTxID(); r.written == nil || r.txID != txID {
+		r.written, r.txID = map[string][]byte{}, txID
+	}
+	return r.written
+}
+
+// write records an asset written by the current transaction, nil if erased
+func (r *Repository) write(key string, data []byte) {
+	r.writes()[key] = data
+}
+
+// stored returns the current version of an asset, or nil if it isn't stored
+// or can't be decoded, whose index entries must be replaced. Assets written
+// by the current transaction are returned as written, since Fabric only
+// reads the state committed before the transaction
+func (r *Repository) stored(attributes []string) Asset {
+	key := r.Key(attributes...)
+
+	data, written := r.writes()[key]
+	if !written {
+		var err error
+		if data, err = r.getState(key); err != nil {
+			return nil
+		}
+	}
+	if data == nil {
+		return nil
+	}
+
+	asset, err := r.def.Decode(data)
+	if err != nil {
+		return nil
+	}
+	return asset
+}
+
+func (r *Repository) indexIterator(index string, values []string) (shim.StateQueryIteratorInterface, error) {
+	if r.def.Collection != "" {
+		return r.stub.GetPrivateDataByPartialCompositeKey(r.def.Collection, index, values)
+	}
+	return r.stub.GetStateByPartialCompositeKey(index, values)
+}
+
+// Query returns the valid assets whose first indexed values of `index` are
+// `values`, in index key order, including deleted ones. Invalid assets are
+// logged and skipped, as by Iterate
+func (r *Repository) Query(index string, values ...string) ([]Asset, error) {
+	r.logger.Debugf("Query: searching %s by %s %v", r.def.DocType, index, values)
+
+	iterator, err := r.indexIterator(index, values)
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.Close()
+
+	assets := []Asset{}
+	for iterator.HasNext() {
+		kv, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := r.getState(string(kv.GetValue()))
+		if err != nil {
+			return nil, err
+		}
+
+		// entradas inconsistentes são ignoradas até a reconstrução do índice
+		if data == nil {
+			r.logger.Warningf("Query: stale %s entry '%s'", index, r.describe(kv.GetKey()))
+			continue
+		}
+
+		asset, err := r.def.Decode(data)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := r.validate(asset); err != nil {
+			r.logger.Warningf("Query: skipping invalid %s '%s': %s", r.def.DocType,
+				r.describe(string(kv.GetValue())), err.Error())
+			continue
+		}
+		assets = append(assets, asset)
+	}
+	return assets, nil
+}
+
+// describe formats a composite key as "<object type>/<attribute>/..."
+func (r *Repository) describe(key string) string {
+	objectType, attributes, err := r.stub.SplitCompositeKey(key)
+	if err != nil {
+		return key
+	}
+	return strings.Join(append([]string{objectType}, attributes...), "/")
+}
+
+// VerifyIndexes compares the index entries with the stored assets, fixing
+// missing and stale entries if `repair` is set. Every asset and entry is
+// read, including invalid assets, so it must only be used for maintenance
+func (r *Repository) VerifyIndexes(repair bool) (*IndexReport, error) {
+	r.logger.Debugf("VerifyIndexes: verifying %s indexes", r.def.DocType)
+
+	expected := map[string]string{}
+	report := &IndexReport{Missing: []string{}, Stale: []string{}}
+
+	err := r.iterate(func(assetKey string, asset Asset) error {
+		report.Checked++
+
+		entries, err := r.indexEntries(asset)
+		if err != nil {
+			return err
+		}
+
+		for key := range entries {
+			expected[key] = assetKey
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stale := []string{}
+	for _, index := range r.def.Indexes {
+		iterator, err := r.indexIterator(index.Name, []string{})
+		if err != nil {
+			return nil, err
+		}
+
+		for iterator.HasNext() {
+			kv, err := iterator.Next()
+			if err != nil {
+				iterator.Close()
+				return nil, err
+			}
+
+			if assetKey, ok := expected[kv.GetKey()]; ok && assetKey == string(kv.GetValue()) {
+				delete(expected, kv.GetKey())
+				continue
+			}
+			stale = append(stale, kv.GetKey())
+		}
+		iterator.Close()
+	}
+
+	missing := []string{}
+	for key := range expected {
+		missing = append(missing, key)
+	}
+	sort.Strings(missing)
+
+	for _, key := range stale {
+		report.Stale = append(report.Stale, r.describe(key))
+	}
+	for _, key := range missing {
+		report.Missing = append(report.Missing, r.describe(key))
+	}
+
+	if !repair || report.Consistent() {
+		return report, nil
+	}
+
+	for _, key := range stale {
+		if err := r.delState(key); err != nil {
+			return nil, err
+		}
+	}
+	for _, key := range missing {
+		if err := r.putState(key, []byte(expected[key])); err != nil {
+			return nil, err
+		}
+	}
+
+	report.Repaired = true
+	return report, nil
+}
@@ -0,0 +1,169 @@
+package store_test
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cdtlab19/coffee-chaincode/mockstub"
+	"github.com/cdtlab19/coffee-chaincode/model"
+	. "github.com/cdtlab19/coffee-chaincode/store"
+)
+
+// committedStub reads the state committed before the transaction, like
+// Fabric, unlike the mock which reads it's own writes
+type committedStub struct {
+	*mockstub.Stub
+	writes map[string][]byte
+}
+
+func (s *committedStub) PutState(key string, value []byte) error {
+	s.writes[key] = value
+	return nil
+}
+
+func (s *committedStub) DelState(key string) error {
+	s.writes[key] = nil
+	return nil
+}
+
+// commit applies the writes to the mock
+func (s *committedStub) commit() {
+	for key, value := range s.writes {
+		if value == nil {
+			Expect(s.Stub.DelState(key)).To(Succeed())
+		} else {
+			Expect(s.Stub.PutState(key, value)).To(Succeed())
+		}
+	}
+}
+
+var _ = Describe("Coffee indexes", func() {
+	var mock *mockstub.Stub
+	var st *CoffeeStore
+
+	ids := func(coffees []*model.Coffee, err error) []string {
+		Expect(err).NotTo(HaveOccurred())
+		ids := []string{}
+		for _, coffee := range coffees {
+			ids = append(ids, coffee.ID)
+		}
+		return ids
+	}
+
+	owned := func(id, flavour, owner string) *model.Coffee {
+		coffee := model.NewCoffee(id, flavour)
+		Expect(coffee.SetOwner(owner)).To(Succeed())
+		return coffee
+	}
+
+	BeforeEach(func() {
+		mock = mockstub.NewStub("store", nil)
+		st = NewCoffeeStore(mock, shim.NewLogger("store-test"))
+		mock.MockTransactionStart("0000")
+
+		Expect(st.CreateCoffee(owned("0000", "cappuccino", "alice"))).To(Succeed())
+		Expect(st.CreateCoffee(owned("0001", "chocolate", "bob"))).To(Succeed())
+		Expect(st.CreateCoffee(model.NewCoffee("0002", "cappuccino"))).To(Succeed())
+	})
+
+	AfterEach(func() {
+		mock.MockTransactionEnd("0000")
+	})
+
+	It("Should query coffees by owner, flavour and state", func() {
+		Expect(ids(st.CoffeeByOwner("alice"))).To(Equal([]string{"0000"}))
+		Expect(ids(st.CoffeeByOwner("carol"))).To(BeEmpty())
+		Expect(ids(st.CoffeeByFlavour("cappuccino"))).To(Equal([]string{"0000", "0002"}))
+		Expect(ids(st.CoffeeByState(model.CoffeeAvailable))).To(Equal([]string{"0002"}))
+		Expect(ids(st.CoffeeByState(model.CoffeeUsed))).To(Equal([]string{"0000", "0001"}))
+	})
+
+	It("Should move coffees between entries when they change", func() {
+		coffee, err := st.GetCoffee("0002")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(coffee.SetOwner("alice")).To(Succeed())
+		Expect(st.UpdateCoffee(coffee)).To(Succeed())
+
+		Expect(ids(st.CoffeeByOwner("alice"))).To(Equal([]string{"0000", "0002"}))
+		Expect(ids(st.CoffeeByState(model.CoffeeAvailable))).To(BeEmpty())
+		Expect(ids(st.CoffeeByState(model.CoffeeUsed))).To(Equal([]string{"0000", "0001", "0002"}))
+	})
+
+	It("Should hide deleted coffees and remove purged ones", func() {
+		Expect(st.DeleteCoffee("0000", testTombstone())).To(Succeed())
+
+		Expect(ids(st.CoffeeByOwner("alice"))).To(BeEmpty())
+		Expect(ids(st.CoffeeByFlavour("cappuccino"))).To(Equal([]string{"0002"}))
+		Expect(ids(st.CoffeeByState(model.CoffeeDeleted))).To(Equal([]string{"0000"}))
+
+		Expect(st.PurgeCoffee("0000", 0, deletedAt)).To(Succeed())
+		Expect(ids(st.CoffeeByState(model.CoffeeDeleted))).To(BeEmpty())
+
+		report, err := st.VerifyCoffeeIndexes(false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Consistent()).To(BeTrue(), "%+v", report)
+	})
+
+	It("Should report consistent indexes", func() {
+		report, err := st.VerifyCoffeeIndexes(false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Checked).To(Equal(3))
+		Expect(report.Consistent()).To(BeTrue(), "%+v", report)
+	})
+
+	It("Should replace the entries of assets written twice in a transaction", func() {
+		stub := &committedStub{mock, map[string][]byte{}}
+		repeated := NewCoffeeStore(stub, shim.NewLogger("store-test"))
+
+		Expect(repeated.SetCoffee(owned("0002", "cappuccino", "carol"))).To(Succeed())
+		Expect(repeated.SetCoffee(owned("0002", "cappuccino", "dave"))).To(Succeed())
+		stub.commit()
+
+		Expect(ids(st.CoffeeByOwner("carol"))).To(BeEmpty())
+		Expect(ids(st.CoffeeByOwner("dave"))).To(Equal([]string{"0002"}))
+
+		report, err := st.VerifyCoffeeIndexes(false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Consistent()).To(BeTrue(), "%+v", report)
+	})
+
+	Context("with inconsistent entries", func() {
+		BeforeEach(func() {
+			// café gravado sem índices, como por versões anteriores
+			key, _ := mock.CreateCompositeKey(model.CoffeeDocType, []string{"0003"})
+			Expect(mock.PutState(key, owned("0003", "mocha", "alice").JSON())).To(Succeed())
+
+			stale, _ := mock.CreateCompositeKey(CoffeeOwnerIndex, []string{"carol", "0001"})
+			Expect(mock.PutState(stale, []byte("0001"))).To(Succeed())
+		})
+
+		It("Should report missing and stale entries", func() {
+			report, err := st.VerifyCoffeeIndexes(false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.Checked).To(Equal(4))
+			Expect(report.Missing).To(ConsistOf(
+				"owner~id/alice/0003",
+				"flavour~id/mocha/0003",
+				"state~id/used/0003",
+			))
+			Expect(report.Stale).To(Equal([]string{"owner~id/carol/0001"}))
+			Expect(report.Repaired).To(BeFalse())
+
+			Expect(ids(st.CoffeeByOwner("alice"))).To(Equal([]string{"0000"}))
+		})
+
+		It("Should rebuild the indexes", func() {
+			report, err := st.VerifyCoffeeIndexes(true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.Repaired).To(BeTrue())
+
+			Expect(ids(st.CoffeeByOwner("alice"))).To(Equal([]string{"0000", "0003"}))
+			Expect(ids(st.CoffeeByOwner("carol"))).To(BeEmpty())
+
+			report, err = st.VerifyCoffeeIndexes(false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.Consistent()).To(BeTrue(), "%+v", report)
+		})
+	})
+})
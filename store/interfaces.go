@@ -0,0 +1,53 @@
+package store
+
+import (
+	"time"
+
+	"github.com/cdtlab19/coffee-chaincode/model"
+)
+
+// CoffeeRepository abstracts the coffee persistence used by the chaincodes
+type CoffeeRepository interface {
+	AllCoffee(includeDeleted bool) ([]*model.Coffee, error)
+	GetCoffee(coffeeID string) (*model.Coffee, error)
+	CoffeeByOwner(owner string) ([]*model.Coffee, error)
+	CoffeeByFlavour(flavour string) ([]*model.Coffee, error)
+	CoffeeByState(state string) ([]*model.Coffee, error)
+	CreateCoffee(coffee *model.Coffee) error
+	UpdateCoffee(coffee *model.Coffee) error
+	SetCoffee(coffee *model.Coffee) error
+	DeleteCoffee(coffeeID string, tombstone *model.Tombstone) error
+	RestoreCoffee(coffeeID string) error
+	PurgeCoffee(coffeeID string, retention time.Duration, now time.Time) error
+	PendingCoffeeMigrations(pageSize int, bookmark string) (*ScanResult, error)
+	MigrateCoffee(ids []string) (*RewriteResult, error)
+	VerifyCoffeeIndexes(repair bool) (*IndexReport, error)
+}
+
+// UserRepository abstracts the user persistence used by the chaincodes
+type UserRepository interface {
+	AllUser(includeDeleted bool) ([]*model.User, error)
+	GetUser(userID string) (*model.User, error)
+	CreateUser(user *model.User) error
+	UpdateUser(user *model.User) error
+	SetUser(user *model.User) error
+	DeleteUser(userID string, tombstone *model.Tombstone) error
+	RestoreUser(userID string) error
+	PurgeUser(userID string, retention time.Duration, now time.Time) error
+	GetUserInfo(userID string) (*model.UserInfo, error)
+	SetUserInfo(info *model.UserInfo) error
+	GetPaymentKey() (*model.PaymentKey, error)
+	SetPaymentKey(key *model.PaymentKey) error
+	GetPayment(reference string) (*model.Payment, error)
+	CreatePayment(payment *model.Payment) error
+	SetUserEndorsement(userID string, orgs ...string) error
+	GetUserEndorsement(userID string) ([]string, error)
+	PendingUserMigrations(pageSize int, bookmark string) (*ScanResult, error)
+	MigrateUser(ids []string) (*RewriteResult, error)
+}
+
+// Checagem em tempo de compilação se os stores implementam os repositórios
+var (
+	_ CoffeeRepository = &CoffeeStore{}
+	_ UserRepository   = &UserStore{}
+)
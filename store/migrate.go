@@ -0,0 +1,211 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/cdtlab19/coffee-chaincode/model"
+	"github.com/hyperledger/fabric/protos/ledger/queryresult"
+)
+
+// ScanResult lists the records of a page selected by a scan. Fabric doesn't
+// allow writes after paginated queries, so scans are called by queries and
+// the selected records are changed by another transaction
+type ScanResult struct {
+	// Scanned is the number of records read in this page
+	Scanned int `json:"scanned"`
+	// IDs are the selected records
+	IDs []string `json:"ids"`
+	// Bookmark must be sent to scan the next page, empty after the last page
+	Bookmark string `json:"bookmark"`
+	// Done is true when there are no more records to scan
+	Done bool `json:"done"`
+}
+
+// PageInfo returns the bookmark of the next page and if there are no more
+// records to read
+func (r *ScanResult) PageInfo() (string, bool) {
+	return r.Bookmark, r.Done
+}
+
+// RewriteResult reports how many of the listed records were changed
+type RewriteResult struct {
+	Changed int `json:"changed"`
+	// Unchanged is the number of records which didn't need to be changed,
+	// or no longer exist
+	Unchanged int `json:"unchanged"`
+	// Invalid are the records skipped because they would be invalid once
+	// changed, which must be fixed before they can be changed
+	Invalid []string `json:"invalid,omitempty"`
+}
+
+// invalidError is returned by the functions called by Rewrite to skip a
+// record which would be invalid once changed
+type invalidError struct {
+	err error
+}
+
+func (e *invalidError) Error() string {
+	return e.err.Error()
+}
+
+// schemaVersion is used to peek a document's schema version before decoding
+type schemaVersion struct {
+	SchemaVersion int `json:"schemaVersion"`
+}
+
+// outdated verifies if a document was stored with an older schema version
+// than `current`
+func outdated(data []byte, current int) (bool, error) {
+	var version schemaVersion
+	if err := json.Unmarshal(data, &version); err != nil {
+		return false, err
+	}
+	return version.SchemaVersion != current, nil
+}
+
+// decodeCoffee decodes a coffee document of any schema version, upgrading it
+// to the current version
+func decodeCoffee(data []byte) (*model.Coffee, error) {
+	var version schemaVersion
+	if err := json.Unmarshal(data, &version); err != nil {
+		return nil, err
+	}
+
+	if version.SchemaVersion > model.CoffeeSchemaVersion {
+		return nil, fmt.Errorf("unsupported coffee schema version %d", version.SchemaVersion)
+	}
+
+	coffee := &model.Coffee{}
+	if err := json.Unmarshal(data, coffee); err != nil {
+		return nil, err
+	}
+
+	// version 0 had the same shape, but no schema version
+	coffee.DocType = model.CoffeeDocType
+	coffee.SchemaVersion = model.CoffeeSchemaVersion
+	return coffee, nil
+}
+
+// userV0 is the user document before personal information was moved to a
+// private data collection
+type userV0 struct {
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	RemainingCoffee int    `json:"remainingCoffee"`
+}
+
+// decodeUser decodes an user document of any schema version, upgrading it to
+// the current version. Personal information found in older versions is
+// returned as an UserInfo, which must be moved to the private collection
+func decodeUser(data []byte) (*model.User, *model.UserInfo, error) {
+	var version schemaVersion
+	if err := json.Unmarshal(data, &version); err != nil {
+		return nil, nil, err
+	}
+
+	switch version.SchemaVersion {
+	case 0:
+		old := &userV0{}
+		if err := json.Unmarshal(data, old); err != nil {
+			return nil, nil, err
+		}
+
+		user := model.NewUser(old.ID, old.RemainingCoffee)
+		if old.Name == "" {
+			return user, nil, nil
+		}
+
+		info := model.NewUserInfo(old.ID, old.Name, "", "")
+		user.SetInfo(info)
+		return user, info, nil
+
+	case model.UserSchemaVersion:
+		user := &model.User{}
+		if err := json.Unmarshal(data, user); err != nil {
+			return nil, nil, err
+		}
+		return user, nil, nil
+
+	default:
+		return nil, nil, fmt.Errorf("unsupported user schema version %d", version.SchemaVersion)
+	}
+}
+
+// Scan reads a page of `pageSize` stored assets starting at `bookmark`,
+// calling `fn` with each asset's ID and raw data, and lists the assets for
+// which `fn` returns true. It runs a paginated query, so it must only be
+// called by queries
+func (r *Repository) Scan(pageSize int, bookmark string,
+	fn func(id string, data []byte) (bool, error)) (*ScanResult, error) {
+	if pageSize < 1 {
+		return nil, fmt.Errorf("invalid page size %d", pageSize)
+	}
+
+	result := &ScanResult{IDs: []string{}}
+	next, err := r.page(r.def.DocType, pageSize, bookmark, func(kv *queryresult.KV) error {
+		_, attributes, err := r.stub.SplitCompositeKey(kv.GetKey())
+		if err != nil {
+			return err
+		}
+
+		id := attributes[0]
+		selected, err := fn(id, kv.GetValue())
+		if err != nil {
+			return fmt.Errorf("failed scanning %s '%s': %s", r.def.DocType, id, err.Error())
+		}
+
+		result.Scanned++
+		if selected {
+			result.IDs = append(result.IDs, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.Bookmark, result.Done = next, next == ""
+	return result, nil
+}
+
+// Rewrite calls `fn` with the ID and raw data of each listed asset, usually
+// selected by Scan, which returns true if it changed the asset. Assets which
+// no longer exist are skipped, and assets for which `fn` returns an
+// invalidError are logged, skipped and listed as invalid
+func (r *Repository) Rewrite(ids []string, fn func(id string, data []byte) (bool, error)) (*RewriteResult, error) {
+	result := &RewriteResult{}
+	seen := map[string]bool{}
+
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		data, err := r.getState(r.Key(id))
+		if err != nil {
+			return nil, err
+		}
+
+		changed := false
+		if data != nil {
+			changed, err = fn(id, data)
+			if invalid, ok := err.(*invalidError); ok {
+				r.logger.Warningf("Rewrite: skipping invalid %s '%s': %s", r.def.DocType, id, invalid.Error())
+				result.Invalid = append(result.Invalid, id)
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed rewriting %s '%s': %s", r.def.DocType, id, err.Error())
+			}
+		}
+
+		if changed {
+			result.Changed++
+		} else {
+			result.Unchanged++
+		}
+	}
+	return result, nil
+}
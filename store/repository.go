@@ -0,0 +1,479 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cdtlab19/coffee-chaincode/model"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/ledger/queryresult"
+)
+
+// Logger writes the debug lines of a repository. It's implemented by
+// *shim.ChaincodeLogger and the transaction loggers of the logging package
+type Logger interface {
+	Debug(args ...interface{})
+	Debugf(format string, args ...interface{})
+	Warningf(format string, args ...interface{})
+}
+
+// Asset is a document stored by a Repository
+type Asset interface {
+	// Valid verifies if the asset may be stored
+	Valid() error
+	// JSON encodes the asset
+	JSON() []byte
+}
+
+// Deletable is an Asset which is soft deleted by a tombstone, so it's kept in
+// the ledger until purged
+type Deletable interface {
+	Asset
+	IsDeleted() bool
+	Tombstone() *model.Tombstone
+	Delete(tombstone *model.Tombstone) error
+	Restore() error
+}
+
+// Revisioned is an Asset whose revision is incremented on every write
+type Revisioned interface {
+	Asset
+	GetRevision() int
+	SetRevision(revision int)
+}
+
+// revision returns the revision of a Revisioned asset, or 0
+func revision(asset Asset) int {
+	if revisioned, ok := asset.(Revisioned); ok {
+		return revisioned.GetRevision()
+	}
+	return 0
+}
+
+// isDeleted verifies if an asset is deletable and was deleted
+func isDeleted(asset Asset) bool {
+	deletable, ok := asset.(Deletable)
+	return ok && deletable.IsDeleted()
+}
+
+// Definition describes how a Repository stores an asset type
+type Definition struct {
+	// DocType is the object type of the asset's composite keys
+	DocType string
+	// Collection is the private data collection where assets are stored. If
+	// empty, assets are stored in the public state
+	Collection string
+	// Key returns the composite key attributes of an asset
+	Key func(asset Asset) []string
+	// Decode decodes a stored asset, upgrading older schema versions
+	Decode func(data []byte) (Asset, error)
+	// Validators are additional validations run after Asset.Valid whenever
+	// an asset is written or read by it's key
+	Validators []func(asset Asset) error
+	// Indexes are the secondary indexes kept consistent on every write
+	Indexes []Index
+}
+
+// Repository implements the CRUD operations over composite keys shared by
+// every asset store
+type Repository struct {
+	stub   shim.ChaincodeStubInterface
+	logger Logger
+	def    Definition
+	// written are the assets written by the transaction `txID`, nil if
+	// erased. Fabric doesn't read a transaction's own writes, so they replace
+	// the stored assets whose index entries must be replaced
+	written map[string][]byte
+	txID    string
+}
+
+// NewRepository creates a new Repository for an asset Definition. Index
+// entries are only kept consistent if each asset is written through a single
+// Repository in a transaction
+func NewRepository(stub shim.ChaincodeStubInterface, logger Logger, def Definition) *Repository {
+	return &Repository{stub: stub, logger: logger, def: def}
+}
+
+// Key returns the composite key of an asset by it's key attributes
+func (r *Repository) Key(attributes ...string) (key string) {
+	key, _ = r.stub.CreateCompositeKey(r.def.DocType, attributes)
+	return
+}
+
+// validate runs all asset validations
+func (r *Repository) validate(asset Asset) error {
+	if err := asset.Valid(); err != nil {
+		return err
+	}
+	for _, validator := range r.def.Validators {
+		if err := validator(asset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decode decodes and validates a stored asset
+func (r *Repository) decode(data []byte) (Asset, error) {
+	asset, err := r.def.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return asset, r.validate(asset)
+}
+
+func (r *Repository) getState(key string) ([]byte, error) {
+	if r.def.Collection != "" {
+		return r.stub.GetPrivateData(r.def.Collection, key)
+	}
+	return r.stub.GetState(key)
+}
+
+func (r *Repository) putState(key string, value []byte) error {
+	if r.def.Collection != "" {
+		return r.stub.PutPrivateData(r.def.Collection, key, value)
+	}
+	return r.stub.PutState(key, value)
+}
+
+func (r *Repository) delState(key string) error {
+	if r.def.Collection != "" {
+		return r.stub.DelPrivateData(r.def.Collection, key)
+	}
+	return r.stub.DelState(key)
+}
+
+func (r *Repository) iterator() (shim.StateQueryIteratorInterface, error) {
+	if r.def.Collection != "" {
+		return r.stub.GetPrivateDataByPartialCompositeKey(r.def.Collection, r.def.DocType, []string{})
+	}
+	return r.stub.GetStateByPartialCompositeKey(r.def.DocType, []string{})
+}
+
+// page calls `fn` with up to `pageSize` keys of an object type, starting at
+// `bookmark`, and returns the bookmark of the next page, or "" after the last
+// page. Public state is read with a paginated query, whose bookmarks are
+// returned by the state database, so they must be sent back unchanged.
+// Fabric has no paginated or range queries over the composite keys of private
+// data, so private pages skip the keys before the bookmark, which is the key
+// the page starts at. Fabric doesn't allow writes after either query, so it
+// must only be called by queries
+func (r *Repository) page(objectType string, pageSize int, bookmark string, fn func(kv *queryresult.KV) error) (string, error) {
+	if r.def.Collection == "" {
+		iterator, metadata, err := r.stub.GetStateByPartialCompositeKeyWithPagination(objectType, []string{},
+			int32(pageSize), bookmark)
+		if err != nil {
+			return "", err
+		}
+		defer iterator.Close()
+
+		for iterator.HasNext() {
+			kv, err := iterator.Next()
+			if err != nil {
+				return "", err
+			}
+			if err := fn(kv); err != nil {
+				return "", err
+			}
+		}
+
+		if metadata == nil || int(metadata.FetchedRecordsCount) < pageSize {
+			return "", nil
+		}
+		return metadata.Bookmark, nil
+	}
+
+	iterator, err := r.stub.GetPrivateDataByPartialCompositeKey(r.def.Collection, objectType, []string{})
+	if err != nil {
+		return "", err
+	}
+	defer iterator.Close()
+
+	read := 0
+	for iterator.HasNext() {
+		kv, err := iterator.Next()
+		if err != nil {
+			return "", err
+		}
+		if kv.GetKey() < bookmark {
+			continue
+		}
+		if read == pageSize {
+			return kv.GetKey(), nil
+		}
+		if err := fn(kv); err != nil {
+			return "", err
+		}
+		read++
+	}
+	return "", nil
+}
+
+// notFound returns the NotFoundError of an asset
+func (r *Repository) notFound(attributes []string) error {
+	return &NotFoundError{r.def.DocType, strings.Join(attributes, ":")}
+}
+
+// get returns an asset by it's key attributes, even if it was deleted
+func (r *Repository) get(attributes []string) (Asset, error) {
+	data, err := r.getState(r.Key(attributes...))
+	if err != nil {
+		return nil, err
+	}
+
+	if data == nil {
+		return nil, r.notFound(attributes)
+	}
+
+	return r.decode(data)
+}
+
+// getDeletable returns a Deletable asset by it's key attributes, even if it
+// was deleted
+func (r *Repository) getDeletable(attributes []string) (Deletable, error) {
+	asset, err := r.get(attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	deletable, ok := asset.(Deletable)
+	if !ok {
+		return nil, fmt.Errorf("%s can't be soft deleted", r.def.DocType)
+	}
+	return deletable, nil
+}
+
+// Get returns an asset by it's key attributes. Deleted assets are reported
+// as NotFoundError
+func (r *Repository) Get(attributes ...string) (Asset, error) {
+	r.logger.Debugf("Get: searching for %s %v", r.def.DocType, attributes)
+
+	asset, err := r.get(attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	if isDeleted(asset) {
+		return nil, r.notFound(attributes)
+	}
+	return asset, nil
+}
+
+// Exists verifies if an asset exists by it's key attributes. Deleted assets
+// still exist until they are purged
+func (r *Repository) Exists(attributes ...string) (bool, error) {
+	data, err := r.getState(r.Key(attributes...))
+	if err != nil {
+		return false, err
+	}
+	return data != nil, nil
+}
+
+// Create validates and stores a new asset, failing with AlreadyExistsError
+// if it already exists
+func (r *Repository) Create(asset Asset) error {
+	attributes := r.def.Key(asset)
+
+	exists, err := r.Exists(attributes...)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return &AlreadyExistsError{r.def.DocType, strings.Join(attributes, ":")}
+	}
+
+	return r.Put(asset)
+}
+
+// Update validates and stores an existing asset, failing with NotFoundError
+// if it doesn't exist or was deleted. Revisioned assets must have the stored
+// revision, or it fails with ConflictError
+func (r *Repository) Update(asset Asset) error {
+	attributes := r.def.Key(asset)
+
+	stored, err := r.Get(attributes...)
+	if err != nil {
+		return err
+	}
+
+	if expected, actual := revision(asset), revision(stored); expected != actual {
+		return &ConflictError{r.def.DocType, strings.Join(attributes, ":"), expected, actual}
+	}
+
+	return r.Put(asset)
+}
+
+// Put validates and stores an asset, whether it exists or not. The revision
+// of Revisioned assets is set to the stored revision plus one
+func (r *Repository) Put(asset Asset) error {
+	attributes := r.def.Key(asset)
+	r.logger.Debugf("Put: setting %s %v", r.def.DocType, attributes)
+
+	if err := r.validate(asset); err != nil {
+		return err
+	}
+
+	stored := r.stored(attributes)
+	if revisioned, ok := asset.(Revisioned); ok {
+		revisioned.SetRevision(revision(stored) + 1)
+	}
+
+	if len(r.def.Indexes) > 0 {
+		if err := r.reindex(stored, asset); err != nil {
+			return err
+		}
+	}
+
+	key, data := r.Key(attributes...), asset.JSON()
+	if err := r.putState(key, data); err != nil {
+		return err
+	}
+	r.write(key, data)
+	return nil
+}
+
+// Delete erases an asset from the state by it's key attributes, failing
+// with NotFoundError if it doesn't exist. Deletable assets should be soft
+// deleted with SoftDelete instead
+func (r *Repository) Delete(attributes ...string) error {
+	r.logger.Debugf("Delete: deleting %s %v", r.def.DocType, attributes)
+
+	exists, err := r.Exists(attributes...)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return r.notFound(attributes)
+	}
+
+	return r.erase(attributes)
+}
+
+// erase deletes an asset and it's index entries from the state
+func (r *Repository) erase(attributes []string) error {
+	if len(r.def.Indexes) > 0 {
+		if err := r.reindex(r.stored(attributes), nil); err != nil {
+			return err
+		}
+	}
+
+	key := r.Key(attributes...)
+	if err := r.delState(key); err != nil {
+		return err
+	}
+	r.write(key, nil)
+	return nil
+}
+
+// SoftDelete marks a Deletable asset as deleted with a tombstone, failing
+// with NotFoundError if it doesn't exist or was already deleted
+func (r *Repository) SoftDelete(tombstone *model.Tombstone, attributes ...string) error {
+	r.logger.Debugf("SoftDelete: deleting %s %v", r.def.DocType, attributes)
+
+	asset, err := r.Get(attributes...)
+	if err != nil {
+		return err
+	}
+
+	deletable, ok := asset.(Deletable)
+	if !ok {
+		return fmt.Errorf("%s can't be soft deleted", r.def.DocType)
+	}
+
+	if err := deletable.Delete(tombstone); err != nil {
+		return err
+	}
+	return r.Put(deletable)
+}
+
+// Restore removes the tombstone of a deleted asset
+func (r *Repository) Restore(attributes ...string) error {
+	r.logger.Debugf("Restore: restoring %s %v", r.def.DocType, attributes)
+
+	deletable, err := r.getDeletable(attributes)
+	if err != nil {
+		return err
+	}
+
+	if err := deletable.Restore(); err != nil {
+		return fmt.Errorf("%s '%s' %s", r.def.DocType, strings.Join(attributes, ":"), err.Error())
+	}
+	return r.Put(deletable)
+}
+
+// Purge erases a deleted asset from the state, once it was deleted for
+// longer than `retention` at the time `now`
+func (r *Repository) Purge(retention time.Duration, now time.Time, attributes ...string) error {
+	r.logger.Debugf("Purge: purging %s %v", r.def.DocType, attributes)
+
+	deletable, err := r.getDeletable(attributes)
+	if err != nil {
+		return err
+	}
+
+	id := strings.Join(attributes, ":")
+	if !deletable.IsDeleted() {
+		return fmt.Errorf("%s '%s' must be deleted before it's purged", r.def.DocType, id)
+	}
+
+	purgeAt := deletable.Tombstone().DeletedAt.Add(retention)
+	if now.Before(purgeAt) {
+		return fmt.Errorf("%s '%s' can't be purged before %s", r.def.DocType, id,
+			purgeAt.Format(time.RFC3339))
+	}
+
+	return r.erase(attributes)
+}
+
+// Iterate calls `fn` for every valid stored asset, including deleted ones,
+// in key order, stopping at the first error. Invalid assets, usually stored
+// by older versions, are logged and skipped
+func (r *Repository) Iterate(fn func(asset Asset) error) error {
+	return r.iterate(func(key string, asset Asset) error {
+		if err := r.validate(asset); err != nil {
+			r.logger.Warningf("Iterate: skipping invalid %s '%s': %s", r.def.DocType, r.describe(key), err.Error())
+			return nil
+		}
+		return fn(asset)
+	})
+}
+
+// iterate calls `fn` with the key of every stored asset, valid or not, in
+// key order, stopping at the first error
+func (r *Repository) iterate(fn func(key string, asset Asset) error) error {
+	iterator, err := r.iterator()
+	if err != nil {
+		return err
+	}
+	defer iterator.Close()
+
+	for iterator.HasNext() {
+		kv, err := iterator.Next()
+		if err != nil {
+			return err
+		}
+
+		asset, err := r.def.Decode(kv.GetValue())
+		if err != nil {
+			return err
+		}
+
+		if err := fn(kv.GetKey(), asset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List returns all stored assets, including deleted ones
+func (r *Repository) List() ([]Asset, error) {
+	r.logger.Debugf("List: listing %s", r.def.DocType)
+
+	assets := []Asset{}
+	err := r.Iterate(func(asset Asset) error {
+		assets = append(assets, asset)
+		return nil
+	})
+	return assets, err
+}
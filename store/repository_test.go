@@ -0,0 +1,131 @@
+package store_test
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cdtlab19/coffee-chaincode/mockstub"
+	"github.com/cdtlab19/coffee-chaincode/model"
+	. "github.com/cdtlab19/coffee-chaincode/store"
+)
+
+// userInfoDefinition stores user infos in a private data collection, as the
+// user store does
+var userInfoDefinition = Definition{
+	DocType:    model.UserInfoDocType,
+	Collection: UserInfoCollection,
+	Key: func(asset Asset) []string {
+		return []string{asset.(*model.UserInfo).ID}
+	},
+	Decode: func(data []byte) (Asset, error) {
+		info := &model.UserInfo{}
+		return info, json.Unmarshal(data, info)
+	},
+}
+
+type repositoryAdapter struct{ *Repository }
+
+func (a repositoryAdapter) Get(id string) (interface{}, error) { return a.Repository.Get(id) }
+func (a repositoryAdapter) Create(asset interface{}) error     { return a.Repository.Create(asset.(Asset)) }
+func (a repositoryAdapter) Update(asset interface{}) error     { return a.Repository.Update(asset.(Asset)) }
+func (a repositoryAdapter) Put(asset interface{}) error        { return a.Repository.Put(asset.(Asset)) }
+func (a repositoryAdapter) Delete(id string) error             { return a.Repository.Delete(id) }
+
+func (a repositoryAdapter) List() ([]interface{}, error) {
+	list, err := a.Repository.List()
+	assets := []interface{}{}
+	for _, asset := range list {
+		assets = append(assets, asset)
+	}
+	return assets, err
+}
+
+var _ = describeConformance("Private Repository", conformance{
+	New: func(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger) storeAdapter {
+		return repositoryAdapter{NewRepository(stub, logger, userInfoDefinition)}
+	},
+	Valid: func(id string) interface{} {
+		return model.NewUserInfo(id, "someone", "", "")
+	},
+	Invalid: func(id string) interface{} {
+		return model.NewUserInfo(id, "", "", "")
+	},
+})
+
+var _ = Describe("Repository", func() {
+	var mock *mockstub.Stub
+	var logger *shim.ChaincodeLogger
+
+	BeforeEach(func() {
+		mock = mockstub.NewStub("store", nil)
+		logger = shim.NewLogger("store-test")
+		mock.MockTransactionStart("0000")
+	})
+
+	AfterEach(func() {
+		mock.MockTransactionEnd("0000")
+	})
+
+	It("Should verify if assets exist", func() {
+		repository := NewRepository(mock, logger, userInfoDefinition)
+		Expect(repository.Put(model.NewUserInfo("0000", "someone", "", ""))).To(Succeed())
+
+		Expect(repository.Exists("0000")).To(BeTrue())
+		Expect(repository.Exists("0001")).To(BeFalse())
+	})
+
+	It("Should store private assets only in their collection", func() {
+		repository := NewRepository(mock, logger, userInfoDefinition)
+		Expect(repository.Put(model.NewUserInfo("0000", "someone", "", ""))).To(Succeed())
+
+		Expect(mock.State).To(BeEmpty())
+		Expect(mock.PvtState[UserInfoCollection]).To(HaveLen(1))
+	})
+
+	It("Should run additional validators on writes and reads by key", func() {
+		def := userInfoDefinition
+		def.Validators = []func(Asset) error{
+			func(asset Asset) error {
+				if asset.(*model.UserInfo).Badge == "" {
+					return errors.New("missing badge")
+				}
+				return nil
+			},
+		}
+
+		repository := NewRepository(mock, logger, def)
+		Expect(repository.Put(model.NewUserInfo("0000", "someone", "", ""))).NotTo(Succeed())
+		Expect(repository.Put(model.NewUserInfo("0000", "someone", "", "42"))).To(Succeed())
+
+		// stored without the validator
+		Expect(NewRepository(mock, logger, userInfoDefinition).
+			Put(model.NewUserInfo("0001", "anyone", "", ""))).To(Succeed())
+
+		_, err := repository.Get("0001")
+		Expect(err).To(MatchError("missing badge"))
+		mock.MockTransactionStart("list")
+
+		assets, err := repository.List()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(assets).To(HaveLen(1))
+	})
+
+	It("Should stop iterating at the first error", func() {
+		repository := NewRepository(mock, logger, userInfoDefinition)
+		Expect(repository.Put(model.NewUserInfo("0000", "someone", "", ""))).To(Succeed())
+		Expect(repository.Put(model.NewUserInfo("0001", "anyone", "", ""))).To(Succeed())
+		mock.MockTransactionStart("iterate")
+
+		visited := 0
+		err := repository.Iterate(func(asset Asset) error {
+			visited++
+			return errors.New("stop")
+		})
+		Expect(err).To(MatchError("stop"))
+		Expect(visited).To(Equal(1))
+	})
+})
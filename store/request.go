@@ -0,0 +1,83 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/cdtlab19/coffee-chaincode/model"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// requestDefinition describes how processed client requests are stored
+var requestDefinition = Definition{
+	DocType: model.RequestDocType,
+	Key: func(asset Asset) []string {
+		return []string{asset.(*model.Request).Key()}
+	},
+	Decode: func(data []byte) (Asset, error) {
+		request := &model.Request{}
+		return request, json.Unmarshal(data, request)
+	},
+}
+
+// RequestStore abstracts the persistence of processed client requests
+type RequestStore struct {
+	repository *Repository
+	logger     Logger
+}
+
+// NewRequestStore creates a new request Store
+func NewRequestStore(stub shim.ChaincodeStubInterface, logger Logger) *RequestStore {
+	return &RequestStore{NewRepository(stub, logger, requestDefinition), logger}
+}
+
+// GetRequest returns a processed request by it's creator and client request
+// ID
+func (r *RequestStore) GetRequest(creator, requestID string) (*model.Request, error) {
+	asset, err := r.repository.Get(model.RequestKey(creator, requestID))
+	if err != nil {
+		return nil, err
+	}
+	return asset.(*model.Request), nil
+}
+
+// SetRequest validates and stores a processed request
+func (r *RequestStore) SetRequest(request *model.Request) error {
+	return r.repository.Put(request)
+}
+
+// expired verifies if a stored request was recorded longer than `expiry`
+// before `now`
+func (r *RequestStore) expired(data []byte, expiry time.Duration, now time.Time) (bool, error) {
+	asset, err := r.repository.decode(data)
+	if err != nil {
+		return false, err
+	}
+	return asset.(*model.Request).Expired(now, expiry), nil
+}
+
+// ExpiredRequests lists the keys of a page of requests recorded longer than
+// `expiry` before `now`, starting at `bookmark`. It runs
+// a paginated query, so it must only be called by queries
+func (r *RequestStore) ExpiredRequests(pageSize int, bookmark string, expiry time.Duration, now time.Time) (*ScanResult, error) {
+	r.logger.Debugf("ExpiredRequests: scanning %d requests at '%s'", pageSize, bookmark)
+
+	return r.repository.Scan(pageSize, bookmark, func(key string, data []byte) (bool, error) {
+		return r.expired(data, expiry, now)
+	})
+}
+
+// ExpireRequests deletes the requests with the listed keys, usually returned
+// by ExpiredRequests, which are still recorded longer than `expiry` before
+// `now`
+func (r *RequestStore) ExpireRequests(keys []string, expiry time.Duration, now time.Time) (*RewriteResult, error) {
+	r.logger.Debugf("ExpireRequests: expiring %d requests", len(keys))
+
+	return r.repository.Rewrite(keys, func(key string, data []byte) (bool, error) {
+		expired, err := r.expired(data, expiry, now)
+		if err != nil || !expired {
+			return false, err
+		}
+		return true, r.repository.Delete(key)
+	})
+}
@@ -0,0 +1,73 @@
+package store_test
+
+import (
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cdtlab19/coffee-chaincode/mockstub"
+	"github.com/cdtlab19/coffee-chaincode/model"
+	. "github.com/cdtlab19/coffee-chaincode/store"
+)
+
+var _ = Describe("RequestStore", func() {
+	var mock *mockstub.Stub
+	var st *RequestStore
+
+	now := time.Date(2019, 5, 2, 12, 0, 0, 0, time.UTC)
+	response := pb.Response{Status: 200, Payload: []byte(`{}`)}
+
+	BeforeEach(func() {
+		logger := shim.NewLogger("request-test")
+		mock = mockstub.NewStub("request", nil)
+		st = NewRequestStore(mock, logger)
+		mock.MockTransactionStart("test")
+	})
+
+	AfterEach(func() {
+		mock.MockTransactionEnd("test")
+	})
+
+	It("Should store and return requests", func() {
+		request := model.NewRequest("Org1MSP/someone", "req-1", "CreateCoffee", []string{"mocha"}, nil,
+			now, response)
+		Expect(st.SetRequest(request)).To(Succeed())
+
+		stored, err := st.GetRequest("Org1MSP/someone", "req-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stored).To(Equal(request))
+
+		_, err = st.GetRequest("Org1MSP/someone", "req-2")
+		Expect(IsNotFound(err)).To(BeTrue())
+		_, err = st.GetRequest("Org2MSP/someone", "req-1")
+		Expect(IsNotFound(err)).To(BeTrue())
+	})
+
+	It("Should expire only requests older than the expiry", func() {
+		Expect(st.SetRequest(model.NewRequest("Org1MSP/someone", "old", "CreateCoffee", nil, nil,
+			now.Add(-2*time.Hour), response))).To(Succeed())
+		Expect(st.SetRequest(model.NewRequest("Org1MSP/someone", "new", "CreateCoffee", nil, nil,
+			now.Add(-30*time.Minute), response))).To(Succeed())
+		mock.MockTransactionStart("scan")
+
+		scan, err := st.ExpiredRequests(10, "", time.Hour, now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(scan.Scanned).To(Equal(2))
+		Expect(scan.IDs).To(Equal([]string{model.RequestKey("Org1MSP/someone", "old")}))
+		Expect(scan.Done).To(BeTrue())
+		mock.MockTransactionStart("expire")
+
+		result, err := st.ExpireRequests(append(scan.IDs, model.RequestKey("Org1MSP/someone", "new")),
+			time.Hour, now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(&RewriteResult{Changed: 1, Unchanged: 1}))
+
+		_, err = st.GetRequest("Org1MSP/someone", "old")
+		Expect(IsNotFound(err)).To(BeTrue())
+		_, err = st.GetRequest("Org1MSP/someone", "new")
+		Expect(err).NotTo(HaveOccurred())
+	})
+})
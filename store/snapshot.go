@@ -0,0 +1,498 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/ledger/queryresult"
+)
+
+// SnapshotFormat identifies snapshots in their header
+const SnapshotFormat = "coffee-chaincode-snapshot"
+
+// SnapshotVersion is the current version of the snapshot format
+const SnapshotVersion = 1
+
+// SnapshotHeader is the first line of a snapshot page
+type SnapshotHeader struct {
+	Format    string `json:"format"`
+	Version   int    `json:"version"`
+	Chaincode string `json:"chaincode"`
+	// Bookmark is the bookmark this page was exported after, empty in the
+	// first page
+	Bookmark string `json:"bookmark"`
+	// Next is the bookmark which must be sent to export the next page
+	Next string `json:"next"`
+	// Done is true in the last page
+	Done bool `json:"done"`
+	// Entries is the number of entries in this page
+	Entries int `json:"entries"`
+}
+
+// SnapshotEntry is a key and it's value, one per line after the header.
+// Documents are kept as JSON, while the values of index entries, which are
+// the keys of the indexed assets, are encoded as JSON strings
+type SnapshotEntry struct {
+	Collection string          `json:"collection,omitempty"`
+	ObjectType string          `json:"objectType"`
+	Attributes []string        `json:"attributes"`
+	Value      json.RawMessage `json:"value"`
+	// ValidationParameter is the key-level endorsement policy of a document,
+	// if any
+	ValidationParameter []byte `json:"validationParameter,omitempty"`
+}
+
+// ImportResult reports how many entries of a snapshot page were imported
+type ImportResult struct {
+	Imported int  `json:"imported"`
+	Done     bool `json:"done"`
+}
+
+// snapshotSource is a set of keys with the same object type included in
+// snapshots
+type snapshotSource struct {
+	def Definition
+	// objectType is the definition's DocType, or the name of one of it's
+	// indexes
+	objectType string
+	// replace allows importing over an existing key, such as the
+	// configuration stored by Init
+	replace bool
+}
+
+func (s snapshotSource) index() bool {
+	return s.objectType != s.def.DocType
+}
+
+// documents returns the source of the documents of a definition and all it's
+// indexes
+func documents(def Definition) []snapshotSource {
+	sources := []snapshotSource{{def: def, objectType: def.DocType}}
+	for _, index := range def.Indexes {
+		sources = append(sources, snapshotSource{def: def, objectType: index.Name})
+	}
+	return sources
+}
+
+// Snapshot exports and imports all the keys of a chaincode as JSON lines, a
+// page at a time
+type Snapshot struct {
+	stub      shim.ChaincodeStubInterface
+	logger    Logger
+	chaincode string
+	sources   []snapshotSource
+}
+
+// NewCoffeeSnapshot creates the Snapshot of the coffee chaincode, with the
+// coffees, their indexes, the configuration and the client requests
+func NewCoffeeSnapshot(stub shim.ChaincodeStubInterface, logger Logger) *Snapshot {
+	sources := append(documents(coffeeDefinition),
+		snapshotSource{def: configDefinition, objectType: configDefinition.DocType, replace: true})
+	sources = append(sources, documents(requestDefinition)...)
+	return &Snapshot{stub, logger, "coffee", sources}
+}
+
+// NewUserSnapshot creates the Snapshot of the user chaincode, with the users,
+// their personal information, the payments and their key, the configuration
+// and the client requests
+func NewUserSnapshot(stub shim.ChaincodeStubInterface, logger Logger) *Snapshot {
+	sources := append(documents(userDefinition), documents(userInfoDefinition)...)
+	sources = append(sources, documents(paymentDefinition)...)
+	sources = append(sources, documents(paymentKeyDefinition)...)
+	sources = append(sources,
+		snapshotSource{def: configDefinition, objectType: configDefinition.DocType, replace: true})
+	sources = append(sources, documents(requestDefinition)...)
+	return &Snapshot{stub, logger, "user", sources}
+}
+
+// NewCombinedSnapshot creates the Snapshot of the combined chaincode, with the
+// keys of both chaincodes. The configuration and the client requests are
+// shared by both, so they're included once
+func NewCombinedSnapshot(stub shim.ChaincodeStubInterface, logger Logger) *Snapshot {
+	sources := append(documents(coffeeDefinition), documents(userDefinition)...)
+	sources = append(sources, documents(userInfoDefinition)...)
+	sources = append(sources, documents(paymentDefinition)...)
+	sources = append(sources, documents(paymentKeyDefinition)...)
+	sources = append(sources,
+		snapshotSource{def: configDefinition, objectType: configDefinition.DocType, replace: true})
+	sources = append(sources, documents(requestDefinition)...)
+	return &Snapshot{stub, logger, "combined", sources}
+}
+
+// encodeBookmark encodes the position of the next key to export, which is
+// opaque to clients
+func encodeBookmark(source int, key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%s", source, key)))
+}
+
+func decodeBookmark(bookmark string) (int, string, error) {
+	if bookmark == "" {
+		return 0, "", nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(bookmark)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid bookmark '%s'", bookmark)
+	}
+
+	parts := strings.SplitN(string(data), ":", 2)
+	source, err := strconv.Atoi(parts[0])
+	if err != nil || len(parts) != 2 {
+		return 0, "", fmt.Errorf("invalid bookmark '%s'", bookmark)
+	}
+	return source, parts[1], nil
+}
+
+// Export returns a page of up to `pageSize` entries starting at `bookmark`,
+// as a header line followed by one line per entry
+func (s *Snapshot) Export(pageSize int, bookmark string) ([]byte, error) {
+	s.logger.Debugf("Export: exporting %d entries at '%s'", pageSize, bookmark)
+
+	if pageSize < 1 {
+		return nil, fmt.Errorf("invalid page size %d", pageSize)
+	}
+
+	first, start, err := decodeBookmark(bookmark)
+	if err != nil {
+		return nil, err
+	}
+
+	header := SnapshotHeader{
+		Format:    SnapshotFormat,
+		Version:   SnapshotVersion,
+		Chaincode: s.chaincode,
+		Bookmark:  bookmark,
+		Next:      bookmark,
+	}
+	entries := []SnapshotEntry{}
+
+	for i := first; i < len(s.sources) && len(entries) < pageSize; i++ {
+		if i > first {
+			start = ""
+		}
+
+		page, next, err := s.export(i, start, pageSize-len(entries))
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, page...)
+		if next != "" {
+			header.Next = encodeBookmark(i, next)
+		} else {
+			header.Next = encodeBookmark(i+1, "")
+		}
+	}
+
+	header.Entries = len(entries)
+	header.Done, err = s.exhausted(header.Next)
+	if err != nil {
+		return nil, err
+	}
+
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	if err := encoder.Encode(header); err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return nil, err
+		}
+	}
+	return buffer.Bytes(), nil
+}
+
+// export returns up to `limit` entries of a source starting at the bookmark
+// `start`, and the bookmark of the next page of the source, or "" if there
+// are no more entries
+func (s *Snapshot) export(i int, start string, limit int) ([]SnapshotEntry, string, error) {
+	source := s.sources[i]
+	repository := NewRepository(s.stub, s.logger, source.def)
+
+	entries := []SnapshotEntry{}
+	next, err := repository.page(source.objectType, limit, start, func(kv *queryresult.KV) error {
+		objectType, attributes, err := s.stub.SplitCompositeKey(kv.GetKey())
+		if err != nil {
+			return err
+		}
+
+		value := json.RawMessage(kv.GetValue())
+		if source.index() {
+			if value, err = json.Marshal(string(kv.GetValue())); err != nil {
+				return err
+			}
+		}
+
+		ep, err := s.validationParameter(source, kv.GetKey())
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, SnapshotEntry{source.def.Collection, objectType, attributes, value, ep})
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return entries, next, nil
+}
+
+// validationParameter returns the key-level endorsement policy of a
+// document, which index entries don't have
+func (s *Snapshot) validationParameter(source snapshotSource, key string) ([]byte, error) {
+	if source.index() {
+		return nil, nil
+	}
+	if source.def.Collection != "" {
+		return s.stub.GetPrivateDataValidationParameter(source.def.Collection, key)
+	}
+	return s.stub.GetStateValidationParameter(key)
+}
+
+// exhausted verifies if there are no entries at or after a bookmark
+func (s *Snapshot) exhausted(bookmark string) (bool, error) {
+	first, start, err := decodeBookmark(bookmark)
+	if err != nil {
+		return false, err
+	}
+
+	for i := first; i < len(s.sources); i++ {
+		if i > first {
+			start = ""
+		}
+
+		entries, _, err := s.export(i, start, 1)
+		if err != nil {
+			return false, err
+		}
+		if len(entries) > 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// empty verifies if there are no public keys, other than replaceable ones.
+// Import writes after this check, and Fabric doesn't allow writes after
+// paginated or private data queries, so it reads the first key of each
+// public source with a plain query. Private keys are checked one at a time
+// by put, since they're never overwritten
+func (s *Snapshot) empty() (bool, error) {
+	for _, source := range s.sources {
+		if source.replace || source.def.Collection != "" {
+			continue
+		}
+
+		iterator, err := s.stub.GetStateByPartialCompositeKey(source.objectType, []string{})
+		if err != nil {
+			return false, err
+		}
+		found := iterator.HasNext()
+		iterator.Close()
+
+		if found {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// source returns the source of an entry
+func (s *Snapshot) source(entry SnapshotEntry) (snapshotSource, error) {
+	for _, source := range s.sources {
+		if source.def.Collection == entry.Collection && source.objectType == entry.ObjectType {
+			return source, nil
+		}
+	}
+	return snapshotSource{}, fmt.Errorf("unknown object type '%s'", entry.ObjectType)
+}
+
+// value validates an entry, returning the value which must be stored
+func (s *Snapshot) value(source snapshotSource, entry SnapshotEntry) ([]byte, error) {
+	repository := NewRepository(s.stub, s.logger, source.def)
+
+	if !source.index() {
+		asset, err := repository.decode(entry.Value)
+		if err != nil {
+			return nil, err
+		}
+		if strings.Join(source.def.Key(asset), ":") != strings.Join(entry.Attributes, ":") {
+			return nil, fmt.Errorf("key doesn't match the %s", source.def.DocType)
+		}
+		return entry.Value, nil
+	}
+
+	var key string
+	if err := json.Unmarshal(entry.Value, &key); err != nil {
+		return nil, err
+	}
+
+	prefix, err := s.stub.CreateCompositeKey(source.def.DocType, []string{})
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(key, prefix) {
+		return nil, fmt.Errorf("index entry doesn't reference a %s", source.def.DocType)
+	}
+	return []byte(key), nil
+}
+
+// Import validates and stores a snapshot page exported by Export. The entries
+// of private collections must be sent apart from the page, in `private`, so
+// they aren't recorded in the transaction, as split by SplitSnapshot. The
+// first page may only be imported into an empty chaincode, and existing keys
+// are never overwritten, except for the configuration
+func (s *Snapshot) Import(data, private []byte) (*ImportResult, error) {
+	scanner := snapshotScanner(data)
+
+	if !scanner.Scan() {
+		return nil, fmt.Errorf("missing snapshot header")
+	}
+
+	var header SnapshotHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return nil, fmt.Errorf("invalid snapshot header: %s", err.Error())
+	}
+	if header.Format != SnapshotFormat || header.Version != SnapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot format '%s' version %d", header.Format, header.Version)
+	}
+	if header.Chaincode != s.chaincode {
+		return nil, fmt.Errorf("snapshot of the %s chaincode can't be imported into the %s chaincode",
+			header.Chaincode, s.chaincode)
+	}
+
+	if header.Bookmark == "" {
+		empty, err := s.empty()
+		if err != nil {
+			return nil, err
+		}
+		if !empty {
+			return nil, fmt.Errorf("snapshots may only be imported into an empty chaincode")
+		}
+	}
+
+	result := &ImportResult{Done: header.Done}
+	if err := s.importEntries(scanner, "line", 2, false, result); err != nil {
+		return nil, err
+	}
+	if err := s.importEntries(snapshotScanner(private), "private line", 1, true, result); err != nil {
+		return nil, err
+	}
+
+	if result.Imported != header.Entries {
+		return nil, fmt.Errorf("expected %d entries, but found %d", header.Entries, result.Imported)
+	}
+	return result, nil
+}
+
+func snapshotScanner(data []byte) *bufio.Scanner {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), len(data)+1)
+	return scanner
+}
+
+// importEntries stores the remaining entries of a scanner, which must all be
+// either private or public
+func (s *Snapshot) importEntries(scanner *bufio.Scanner, name string, line int, private bool,
+	result *ImportResult) error {
+	for ; scanner.Scan(); line++ {
+		var entry SnapshotEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return fmt.Errorf("%s %d: %s", name, line, err.Error())
+		}
+
+		if private && entry.Collection == "" {
+			return fmt.Errorf("%s %d: public entries must be sent in the snapshot page", name, line)
+		}
+		if !private && entry.Collection != "" {
+			return fmt.Errorf("%s %d: entries of private collections must be sent apart from the snapshot page",
+				name, line)
+		}
+
+		if err := s.put(entry); err != nil {
+			return fmt.Errorf("%s %d: %s", name, line, err.Error())
+		}
+		result.Imported++
+	}
+	return scanner.Err()
+}
+
+// SplitSnapshot splits a page exported by Export into the header and the
+// public entries, and the entries of private collections, which must be sent
+// to Import apart from the page
+func SplitSnapshot(page []byte) ([]byte, []byte, error) {
+	scanner := snapshotScanner(page)
+
+	var public, private bytes.Buffer
+	for line := 1; scanner.Scan(); line++ {
+		if line > 1 {
+			var entry SnapshotEntry
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				return nil, nil, fmt.Errorf("line %d: %s", line, err.Error())
+			}
+
+			if entry.Collection != "" {
+				private.Write(scanner.Bytes())
+				private.WriteByte('\n')
+				continue
+			}
+		}
+
+		public.Write(scanner.Bytes())
+		public.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	return public.Bytes(), private.Bytes(), nil
+}
+
+// put validates and stores an entry
+func (s *Snapshot) put(entry SnapshotEntry) error {
+	source, err := s.source(entry)
+	if err != nil {
+		return err
+	}
+
+	value, err := s.value(source, entry)
+	if err != nil {
+		return err
+	}
+
+	key, err := s.stub.CreateCompositeKey(entry.ObjectType, entry.Attributes)
+	if err != nil {
+		return err
+	}
+
+	repository := NewRepository(s.stub, s.logger, source.def)
+	if !source.replace {
+		existing, err := repository.getState(key)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return &AlreadyExistsError{entry.ObjectType, strings.Join(entry.Attributes, ":")}
+		}
+	}
+	if err := repository.putState(key, value); err != nil {
+		return err
+	}
+
+	if len(entry.ValidationParameter) == 0 {
+		return nil
+	}
+	if source.index() {
+		return fmt.Errorf("index entries can't have endorsement policies")
+	}
+	if source.def.Collection != "" {
+		return s.stub.SetPrivateDataValidationParameter(source.def.Collection, key, entry.ValidationParameter)
+	}
+	return s.stub.SetStateValidationParameter(key, entry.ValidationParameter)
+}
@@ -0,0 +1,195 @@
+package store_test
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cdtlab19/coffee-chaincode/mockstub"
+	"github.com/cdtlab19/coffee-chaincode/model"
+	. "github.com/cdtlab19/coffee-chaincode/store"
+)
+
+var _ = Describe("Snapshot", func() {
+	var source, target *mockstub.Stub
+	var logger *shim.ChaincodeLogger
+
+	BeforeEach(func() {
+		logger = shim.NewLogger("snapshot-test")
+		source = mockstub.NewStub("source", nil)
+		target = mockstub.NewStub("target", nil)
+		source.MockTransactionStart("source")
+		target.MockTransactionStart("target")
+	})
+
+	AfterEach(func() {
+		source.MockTransactionEnd("source")
+		target.MockTransactionEnd("target")
+	})
+
+	header := func(page []byte) SnapshotHeader {
+		var header SnapshotHeader
+		line := bytes.SplitN(page, []byte("\n"), 2)[0]
+		Expect(json.Unmarshal(line, &header)).To(Succeed())
+		return header
+	}
+
+	// copySnapshot exports all pages of a snapshot and imports them in order,
+	// each in it's own transaction
+	copySnapshot := func(from, to *Snapshot, pageSize int) int {
+		pages, bookmark := 0, ""
+		for {
+			source.MockTransactionStart("export")
+			target.MockTransactionStart("import")
+
+			page, err := from.Export(pageSize, bookmark)
+			Expect(err).NotTo(HaveOccurred())
+			pages++
+
+			h := header(page)
+			Expect(h.Entries).To(BeNumerically("<=", pageSize))
+
+			public, private, err := SplitSnapshot(page)
+			Expect(err).NotTo(HaveOccurred())
+
+			result, err := to.Import(public, private)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Imported).To(Equal(h.Entries))
+
+			if h.Done {
+				return pages
+			}
+			bookmark = h.Next
+		}
+	}
+
+	It("Should round-trip the coffee chaincode state in pages", func() {
+		coffees := NewCoffeeStore(source, logger)
+		for _, id := range []string{"c1", "c2", "c3"} {
+			Expect(coffees.CreateCoffee(model.NewCoffee(id, "mocha"))).To(Succeed())
+		}
+		used := model.NewCoffee("c4", "latte")
+		Expect(used.SetOwner("u1")).To(Succeed())
+		Expect(coffees.CreateCoffee(used)).To(Succeed())
+
+		config := model.NewConfig()
+		config.DefaultCredits = 3
+		Expect(NewConfigStore(source, logger).SetConfig(config)).To(Succeed())
+		Expect(NewConfigStore(target, logger).SetConfig(model.NewConfig())).To(Succeed())
+
+		pages := copySnapshot(NewCoffeeSnapshot(source, logger), NewCoffeeSnapshot(target, logger), 3)
+		Expect(pages).To(BeNumerically(">", 1))
+		Expect(target.State).To(Equal(source.State))
+
+		report, err := NewCoffeeStore(target, logger).VerifyCoffeeIndexes(false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Consistent()).To(BeTrue())
+	})
+
+	It("Should round-trip the user chaincode state with personal information", func() {
+		users := NewUserStore(source, logger)
+		info := model.NewUserInfo("u1", "someone", "someone@example.com", "")
+		user := model.NewUser("u1", 3)
+		user.SetInfo(info)
+		Expect(users.CreateUser(user)).To(Succeed())
+		Expect(users.SetUserInfo(info)).To(Succeed())
+		Expect(users.SetUserEndorsement("u1", "Org1MSP", "Org2MSP")).To(Succeed())
+
+		copySnapshot(NewUserSnapshot(source, logger), NewUserSnapshot(target, logger), 10)
+		Expect(target.State).To(Equal(source.State))
+		Expect(target.PvtState).To(Equal(source.PvtState))
+		Expect(target.EndorsementPolicies).To(Equal(source.EndorsementPolicies))
+	})
+
+	It("Should only import private entries apart from the page", func() {
+		users := NewUserStore(source, logger)
+		info := model.NewUserInfo("u1", "someone", "someone@example.com", "")
+		user := model.NewUser("u1", 3)
+		user.SetInfo(info)
+		Expect(users.CreateUser(user)).To(Succeed())
+		Expect(users.SetUserInfo(info)).To(Succeed())
+		source.MockTransactionStart("export")
+
+		page, err := NewUserSnapshot(source, logger).Export(10, "")
+		Expect(err).NotTo(HaveOccurred())
+
+		public, private, err := SplitSnapshot(page)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(public)).NotTo(ContainSubstring("someone@example.com"))
+		Expect(string(private)).To(ContainSubstring("someone@example.com"))
+
+		_, err = NewUserSnapshot(target, logger).Import(page, nil)
+		Expect(err).To(MatchError(ContainSubstring("must be sent apart from the snapshot page")))
+
+		other := mockstub.NewStub("other", nil)
+		other.MockTransactionStart("other")
+		defer other.MockTransactionEnd("other")
+
+		_, err = NewUserSnapshot(other, logger).Import(public, public)
+		Expect(err).To(MatchError(ContainSubstring("public entries must be sent in the snapshot page")))
+	})
+
+	It("Should export an empty last page", func() {
+		page, err := NewCoffeeSnapshot(source, logger).Export(10, "")
+		Expect(err).NotTo(HaveOccurred())
+
+		h := header(page)
+		Expect(h.Format).To(Equal(SnapshotFormat))
+		Expect(h.Version).To(Equal(SnapshotVersion))
+		Expect(h.Chaincode).To(Equal("coffee"))
+		Expect(h.Entries).To(Equal(0))
+		Expect(h.Done).To(BeTrue())
+	})
+
+	It("Should only import into an empty chaincode", func() {
+		Expect(NewCoffeeStore(source, logger).CreateCoffee(model.NewCoffee("c1", "mocha"))).To(Succeed())
+		Expect(NewCoffeeStore(target, logger).CreateCoffee(model.NewCoffee("c2", "mocha"))).To(Succeed())
+		source.MockTransactionStart("export")
+
+		page, err := NewCoffeeSnapshot(source, logger).Export(10, "")
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = NewCoffeeSnapshot(target, logger).Import(page, nil)
+		Expect(err).To(MatchError(ContainSubstring("empty chaincode")))
+	})
+
+	It("Should reject snapshots of other chaincodes and versions", func() {
+		page, err := NewUserSnapshot(source, logger).Export(10, "")
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = NewCoffeeSnapshot(target, logger).Import(page, nil)
+		Expect(err).To(MatchError(ContainSubstring("can't be imported into the coffee chaincode")))
+
+		_, err = NewUserSnapshot(target, logger).Import(
+			[]byte(`{"format":"coffee-chaincode-snapshot","version":99,"chaincode":"user"}`), nil)
+		Expect(err).To(MatchError(ContainSubstring("unsupported snapshot")))
+	})
+
+	It("Should reject invalid entries", func() {
+		snapshot := NewCoffeeSnapshot(target, logger)
+		h := `{"format":"coffee-chaincode-snapshot","version":1,"chaincode":"coffee","entries":1}` + "\n"
+
+		_, err := snapshot.Import([]byte(h+`{"objectType":"coffee","attributes":["c1"],"value":{"docType":"coffee","id":"c1"}}`), nil)
+		Expect(err).To(MatchError(ContainSubstring("line 2")))
+
+		_, err = snapshot.Import([]byte(h+`{"objectType":"coffee","attributes":["c2"],"value":{"docType":"coffee","id":"c1","flavour":"mocha"}}`), nil)
+		Expect(err).To(MatchError(ContainSubstring("key doesn't match")))
+
+		_, err = snapshot.Import([]byte(h+`{"objectType":"unknown","attributes":[],"value":{}}`), nil)
+		Expect(err).To(MatchError(ContainSubstring("unknown object type")))
+
+		_, err = snapshot.Import([]byte(h+`{"objectType":"owner~id","attributes":["u1","c1"],"value":"user"}`), nil)
+		Expect(err).To(MatchError(ContainSubstring("doesn't reference a coffee")))
+
+		_, err = snapshot.Import([]byte(h), nil)
+		Expect(err).To(MatchError(ContainSubstring("expected 1 entries")))
+	})
+
+	It("Should reject invalid bookmarks", func() {
+		_, err := NewCoffeeSnapshot(source, logger).Export(10, "not a bookmark")
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -2,51 +2,110 @@ package store
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/cdtlab19/coffee-chaincode/model"
 	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/core/chaincode/shim/ext/statebased"
 )
 
+// UserInfoCollection is the private data collection where user's personal
+// information is stored
+const UserInfoCollection = "collectionUserInfo"
+
+// userDefinition describes how user assets are stored
+var userDefinition = Definition{
+	DocType: model.UserDocType,
+	Key: func(asset Asset) []string {
+		return []string{asset.(*model.User).ID}
+	},
+	Decode: func(data []byte) (Asset, error) {
+		user, _, err := decodeUser(data)
+		return user, err
+	},
+}
+
+// userInfoDefinition describes how users' personal information is stored
+var userInfoDefinition = Definition{
+	DocType:    model.UserInfoDocType,
+	Collection: UserInfoCollection,
+	Key: func(asset Asset) []string {
+		return []string{asset.(*model.UserInfo).ID}
+	},
+	Decode: func(data []byte) (Asset, error) {
+		info := &model.UserInfo{}
+		return info, json.Unmarshal(data, info)
+	},
+}
+
+// paymentDefinition describes how purchases of credits are stored, in the
+// same collection as the personal information
+var paymentDefinition = Definition{
+	DocType:    model.PaymentDocType,
+	Collection: UserInfoCollection,
+	Key: func(asset Asset) []string {
+		return []string{asset.(*model.Payment).ID}
+	},
+	Decode: func(data []byte) (Asset, error) {
+		payment := &model.Payment{}
+		return payment, json.Unmarshal(data, payment)
+	},
+}
+
+// paymentKeyDefinition describes how the secret key of the payments' IDs is
+// stored, along with the payments. It has a single instance, so it's key has
+// no attributes
+var paymentKeyDefinition = Definition{
+	DocType:    model.PaymentKeyDocType,
+	Collection: UserInfoCollection,
+	Key: func(asset Asset) []string {
+		return []string{}
+	},
+	Decode: func(data []byte) (Asset, error) {
+		key := &model.PaymentKey{}
+		return key, json.Unmarshal(data, key)
+	},
+}
+
 // UserStore abstracts user CRUD methods
 type UserStore struct {
-	stub   shim.ChaincodeStubInterface
-	logger *shim.ChaincodeLogger
+	stub     shim.ChaincodeStubInterface
+	logger   Logger
+	users    *Repository
+	infos    *Repository
+	payments *Repository
+	keys     *Repository
 }
 
 // NewUserStore creates a new user Store
-func NewUserStore(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger) *UserStore {
-	return &UserStore{stub, logger}
-}
-
-func (u *UserStore) newUserKey(id string) (key string) {
-	key, _ = u.stub.CreateCompositeKey(model.UserDocType, []string{id})
-	return
+func NewUserStore(stub shim.ChaincodeStubInterface, logger Logger) *UserStore {
+	return &UserStore{
+		stub:     stub,
+		logger:   logger,
+		users:    NewRepository(stub, logger, userDefinition),
+		infos:    NewRepository(stub, logger, userInfoDefinition),
+		payments: NewRepository(stub, logger, paymentDefinition),
+		keys:     NewRepository(stub, logger, paymentKeyDefinition),
+	}
 }
 
-// AllUser returns all existing users
-func (u *UserStore) AllUser() ([]*model.User, error) {
+// AllUser returns all existing users. Deleted users are only returned if
+// `includeDeleted` is set
+func (u *UserStore) AllUser(includeDeleted bool) ([]*model.User, error) {
 	u.logger.Debug("Entered AllUser")
-	iterator, err := u.stub.GetStateByPartialCompositeKey(model.UserDocType, []string{})
-	if err != nil {
-		return nil, err
-	}
-	defer iterator.Close()
 
-	u.logger.Debug("AllUser: starting iterator")
 	users := []*model.User{}
-	for iterator.HasNext() {
-		k, err := iterator.Next()
-		if err != nil {
-			return nil, err
+	err := u.users.Iterate(func(asset Asset) error {
+		user := asset.(*model.User)
+		if user.IsDeleted() && !includeDeleted {
+			return nil
 		}
-
-		user := &model.User{}
-		if err := json.Unmarshal(k.GetValue(), &user); err != nil {
-			return nil, err
-		}
-
 		u.logger.Debugf("AllUsers: element with ID '%s' found", user.ID)
 		users = append(users, user)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	u.logger.Debug("Exiting AllUsers")
@@ -54,26 +113,200 @@ func (u *UserStore) AllUser() ([]*model.User, error) {
 }
 
 // GetUser returns an user by it's ID
-func (u *UserStore) GetUser(userID string) (user *model.User, err error) {
-	u.logger.Debug("GetUser: searching for user '%s'", userID)
-
-	data, err := u.stub.GetState(u.newUserKey(userID))
+func (u *UserStore) GetUser(userID string) (*model.User, error) {
+	asset, err := u.users.Get(userID)
 	if err != nil {
 		return nil, err
 	}
+	return asset.(*model.User), nil
+}
+
+// CreateUser validates and stores a new user asset, failing if it already
+// exists
+func (u *UserStore) CreateUser(user *model.User) error {
+	return u.users.Create(user)
+}
 
-	err = json.Unmarshal(data, &user)
-	return
+// UpdateUser validates and stores an existing user asset, failing if it
+// doesn't exist
+func (u *UserStore) UpdateUser(user *model.User) error {
+	return u.users.Update(user)
 }
 
-// SetUser sets an user asset by it's ID
+// SetUser validates and sets an user asset by it's ID, whether it exists or
+// not
 func (u *UserStore) SetUser(user *model.User) error {
-	u.logger.Debug("SetUser: setting user %s", user.ID)
-	return u.stub.PutState(u.newUserKey(user.ID), user.JSON())
+	return u.users.Put(user)
+}
+
+// DeleteUser marks an existing user asset as deleted with a tombstone. It's
+// private information is kept until the user is purged
+func (u *UserStore) DeleteUser(userID string, tombstone *model.Tombstone) error {
+	return u.users.SoftDelete(tombstone, userID)
+}
+
+// RestoreUser removes the tombstone of a deleted user asset
+func (u *UserStore) RestoreUser(userID string) error {
+	return u.users.Restore(userID)
+}
+
+// PurgeUser erases an user asset deleted for longer than `retention`, and
+// it's private information
+func (u *UserStore) PurgeUser(userID string, retention time.Duration, now time.Time) error {
+	if err := u.users.Purge(retention, now, userID); err != nil {
+		return err
+	}
+
+	exists, err := u.infos.Exists(userID)
+	if err != nil || !exists {
+		return err
+	}
+	return u.infos.Delete(userID)
+}
+
+// GetUserInfo returns an user's private information by it's ID
+func (u *UserStore) GetUserInfo(userID string) (*model.UserInfo, error) {
+	asset, err := u.infos.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+	return asset.(*model.UserInfo), nil
+}
+
+// SetUserInfo validates and sets an user's private information by it's ID
+func (u *UserStore) SetUserInfo(info *model.UserInfo) error {
+	return u.infos.Put(info)
+}
+
+// GetPaymentKey returns the secret key of the payments' IDs, failing with
+// NotFoundError if it wasn't set yet
+func (u *UserStore) GetPaymentKey() (*model.PaymentKey, error) {
+	asset, err := u.keys.Get()
+	if err != nil {
+		return nil, err
+	}
+	return asset.(*model.PaymentKey), nil
+}
+
+// SetPaymentKey validates and stores the secret key of the payments' IDs,
+// failing if it was already set, since the IDs of the recorded payments
+// depend on it
+func (u *UserStore) SetPaymentKey(key *model.PaymentKey) error {
+	return u.keys.Create(key)
+}
+
+// GetPayment returns a purchase of credits by it's payment reference,
+// including payments recorded before the payment key was set
+func (u *UserStore) GetPayment(reference string) (*model.Payment, error) {
+	key, err := u.GetPaymentKey()
+	if err != nil {
+		return nil, err
+	}
+
+	asset, err := u.payments.Get(key.PaymentID(reference))
+	if IsNotFound(err) {
+		asset, err = u.payments.Get(model.LegacyPaymentID(reference))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return asset.(*model.Payment), nil
+}
+
+// CreatePayment validates and stores a purchase of credits, failing if it's
+// reference was already used, even before the payment key was set
+func (u *UserStore) CreatePayment(payment *model.Payment) error {
+	legacy, err := u.payments.Exists(model.LegacyPaymentID(payment.Reference))
+	if err != nil {
+		return err
+	}
+	if legacy {
+		return &AlreadyExistsError{model.PaymentDocType, model.LegacyPaymentID(payment.Reference)}
+	}
+	return u.payments.Create(payment)
+}
+
+// SetUserEndorsement requires the user asset to be endorsed by the peers of
+// the given organizations
+func (u *UserStore) SetUserEndorsement(userID string, orgs ...string) error {
+	u.logger.Debugf("SetUserEndorsement: setting user %s endorsers to %v", userID, orgs)
+
+	ep, err := statebased.NewStateEP(nil)
+	if err != nil {
+		return err
+	}
+
+	if err := ep.AddOrgs(statebased.RoleTypePeer, orgs...); err != nil {
+		return err
+	}
+
+	policy, err := ep.Policy()
+	if err != nil {
+		return err
+	}
+
+	return u.stub.SetStateValidationParameter(u.users.Key(userID), policy)
 }
 
-// DeleteUser deletes an user asset by it's ID
-func (u *UserStore) DeleteUser(userID string) error {
-	u.logger.Debug("DeleteUser: deleting user %s", userID)
-	return u.stub.DelState(u.newUserKey(userID))
+// GetUserEndorsement returns the organizations required to endorse an user
+// asset
+func (u *UserStore) GetUserEndorsement(userID string) ([]string, error) {
+	policy, err := u.stub.GetStateValidationParameter(u.users.Key(userID))
+	if err != nil {
+		return nil, err
+	}
+
+	ep, err := statebased.NewStateEP(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	return ep.ListOrgs(), nil
+}
+
+// PendingUserMigrations lists a page of user assets stored with older
+// schema versions, starting at `bookmark`
+func (u *UserStore) PendingUserMigrations(pageSize int, bookmark string) (*ScanResult, error) {
+	u.logger.Debugf("PendingUserMigrations: scanning %d users at '%s'", pageSize, bookmark)
+
+	return u.users.Scan(pageSize, bookmark, func(id string, data []byte) (bool, error) {
+		return outdated(data, model.UserSchemaVersion)
+	})
+}
+
+// MigrateUser rewrites the listed user assets which are stored with older
+// schema versions. Personal information stored in the public state is moved
+// to the private collection. Users which would be invalid once upgraded,
+// along with their personal information, are skipped and listed as invalid
+func (u *UserStore) MigrateUser(ids []string) (*RewriteResult, error) {
+	u.logger.Debugf("MigrateUser: migrating users %v", ids)
+
+	return u.users.Rewrite(ids, func(id string, data []byte) (bool, error) {
+		if old, err := outdated(data, model.UserSchemaVersion); err != nil || !old {
+			return false, err
+		}
+
+		user, info, err := decodeUser(data)
+		if err != nil {
+			return false, err
+		}
+
+		// validated before writing either of them
+		if err := u.users.validate(user); err != nil {
+			return false, &invalidError{err}
+		}
+		if info != nil {
+			if err := u.infos.validate(info); err != nil {
+				return false, &invalidError{err}
+			}
+		}
+
+		if info != nil {
+			if err := u.SetUserInfo(info); err != nil {
+				return false, err
+			}
+		}
+
+		return true, u.SetUser(user)
+	})
 }
@@ -0,0 +1,152 @@
+package store_test
+
+import (
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cdtlab19/coffee-chaincode/mockstub"
+	"github.com/cdtlab19/coffee-chaincode/model"
+	. "github.com/cdtlab19/coffee-chaincode/store"
+)
+
+type userAdapter struct{ *UserStore }
+
+func (a userAdapter) Get(id string) (interface{}, error) { return a.GetUser(id) }
+func (a userAdapter) Create(asset interface{}) error     { return a.CreateUser(asset.(*model.User)) }
+func (a userAdapter) Update(asset interface{}) error     { return a.UpdateUser(asset.(*model.User)) }
+func (a userAdapter) Put(asset interface{}) error        { return a.SetUser(asset.(*model.User)) }
+func (a userAdapter) Delete(id string) error             { return a.DeleteUser(id, testTombstone()) }
+func (a userAdapter) Restore(id string) error            { return a.RestoreUser(id) }
+
+func (a userAdapter) Purge(id string, retention time.Duration, now time.Time) error {
+	return a.PurgeUser(id, retention, now)
+}
+
+func (a userAdapter) List() ([]interface{}, error) {
+	return a.list(false)
+}
+
+func (a userAdapter) ListAll() ([]interface{}, error) {
+	return a.list(true)
+}
+
+func (a userAdapter) list(includeDeleted bool) ([]interface{}, error) {
+	users, err := a.AllUser(includeDeleted)
+	assets := []interface{}{}
+	for _, user := range users {
+		assets = append(assets, user)
+	}
+	return assets, err
+}
+
+var _ = describeConformance("UserStore", conformance{
+	New: func(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger) storeAdapter {
+		return userAdapter{NewUserStore(stub, logger)}
+	},
+	Valid: func(id string) interface{} {
+		return model.NewUser(id, 3)
+	},
+	Invalid: func(id string) interface{} {
+		return model.NewUser(id, -1)
+	},
+	Deletable: true,
+})
+
+var _ = Describe("UserStore", func() {
+	var mock *mockstub.Stub
+	var st *UserStore
+
+	BeforeEach(func() {
+		mock = mockstub.NewStub("store", nil)
+		st = NewUserStore(mock, shim.NewLogger("store-test"))
+		mock.MockTransactionStart("0000")
+
+		info := model.NewUserInfo("0000", "someone", "", "")
+		user := model.NewUser("0000", 3)
+		user.SetInfo(info)
+		Expect(st.CreateUser(user)).To(Succeed())
+		Expect(st.SetUserInfo(info)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		mock.MockTransactionEnd("0000")
+	})
+
+	It("Should keep the personal information of deleted users", func() {
+		Expect(st.DeleteUser("0000", testTombstone())).To(Succeed())
+
+		info, err := st.GetUserInfo("0000")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Name).To(Equal("someone"))
+	})
+
+	It("Should purge the personal information with the user", func() {
+		Expect(st.DeleteUser("0000", testTombstone())).To(Succeed())
+		Expect(st.PurgeUser("0000", 0, deletedAt)).To(Succeed())
+
+		_, err := st.GetUserInfo("0000")
+		Expect(IsNotFound(err)).To(BeTrue(), "%v", err)
+	})
+
+	Context("Payments", func() {
+		key := model.NewPaymentKey([]byte("0123456789abcdef"))
+
+		BeforeEach(func() {
+			Expect(st.SetPaymentKey(key)).To(Succeed())
+		})
+
+		It("Should store payments in the private collection by their reference", func() {
+			payment := model.NewPayment(key, "PAY-0001", "0000", 5, deletedAt)
+			Expect(st.CreatePayment(payment)).To(Succeed())
+
+			stored, err := st.GetPayment("PAY-0001")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stored).To(Equal(payment))
+
+			_, err = st.GetPayment("PAY-0002")
+			Expect(IsNotFound(err)).To(BeTrue(), "%v", err)
+
+			for _, value := range mock.State {
+				Expect(string(value)).NotTo(ContainSubstring("PAY-0001"))
+			}
+		})
+
+		It("Should not reuse payment references", func() {
+			Expect(st.CreatePayment(model.NewPayment(key, "PAY-0001", "0000", 5, deletedAt))).To(Succeed())
+
+			err := st.CreatePayment(model.NewPayment(key, "PAY-0001", "0000", 3, deletedAt))
+			Expect(IsAlreadyExists(err)).To(BeTrue(), "%v", err)
+		})
+
+		It("Should not reuse references of payments recorded before the key", func() {
+			legacy := model.NewPayment(key, "PAY-0001", "0000", 5, deletedAt)
+			legacy.ID = model.LegacyPaymentID("PAY-0001")
+			key, _ := mock.CreateCompositeKey(model.PaymentDocType, []string{legacy.ID})
+			Expect(mock.PutPrivateData(UserInfoCollection, key, legacy.JSON())).To(Succeed())
+
+			stored, err := st.GetPayment("PAY-0001")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stored).To(Equal(legacy))
+
+			err = st.CreatePayment(model.NewPayment(model.NewPaymentKey([]byte("0123456789abcdef")),
+				"PAY-0001", "0000", 3, deletedAt))
+			Expect(IsAlreadyExists(err)).To(BeTrue(), "%v", err)
+		})
+
+		It("Should only set the payment key once", func() {
+			err := st.SetPaymentKey(model.NewPaymentKey([]byte("fedcba9876543210")))
+			Expect(IsAlreadyExists(err)).To(BeTrue(), "%v", err)
+
+			stored, err := st.GetPaymentKey()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stored).To(Equal(key))
+
+			for key := range mock.State {
+				Expect(key).NotTo(ContainSubstring(model.PaymentKeyDocType))
+			}
+		})
+	})
+})
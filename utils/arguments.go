@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/vtfr/rocha"
+	"github.com/vtfr/rocha/argsmw"
+)
+
+// OptionalArguments is an argument parsing middleware like argsmw.Arguments,
+// but only the first `required` arguments must be sent. Missing optional
+// arguments are not stored in the context, so handlers may verify them with
+// rocha.Context.Get
+func OptionalArguments(required int, defs ...argsmw.Definition) rocha.Middleware {
+	return func(next rocha.Handler) rocha.Handler {
+		return func(c rocha.Context) pb.Response {
+			args := c.Args()
+
+			if len(args) < required || len(args) > len(defs) {
+				return shim.Error(
+					fmt.Sprintf("Invalid number of arguments. Expected between %d and %d",
+						required, len(defs)))
+			}
+
+			for i, arg := range args {
+				if err := defs[i](c, arg); err != nil {
+					return shim.Error(
+						fmt.Sprintf("Invalid argument at position '%d': %s",
+							i, err.Error()))
+				}
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// Bool is a boolean argument parser which stores a bool in the given context
+// key, accepting the values understood by strconv.ParseBool
+func Bool(key string) argsmw.Definition {
+	return func(c rocha.Context, arg string) error {
+		v, err := strconv.ParseBool(arg)
+		if err != nil {
+			return err
+		}
+
+		c.Set(key, v)
+		return nil
+	}
+}
@@ -0,0 +1,75 @@
+package utils_test
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vtfr/rocha"
+	"github.com/vtfr/rocha/argsmw"
+
+	. "github.com/cdtlab19/coffee-chaincode/utils"
+)
+
+var _ = Describe("OptionalArguments", func() {
+	var received rocha.Context
+
+	handler := rocha.Chain(func(c rocha.Context) pb.Response {
+		received = c
+		return shim.Success(nil)
+	}, OptionalArguments(1,
+		argsmw.String("name"),
+		argsmw.Int("amount", 10)))
+
+	BeforeEach(func() {
+		received = nil
+	})
+
+	It("Should parse all arguments", func() {
+		resp := handler(rocha.NewContext(nil, "", []string{"name", "3"}))
+		Expect(int(resp.Status)).To(Equal(shim.OK))
+		Expect(received.String("name")).To(Equal("name"))
+		Expect(received.Int("amount")).To(Equal(3))
+	})
+
+	It("Should not set missing optional arguments", func() {
+		resp := handler(rocha.NewContext(nil, "", []string{"name"}))
+		Expect(int(resp.Status)).To(Equal(shim.OK))
+
+		_, ok := received.Get("amount")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("Should fail if required arguments are missing", func() {
+		resp := handler(rocha.NewContext(nil, "", []string{}))
+		Expect(int(resp.Status)).To(Equal(shim.ERROR))
+		Expect(received).To(BeNil())
+	})
+
+	It("Should fail if too many arguments are sent", func() {
+		resp := handler(rocha.NewContext(nil, "", []string{"name", "3", "other"}))
+		Expect(int(resp.Status)).To(Equal(shim.ERROR))
+	})
+
+	It("Should fail if an argument is invalid", func() {
+		resp := handler(rocha.NewContext(nil, "", []string{"name", "three"}))
+		Expect(int(resp.Status)).To(Equal(shim.ERROR))
+		Expect(resp.Message).To(ContainSubstring("position '1'"))
+	})
+})
+
+var _ = Describe("Bool", func() {
+	It("Should parse booleans", func() {
+		c := rocha.NewContext(nil, "", []string{})
+		Expect(Bool("flag")(c, "true")).To(Succeed())
+		Expect(c.Value("flag")).To(BeTrue())
+
+		Expect(Bool("flag")(c, "0")).To(Succeed())
+		Expect(c.Value("flag")).To(BeFalse())
+	})
+
+	It("Should reject other values", func() {
+		c := rocha.NewContext(nil, "", []string{})
+		Expect(Bool("flag")(c, "yes")).NotTo(Succeed())
+	})
+})
@@ -3,19 +3,96 @@ package utils
 import (
 	"encoding/json"
 	"fmt"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
 
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 	pb "github.com/hyperledger/fabric/protos/peer"
 	"github.com/vtfr/rocha"
 )
 
+// StatusError is an error with a specific response status, such as 404 for
+// missing assets. Other errors are responded with shim.ERROR
+type StatusError interface {
+	error
+	Status() int32
+}
+
+// Error converts an error to an error pb.Response, using it's status if it's
+// a StatusError
+func Error(err error) pb.Response {
+	if e, ok := err.(StatusError); ok {
+		return pb.Response{Status: e.Status(), Message: e.Error()}
+	}
+	return shim.Error(err.Error())
+}
+
+// APIVersion is the version of the response envelope
+const APIVersion = "1"
+
+// Envelope wraps the responses of handlers which opt in with WithEnvelope,
+// with the transaction's metadata. Error responses keep the error in their
+// message too
+type Envelope struct {
+	APIVersion string          `json:"apiVersion"`
+	TxID       string          `json:"txId"`
+	Timestamp  time.Time       `json:"timestamp"`
+	Data       json.RawMessage `json:"data,omitempty"`
+	Error      *EnvelopeError  `json:"error,omitempty"`
+	Page       *Page           `json:"page,omitempty"`
+}
+
+// EnvelopeError is the error of an enveloped response
+type EnvelopeError struct {
+	Status  int32  `json:"status"`
+	Message string `json:"message"`
+}
+
+// Page is the pagination info of an enveloped response
+type Page struct {
+	// Bookmark must be sent to read the next page
+	Bookmark string `json:"bookmark"`
+	// Done is true when there are no more pages
+	Done bool `json:"done"`
+}
+
+// Paginated is implemented by responses with a single page of results, whose
+// pagination info is added to the envelope
+type Paginated interface {
+	PageInfo() (bookmark string, done bool)
+}
+
+// ResponseOption configures the responses of RespondJSON
+type ResponseOption func(o *responseOptions)
+
+type responseOptions struct {
+	envelope bool
+}
+
+// WithEnvelope wraps the responses in an Envelope
+func WithEnvelope() ResponseOption {
+	return func(o *responseOptions) {
+		o.envelope = true
+	}
+}
+
 // RespondJSON receives a handler returning (interface{}, error) and converts
 // it to a valid JSON pb.Response or an error pb.Response
-func RespondJSON(h func(c rocha.Context) (interface{}, error)) rocha.Handler {
+func RespondJSON(h func(c rocha.Context) (interface{}, error), options ...ResponseOption) rocha.Handler {
+	opts := &responseOptions{}
+	for _, option := range options {
+		option(opts)
+	}
+
 	return func(c rocha.Context) pb.Response {
 		ret, err := h(c)
+		if opts.envelope {
+			return respondEnvelope(c.Stub(), ret, err)
+		}
+
 		if err != nil {
-			return shim.Error(err.Error())
+			return Error(err)
 		}
 
 		// if no data is sent, return simple Success message
@@ -31,3 +108,34 @@ func RespondJSON(h func(c rocha.Context) (interface{}, error)) rocha.Handler {
 		return shim.Success(data)
 	}
 }
+
+// respondEnvelope responds the result of a handler in an Envelope
+func respondEnvelope(stub shim.ChaincodeStubInterface, ret interface{}, err error) pb.Response {
+	envelope := &Envelope{APIVersion: APIVersion, TxID: stub.GetTxID()}
+
+	// the transaction's timestamp is the same in every endorsement
+	if timestamp, err := stub.GetTxTimestamp(); err == nil && timestamp != nil {
+		envelope.Timestamp, _ = ptypes.Timestamp(timestamp)
+	}
+
+	response := shim.Success(nil)
+	if err != nil {
+		response = Error(err)
+		envelope.Error = &EnvelopeError{response.Status, response.Message}
+	} else if ret != nil {
+		if envelope.Data, err = json.Marshal(ret); err != nil {
+			return shim.Error(fmt.Sprintf("Failed encoding response: %s", err.Error()))
+		}
+		if paginated, ok := ret.(Paginated); ok {
+			bookmark, done := paginated.PageInfo()
+			envelope.Page = &Page{bookmark, done}
+		}
+	}
+
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Failed encoding response: %s", err.Error()))
+	}
+	response.Payload = data
+	return response
+}
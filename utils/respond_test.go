@@ -1,6 +1,7 @@
 package utils_test
 
 import (
+	"encoding/json"
 	"errors"
 	"math"
 
@@ -70,3 +71,104 @@ var _ = Describe("Respond", func() {
 		Expect(resp.Message).To(ContainSubstring("Failed encoding response"))
 	})
 })
+
+// notFoundError is an error with a specific response status
+type notFoundError struct{}
+
+func (notFoundError) Error() string { return "not found" }
+func (notFoundError) Status() int32 { return 404 }
+
+var _ = Describe("Error", func() {
+	It("Should use the status of a StatusError", func() {
+		handler := RespondJSON(func(c rocha.Context) (interface{}, error) {
+			return nil, notFoundError{}
+		})
+
+		resp := handler(rocha.NewContext(nil, "", []string{}))
+		Expect(int(resp.Status)).To(Equal(404))
+		Expect(resp.Message).To(Equal("not found"))
+	})
+
+	It("Should use shim.ERROR for other errors", func() {
+		resp := Error(errors.New("failure"))
+		Expect(int(resp.Status)).To(Equal(shim.ERROR))
+		Expect(resp.Message).To(Equal("failure"))
+	})
+})
+
+// page is a paginated response
+type page struct {
+	Next string `json:"next"`
+}
+
+func (p page) PageInfo() (string, bool) { return p.Next, p.Next == "" }
+
+var _ = Describe("Envelope", func() {
+	var stub *shim.MockStub
+	var context rocha.Context
+
+	BeforeEach(func() {
+		stub = shim.NewMockStub("utils", nil)
+		stub.MockTransactionStart("tx1")
+		context = rocha.NewContext(stub, "", []string{})
+	})
+
+	AfterEach(func() {
+		stub.MockTransactionEnd("tx1")
+	})
+
+	envelope := func(payload []byte) *Envelope {
+		envelope := &Envelope{}
+		Expect(json.Unmarshal(payload, envelope)).To(Succeed())
+		return envelope
+	}
+
+	It("Should wrap the data with the transaction's metadata", func() {
+		handler := RespondJSON(func(c rocha.Context) (interface{}, error) {
+			return map[string]string{"key": "value"}, nil
+		}, WithEnvelope())
+
+		resp := handler(context)
+		Expect(int(resp.Status)).To(Equal(shim.OK))
+
+		env := envelope(resp.Payload)
+		Expect(env.APIVersion).To(Equal(APIVersion))
+		Expect(env.TxID).To(Equal("tx1"))
+		Expect(env.Data).To(MatchJSON(`{"key":"value"}`))
+		Expect(env.Error).To(BeNil())
+		Expect(env.Page).To(BeNil())
+
+		timestamp, err := stub.GetTxTimestamp()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(env.Timestamp.Unix()).To(Equal(timestamp.Seconds))
+	})
+
+	It("Should omit empty data", func() {
+		handler := RespondJSON(func(c rocha.Context) (interface{}, error) {
+			return nil, nil
+		}, WithEnvelope())
+
+		resp := handler(context)
+		Expect(int(resp.Status)).To(Equal(shim.OK))
+		Expect(string(resp.Payload)).NotTo(ContainSubstring(`"data"`))
+	})
+
+	It("Should wrap errors, keeping their status and message", func() {
+		handler := RespondJSON(func(c rocha.Context) (interface{}, error) {
+			return nil, notFoundError{}
+		}, WithEnvelope())
+
+		resp := handler(context)
+		Expect(int(resp.Status)).To(Equal(404))
+		Expect(resp.Message).To(Equal("not found"))
+		Expect(envelope(resp.Payload).Error).To(Equal(&EnvelopeError{Status: 404, Message: "not found"}))
+	})
+
+	It("Should add the pagination info of paginated responses", func() {
+		handler := RespondJSON(func(c rocha.Context) (interface{}, error) {
+			return page{"b1"}, nil
+		}, WithEnvelope())
+
+		Expect(envelope(handler(context).Payload).Page).To(Equal(&Page{Bookmark: "b1", Done: false}))
+	})
+})